@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// catalogTimeFormat matches the timestamps printed by 'ilab model list' and 'ilab data list'.
+const catalogTimeFormat = "2006-01-02 15:04:05"
+
+// quantizationFromName recovers a GGUF quantization from file names such as
+// "granite-7b-lab-Q4_K_M.gguf" when the header doesn't say.
+var quantizationFromName = regexp.MustCompile(`(?i)[-_.]((?:I?Q\d(?:_[0-9A-Z]+)*)|BF16|F16|F32)\.gguf$`)
+
+// humanSize formats a byte count the way ilab does (e.g. "4.1 GB").
+func humanSize(bytes int64) string {
+	size := float64(bytes)
+	for _, unit := range []string{"B", "KB", "MB", "GB"} {
+		if size < 1024 {
+			return fmt.Sprintf("%.1f %s", size, unit)
+		}
+		size /= 1024
+	}
+	return fmt.Sprintf("%.1f TB", size)
+}
+
+// hfModelConfig holds the fields we report from a Hugging Face config.json.
+type hfModelConfig struct {
+	ModelType          string   `json:"model_type"`
+	Architectures      []string `json:"architectures"`
+	TorchDtype         string   `json:"torch_dtype"`
+	QuantizationConfig *struct {
+		QuantMethod string `json:"quant_method"`
+		Bits        int    `json:"bits"`
+	} `json:"quantization_config"`
+}
+
+// describeHFModel reads a safetensors/PyTorch model directory. It reports false when the
+// directory holds no model weights.
+func describeHFModel(dir string, m *Model) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	hasConfig, format := false, ""
+	for _, e := range entries {
+		switch {
+		case e.Name() == "config.json":
+			hasConfig = true
+		case strings.HasSuffix(e.Name(), ".safetensors"):
+			format = "safetensors"
+		case strings.HasSuffix(e.Name(), ".bin") && format == "":
+			format = "pytorch"
+		}
+	}
+	if !hasConfig || format == "" {
+		return false
+	}
+	m.Format = format
+
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return true
+	}
+	var cfg hfModelConfig
+	if json.Unmarshal(data, &cfg) != nil {
+		return true
+	}
+	m.Architecture = cfg.ModelType
+	if m.Architecture == "" && len(cfg.Architectures) > 0 {
+		m.Architecture = cfg.Architectures[0]
+	}
+	switch {
+	case cfg.QuantizationConfig != nil && cfg.QuantizationConfig.Bits > 0:
+		m.Quantization = fmt.Sprintf("%s-%dbit", cfg.QuantizationConfig.QuantMethod, cfg.QuantizationConfig.Bits)
+	case cfg.QuantizationConfig != nil && cfg.QuantizationConfig.QuantMethod != "":
+		m.Quantization = cfg.QuantizationConfig.QuantMethod
+	default:
+		m.Quantization = cfg.TorchDtype
+	}
+	return true
+}
+
+// describeGGUFModel reads the header of a GGUF file. It reports false for non-GGUF files.
+func describeGGUFModel(path string, m *Model) bool {
+	info, err := readGGUFInfo(path)
+	if err != nil {
+		return false
+	}
+	m.Format = "gguf"
+	m.Architecture = info.Architecture
+	m.Quantization = info.Quantization
+	if m.Quantization == "" {
+		if match := quantizationFromName.FindStringSubmatch(path); match != nil {
+			m.Quantization = strings.ToUpper(match[1])
+		}
+	}
+	return true
+}
+
+// scanModels enumerates the GGUF files and model directories under the models directory.
+func scanModels() ([]Model, error) {
+	modelsDir, err := getModelsDir()
+	if err != nil {
+		return nil, err
+	}
+	names, err := listModelEntries(modelsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	models := []Model{}
+	for _, name := range names {
+		path := filepath.Join(modelsDir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		m := Model{Name: name, LastModified: info.ModTime().Format(catalogTimeFormat)}
+		if info.IsDir() {
+			if !describeHFModel(path, &m) {
+				continue
+			}
+		} else if !describeGGUFModel(path, &m) {
+			continue
+		}
+		m.Bytes, _ = dirSize(path)
+		m.Size = humanSize(m.Bytes)
+		models = append(models, m)
+	}
+	return models, nil
+}
+
+// scanDatasets enumerates the .jsonl files under the datasets directory, newest first.
+func scanDatasets() ([]Data, error) {
+	datasetsDir, err := getDatasetsDir()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(datasetsDir); err != nil {
+		return nil, err
+	}
+
+	type entry struct {
+		data    Data
+		modTime time.Time
+	}
+	var entries []entry
+	err = filepath.WalkDir(datasetsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != datasetsDir && strings.HasPrefix(d.Name(), ".") {
+			// Skip staging directories of datasets still being written
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".jsonl") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(datasetsDir, path)
+		entries = append(entries, entry{
+			data: Data{
+				Dataset:   rel,
+				CreatedAt: info.ModTime().Format(catalogTimeFormat),
+				FileSize:  humanSize(info.Size()),
+				Bytes:     info.Size(),
+			},
+			modTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.After(entries[j].modTime) })
+	dataList := make([]Data, 0, len(entries))
+	for _, e := range entries {
+		dataList = append(dataList, e.data)
+	}
+	return dataList, nil
+}
+
+// listDatasets enumerates the datasets directory, falling back to parsing 'ilab data list'.
+func (srv *ILabServer) listDatasets() ([]Data, error) {
+	dataList, err := scanDatasets()
+	if err == nil {
+		return dataList, nil
+	}
+	srv.log.Warnf("Could not scan datasets directory (%v); falling back to 'ilab data list'", err)
+
+	output, err := srv.runIlabCommand("data", "list")
+	if err != nil {
+		return nil, fmt.Errorf("error running 'ilab data list': %v: %s", err, output)
+	}
+	return srv.parseDataList(output)
+}
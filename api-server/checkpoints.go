@@ -0,0 +1,847 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	errNoCheckpoints      = errors.New("no checkpoints found")
+	errCheckpointNotFound = errors.New("checkpoint not found")
+)
+
+// CheckpointEvaluation is an evaluation result recorded against a checkpoint (e.g. by /qna-eval).
+type CheckpointEvaluation struct {
+	Evaluator string             `json:"evaluator"`
+	Scores    map[string]float64 `json:"scores,omitempty"`
+	Result    string             `json:"result,omitempty"` // raw output when it carries no numeric scores
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// CheckpointInfo describes a training checkpoint and the job that produced it.
+type CheckpointInfo struct {
+	Name          string                 `json:"name"`
+	Path          string                 `json:"path"`
+	JobID         string                 `json:"job_id,omitempty"`
+	Branch        string                 `json:"branch,omitempty"`
+	BaseModel     string                 `json:"base_model,omitempty"`
+	CommitSHA     string                 `json:"commit_sha,omitempty"` // taxonomy commit of the training job's data
+	TaxonomyDirty bool                   `json:"taxonomy_dirty,omitempty"`
+	ModelType     string                 `json:"model_type,omitempty"`
+	Samples       *int                   `json:"samples,omitempty"`
+	Epoch         *float64               `json:"epoch,omitempty"`
+	Bytes         int64                  `json:"bytes"`
+	CreatedAt     time.Time              `json:"created_at"`
+	Evaluations   []CheckpointEvaluation `json:"evaluations"`
+}
+
+var (
+	checkpointSamplesPattern = regexp.MustCompile(`samples_(\d+)`)
+	checkpointEpochPattern   = regexp.MustCompile(`epoch[_-]?(\d+)`)
+)
+
+// checkpointRecord is the provenance stored in the checkpoints table.
+type checkpointRecord struct {
+	JobID         string
+	Branch        string
+	BaseModel     string
+	CommitSHA     string
+	TaxonomyDirty bool
+}
+
+// checkpointConfig holds the fields we read from a checkpoint's config.json / trainer_state.json.
+type checkpointConfig struct {
+	NameOrPath string   `json:"_name_or_path"`
+	ModelType  string   `json:"model_type"`
+	Epoch      *float64 `json:"epoch"`
+}
+
+// readCheckpointConfig reads config.json and, if present, the epoch from trainer_state.json.
+func readCheckpointConfig(dir string) checkpointConfig {
+	var cfg checkpointConfig
+	if data, err := os.ReadFile(filepath.Join(dir, "config.json")); err == nil {
+		_ = json.Unmarshal(data, &cfg)
+	}
+	if cfg.Epoch == nil {
+		var state struct {
+			Epoch *float64 `json:"epoch"`
+		}
+		if data, err := os.ReadFile(filepath.Join(dir, "trainer_state.json")); err == nil && json.Unmarshal(data, &state) == nil {
+			cfg.Epoch = state.Epoch
+		}
+	}
+	return cfg
+}
+
+// modelNameFromPath turns a model path under the models directory into its model name.
+func modelNameFromPath(path string) string {
+	if modelsDir, err := getModelsDir(); err == nil {
+		if rel, err := filepath.Rel(modelsDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
+
+// trainJobBaseModel returns the model a training job started from: the one recorded on
+// the job or, for jobs from before it was recorded, its model path argument. The model path
+// of a resumed job is a checkpoint, so those without a record have no known base model.
+func trainJobBaseModel(job *Job) string {
+	if job.BaseModel != "" || job.ResumeCheckpoint != "" {
+		return job.BaseModel
+	}
+	for _, arg := range job.Args {
+		for _, flag := range []string{"--model-path=", "--gguf-model-path="} {
+			if strings.HasPrefix(arg, flag) {
+				return modelNameFromPath(strings.TrimPrefix(arg, flag))
+			}
+		}
+	}
+	return ""
+}
+
+// isTrainJob reports whether job is an "ilab model train" job.
+func isTrainJob(job *Job) bool {
+	return strings.HasPrefix(job.JobID, "t-")
+}
+
+// checkpointDir is a checkpoint directory on disk.
+type checkpointDir struct {
+	name  string
+	path  string
+	jobID string // the training job whose output directory holds it, if any
+	info  fs.FileInfo
+}
+
+// listCheckpointDirs lists the checkpoint directories. Those in the shared checkpoints
+// directory keep their directory name. Every job reuses names such as samples_1000, so
+// those in a training job's own output directory are named "<job_id>-<dir>", e.g.
+// "t-1736292283945521000-samples_1000". It fails with a not-exist error when neither
+// the shared directory nor any job output directory exists.
+func listCheckpointDirs() ([]checkpointDir, error) {
+	checkpointsDir, err := getCheckpointsDir()
+	if err != nil {
+		return nil, err
+	}
+	outputsDir, err := getTrainOutputsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, err := readCheckpointDirs(checkpointsDir, "")
+	jobEntries, jobsErr := os.ReadDir(outputsDir)
+	if err != nil && (!os.IsNotExist(err) || jobsErr != nil) {
+		return nil, err
+	}
+	for _, e := range jobEntries {
+		if !e.IsDir() {
+			continue
+		}
+		jobDirs, err := readCheckpointDirs(filepath.Join(outputsDir, e.Name(), "hf_format"), e.Name())
+		if err != nil {
+			continue
+		}
+		dirs = append(dirs, jobDirs...)
+	}
+	return dirs, nil
+}
+
+// readCheckpointDirs lists the checkpoint directories in dir, the output of job jobID if set.
+func readCheckpointDirs(dir, jobID string) ([]checkpointDir, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []checkpointDir
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		d := checkpointDir{name: e.Name(), path: filepath.Join(dir, e.Name()), jobID: jobID, info: info}
+		if jobID != "" {
+			d.name = jobID + "-" + e.Name()
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs, nil
+}
+
+// recordCheckpoints attributes the checkpoints in a training job's own output directory to
+// that job. The shared directory is left alone: other runs write there concurrently, so a
+// checkpoint's timestamp does not tell which run wrote it.
+func (srv *ILabServer) recordCheckpoints(job *Job) {
+	outputDir, err := getTrainOutputDir(job.JobID)
+	if err != nil {
+		srv.log.Errorf("Error getting output directory of job %s: %v", job.JobID, err)
+		return
+	}
+	dirs, err := readCheckpointDirs(filepath.Join(outputDir, "hf_format"), job.JobID)
+	if err != nil {
+		return
+	}
+
+	baseModel := trainJobBaseModel(job)
+	recorded := 0
+	for _, d := range dirs {
+		res, err := srv.db.Exec(`INSERT OR REPLACE INTO checkpoints (name, path, job_id, branch, base_model, commit_sha, taxonomy_dirty, recorded_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			d.name, d.path, job.JobID, job.Branch, baseModel, job.CommitSHA, job.TaxonomyDirty, time.Now().Format(time.RFC3339))
+		if err != nil {
+			srv.log.Errorf("Error recording checkpoint '%s' for job %s: %v", d.name, job.JobID, err)
+			continue
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			recorded++
+		}
+	}
+	srv.log.Infof("Recorded %d checkpoint(s) for training job %s", recorded, job.JobID)
+}
+
+// loadCheckpointRecords returns the recorded checkpoint provenance keyed by checkpoint name.
+func (srv *ILabServer) loadCheckpointRecords() (map[string]checkpointRecord, error) {
+	rows, err := srv.db.Query("SELECT name, job_id, branch, base_model, commit_sha, taxonomy_dirty FROM checkpoints")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make(map[string]checkpointRecord)
+	for rows.Next() {
+		var name string
+		var jobID, branch, baseModel, commitSHA sql.NullString
+		var taxonomyDirty sql.NullBool
+		if err := rows.Scan(&name, &jobID, &branch, &baseModel, &commitSHA, &taxonomyDirty); err != nil {
+			return nil, err
+		}
+		records[name] = checkpointRecord{
+			JobID:         jobID.String,
+			Branch:        branch.String,
+			BaseModel:     baseModel.String,
+			CommitSHA:     commitSHA.String,
+			TaxonomyDirty: taxonomyDirty.Bool,
+		}
+	}
+	return records, rows.Err()
+}
+
+// loadCheckpointEvaluations returns the evaluations recorded for each checkpoint, oldest first.
+func (srv *ILabServer) loadCheckpointEvaluations() (map[string][]CheckpointEvaluation, error) {
+	rows, err := srv.db.Query("SELECT checkpoint, evaluator, result, created_at FROM checkpoint_evaluations ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	evals := make(map[string][]CheckpointEvaluation)
+	for rows.Next() {
+		var checkpoint, evaluator, result, createdAt string
+		if err := rows.Scan(&checkpoint, &evaluator, &result, &createdAt); err != nil {
+			return nil, err
+		}
+		eval := CheckpointEvaluation{Evaluator: evaluator}
+		eval.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		if scores := parseEvaluationScores(result); len(scores) > 0 {
+			eval.Scores = scores
+		} else {
+			eval.Result = result
+		}
+		evals[checkpoint] = append(evals[checkpoint], eval)
+	}
+	return evals, rows.Err()
+}
+
+// parseEvaluationScores extracts the numeric top-level fields of a JSON evaluation result.
+func parseEvaluationScores(result string) map[string]float64 {
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(result)), &raw); err != nil {
+		return nil
+	}
+	scores := make(map[string]float64)
+	for k, v := range raw {
+		if f, ok := v.(float64); ok {
+			scores[k] = f
+		}
+	}
+	return scores
+}
+
+// recordCheckpointEvaluation stores an evaluation result if modelPath is a checkpoint.
+func (srv *ILabServer) recordCheckpointEvaluation(modelPath, evaluator, result string) {
+	dirs, err := listCheckpointDirs()
+	if err != nil {
+		return
+	}
+	var name string
+	for _, d := range dirs {
+		if rel, err := filepath.Rel(d.path, filepath.Clean(modelPath)); err == nil && !strings.HasPrefix(rel, "..") {
+			name = d.name
+			break
+		}
+	}
+	if name == "" {
+		return
+	}
+	if _, err := srv.db.Exec("INSERT INTO checkpoint_evaluations (checkpoint, evaluator, result, created_at) VALUES (?, ?, ?, ?)",
+		name, evaluator, result, time.Now().Format(time.RFC3339)); err != nil {
+		srv.log.Errorf("Error recording %s evaluation for checkpoint '%s': %v", evaluator, name, err)
+	}
+}
+
+// listCheckpoints describes every checkpoint directory, newest first. Unrecorded checkpoints
+// are attributed to the job whose output directory holds them or, in the shared directory,
+// to the training job running when they were written.
+func (srv *ILabServer) listCheckpoints() ([]CheckpointInfo, error) {
+	dirs, err := listCheckpointDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := srv.loadCheckpointRecords()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint records: %v", err)
+	}
+	evals, err := srv.loadCheckpointEvaluations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint evaluations: %v", err)
+	}
+	jobs, err := srv.listAllJobs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %v", err)
+	}
+
+	checkpoints := []CheckpointInfo{}
+	for _, d := range dirs {
+		cp := CheckpointInfo{
+			Name:        d.name,
+			Path:        d.path,
+			CreatedAt:   d.info.ModTime(),
+			Evaluations: evals[d.name],
+		}
+		if cp.Evaluations == nil {
+			cp.Evaluations = []CheckpointEvaluation{}
+		}
+		cp.Bytes, _ = dirSize(d.path)
+
+		dirName := filepath.Base(d.path)
+		if m := checkpointSamplesPattern.FindStringSubmatch(dirName); m != nil {
+			if n, err := strconv.Atoi(m[1]); err == nil {
+				cp.Samples = &n
+			}
+		}
+		cfg := readCheckpointConfig(d.path)
+		cp.ModelType = cfg.ModelType
+		cp.Epoch = cfg.Epoch
+		if m := checkpointEpochPattern.FindStringSubmatch(dirName); m != nil && cp.Epoch == nil {
+			if n, err := strconv.ParseFloat(m[1], 64); err == nil {
+				cp.Epoch = &n
+			}
+		}
+
+		if rec, ok := records[d.name]; ok {
+			cp.JobID, cp.Branch, cp.BaseModel = rec.JobID, rec.Branch, rec.BaseModel
+			cp.CommitSHA, cp.TaxonomyDirty = rec.CommitSHA, rec.TaxonomyDirty
+		} else if job := jobByID(jobs, d.jobID); job != nil {
+			cp.JobID, cp.Branch, cp.BaseModel = job.JobID, job.Branch, trainJobBaseModel(job)
+			cp.CommitSHA, cp.TaxonomyDirty = job.CommitSHA, job.TaxonomyDirty
+		} else if job := trainJobAt(jobs, cp.CreatedAt); d.jobID == "" && job != nil {
+			cp.JobID, cp.Branch, cp.BaseModel = job.JobID, job.Branch, trainJobBaseModel(job)
+			cp.CommitSHA, cp.TaxonomyDirty = job.CommitSHA, job.TaxonomyDirty
+		}
+		if cp.BaseModel == "" && cfg.NameOrPath != "" {
+			cp.BaseModel = modelNameFromPath(cfg.NameOrPath)
+		}
+
+		checkpoints = append(checkpoints, cp)
+	}
+
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].CreatedAt.After(checkpoints[j].CreatedAt)
+	})
+	return checkpoints, nil
+}
+
+// findCheckpoint returns the checkpoint with the given name, or the given path within the
+// checkpoints directory.
+func (srv *ILabServer) findCheckpoint(nameOrPath string) (*CheckpointInfo, error) {
+	checkpoints, err := srv.listCheckpoints()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for i := range checkpoints {
+		if checkpoints[i].Name == nameOrPath || checkpoints[i].Path == filepath.Clean(nameOrPath) {
+			return &checkpoints[i], nil
+		}
+	}
+	return nil, errCheckpointNotFound
+}
+
+// resolveResumeCheckpoint returns the checkpoint a training job may resume from. Only
+// checkpoints saved in Hugging Face format can be loaded as a model, and the simple
+// pipeline, which trains GGUF models, cannot resume at all.
+func (srv *ILabServer) resolveResumeCheckpoint(nameOrPath string) (*CheckpointInfo, error) {
+	if !srv.writesHFCheckpoints() {
+		return nil, fmt.Errorf("the simple pipeline cannot resume from a checkpoint")
+	}
+	cp, err := srv.findCheckpoint(nameOrPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(cp.Path, "config.json")); err != nil {
+		return nil, fmt.Errorf("checkpoint '%s' has no config.json and cannot be loaded as a model", cp.Name)
+	}
+	return cp, nil
+}
+
+// jobByID returns the job with the given ID, or nil if jobID is empty or unknown.
+func jobByID(jobs []*Job, jobID string) *Job {
+	for _, job := range jobs {
+		if jobID != "" && job.JobID == jobID {
+			return job
+		}
+	}
+	return nil
+}
+
+// remainingEpochs returns the number of epochs the job that wrote cp had left to run when
+// cp was saved, rounded up. It fails when that is unknown, i.e. the job, its epoch count or
+// cp's epoch is not recorded, and when cp was saved after the job's last epoch.
+func (srv *ILabServer) remainingEpochs(cp *CheckpointInfo) (int, error) {
+	var job *Job
+	if cp.JobID != "" {
+		job, _ = srv.getJob(cp.JobID)
+	}
+	if job == nil || job.TrainSpec == nil || job.TrainSpec.NumEpochs == nil {
+		return 0, fmt.Errorf("the epoch count of the job that wrote checkpoint '%s' is unknown; pass 'epochs'", cp.Name)
+	}
+	epoch := cp.Epoch
+	if epoch == nil {
+		epoch = srv.checkpointEpochFromMetrics(cp)
+	}
+	if epoch == nil {
+		return 0, fmt.Errorf("the epoch checkpoint '%s' was saved in is unknown; pass 'epochs'", cp.Name)
+	}
+	total := *job.TrainSpec.NumEpochs
+	remaining := int(math.Ceil(float64(total) - *epoch))
+	if remaining <= 0 {
+		return 0, fmt.Errorf("checkpoint '%s' was saved after all %d epochs of job '%s'; pass 'epochs' to train further", cp.Name, total, job.JobID)
+	}
+	return remaining, nil
+}
+
+// checkpointEpochFromMetrics returns the epoch cp's job was in when it had seen cp's
+// samples, from the job's training metrics, or nil if they don't tell. instructlab-training
+// names checkpoints samples_N and records no epoch in them.
+func (srv *ILabServer) checkpointEpochFromMetrics(cp *CheckpointInfo) *float64 {
+	if cp.JobID == "" || cp.Samples == nil {
+		return nil
+	}
+	var epoch float64
+	err := srv.db.QueryRow(`
+        SELECT epoch FROM training_metrics
+        WHERE job_id = ? AND name = 'samples_seen' AND value >= ? AND epoch IS NOT NULL
+        ORDER BY value, step
+        LIMIT 1
+    `, cp.JobID, *cp.Samples).Scan(&epoch)
+	if err != nil {
+		return nil
+	}
+	return &epoch
+}
+
+// trainJobAt returns the training job that was running at t, preferring the most recently started.
+func trainJobAt(jobs []*Job, t time.Time) *Job {
+	var match *Job
+	for _, job := range jobs {
+		if !isTrainJob(job) || t.Before(job.StartTime) {
+			continue
+		}
+		if job.EndTime != nil && t.After(*job.EndTime) {
+			continue
+		}
+		if match == nil || job.StartTime.After(match.StartTime) {
+			match = job
+		}
+	}
+	return match
+}
+
+// selectLatestCheckpoint picks the "latest" checkpoint according to by:
+//   - "newest" (default): most recently written
+//   - "samples": highest sample count
+//
+// If jobID is set, only checkpoints produced by that training job are considered.
+func selectLatestCheckpoint(checkpoints []CheckpointInfo, by, jobID string) (*CheckpointInfo, error) {
+	var latest *CheckpointInfo
+	for i := range checkpoints {
+		cp := &checkpoints[i]
+		if jobID != "" && cp.JobID != jobID {
+			continue
+		}
+		if latest == nil {
+			latest = cp
+			continue
+		}
+		switch by {
+		case "", "newest":
+			if cp.CreatedAt.After(latest.CreatedAt) {
+				latest = cp
+			}
+		case "samples":
+			if samplesOf(cp) > samplesOf(latest) {
+				latest = cp
+			}
+		default:
+			return nil, fmt.Errorf("invalid latest rule '%s' (expected 'newest' or 'samples')", by)
+		}
+	}
+	if latest == nil {
+		return nil, errNoCheckpoints
+	}
+	return latest, nil
+}
+
+// samplesOf returns a checkpoint's sample count, or -1 if it has none.
+func samplesOf(cp *CheckpointInfo) int {
+	if cp.Samples == nil {
+		return -1
+	}
+	return *cp.Samples
+}
+
+// validLatestRule reports whether by names a supported "latest" rule.
+func validLatestRule(by string) bool {
+	return by == "" || by == "newest" || by == "samples"
+}
+
+// checkpointLossWindow is how many training steps up to a checkpoint its loss averages over.
+const checkpointLossWindow = 10
+
+// selectBestCheckpoint picks the best checkpoint of the training job jobID: the one with the
+// highest evaluation score if any of them was evaluated, otherwise the one with the lowest
+// training loss around its sample count.
+func (srv *ILabServer) selectBestCheckpoint(checkpoints []CheckpointInfo, jobID string) (*CheckpointInfo, error) {
+	var candidates []*CheckpointInfo
+	for i := range checkpoints {
+		if checkpoints[i].JobID == jobID {
+			candidates = append(candidates, &checkpoints[i])
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, errNoCheckpoints
+	}
+
+	var best *CheckpointInfo
+	var bestScore float64
+	for _, cp := range candidates {
+		if score, ok := evaluationScore(cp); ok && (best == nil || score > bestScore) {
+			best, bestScore = cp, score
+		}
+	}
+	if best != nil {
+		return best, nil
+	}
+
+	var bestLoss float64
+	for _, cp := range candidates {
+		if loss, ok := srv.checkpointLoss(cp); ok && (best == nil || loss < bestLoss) {
+			best, bestLoss = cp, loss
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("none of the %d checkpoints of job '%s' has an evaluation score or a training loss", len(candidates), jobID)
+	}
+	return best, nil
+}
+
+// evaluationScore returns the mean of the scores of cp's most recent scored evaluation.
+func evaluationScore(cp *CheckpointInfo) (float64, bool) {
+	for i := len(cp.Evaluations) - 1; i >= 0; i-- {
+		scores := cp.Evaluations[i].Scores
+		if len(scores) == 0 {
+			continue
+		}
+		var sum float64
+		for _, s := range scores {
+			sum += s
+		}
+		return sum / float64(len(scores)), true
+	}
+	return 0, false
+}
+
+// checkpointLoss returns the mean training loss of the checkpointLossWindow steps of cp's job
+// up to the step that reached cp's sample count.
+func (srv *ILabServer) checkpointLoss(cp *CheckpointInfo) (float64, bool) {
+	if cp.JobID == "" || cp.Samples == nil {
+		return 0, false
+	}
+	var loss sql.NullFloat64
+	err := srv.db.QueryRow(`
+        SELECT AVG(value) FROM (
+            SELECT value FROM training_metrics
+            WHERE job_id = ? AND name = 'loss' AND step <= (
+                SELECT step FROM training_metrics
+                WHERE job_id = ? AND name = 'samples_seen' AND value >= ?
+                ORDER BY value, step
+                LIMIT 1
+            )
+            ORDER BY step DESC
+            LIMIT ?
+        )
+    `, cp.JobID, cp.JobID, *cp.Samples, checkpointLossWindow).Scan(&loss)
+	if err != nil || !loss.Valid {
+		return 0, false
+	}
+	return loss.Float64, true
+}
+
+// getLatestCheckpointHandler handles GET /checkpoints/latest?by={newest|samples}&job_id=...
+func (srv *ILabServer) getLatestCheckpointHandler(w http.ResponseWriter, r *http.Request) {
+	by := r.URL.Query().Get("by")
+	jobID := r.URL.Query().Get("job_id")
+	srv.log.Infof("GET /checkpoints/latest called, by=%q, job_id=%q", by, jobID)
+
+	if !validLatestRule(by) {
+		http.Error(w, fmt.Sprintf("Invalid value for 'by': '%s' (expected 'newest' or 'samples')", by), http.StatusBadRequest)
+		return
+	}
+
+	checkpoints, err := srv.listCheckpoints()
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "Checkpoints directory does not exist", http.StatusNotFound)
+			return
+		}
+		srv.log.Errorf("Error listing checkpoints: %v", err)
+		http.Error(w, "Failed to list checkpoints", http.StatusInternalServerError)
+		return
+	}
+
+	latest, err := selectLatestCheckpoint(checkpoints, by, jobID)
+	if err != nil {
+		http.Error(w, "No matching checkpoint found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(latest)
+}
+
+// provenanceManifest is the file written alongside a promoted checkpoint.
+const provenanceManifest = "ilab-provenance.json"
+
+// PromoteCheckpointRequest is used by the /checkpoints/{name}/promote endpoint.
+type PromoteCheckpointRequest struct {
+	Name string `json:"name"`           // Required: model name under ~/.cache/instructlab/models
+	Mode string `json:"mode,omitempty"` // Optional: "copy" (default) or "link" (hard links with copy fallback)
+}
+
+// ModelProvenance records where a promoted model came from.
+type ModelProvenance struct {
+	Model          string                 `json:"model"`
+	Checkpoint     string                 `json:"checkpoint"`
+	CheckpointPath string                 `json:"checkpoint_path"`
+	JobID          string                 `json:"job_id,omitempty"`
+	Branch         string                 `json:"branch,omitempty"`
+	CommitSHA      string                 `json:"commit_sha,omitempty"`
+	TaxonomyDirty  bool                   `json:"taxonomy_dirty,omitempty"`
+	DatasetFile    string                 `json:"dataset_file,omitempty"`
+	BaseModel      string                 `json:"base_model,omitempty"`
+	Samples        *int                   `json:"samples,omitempty"`
+	Epoch          *float64               `json:"epoch,omitempty"`
+	Evaluations    []CheckpointEvaluation `json:"evaluations,omitempty"`
+	PromotedAt     time.Time              `json:"promoted_at"`
+}
+
+// trainJobDatasetFile returns the --data-path a training job was started with, if any.
+func trainJobDatasetFile(job *Job) string {
+	for _, arg := range job.Args {
+		if strings.HasPrefix(arg, "--data-path=") {
+			return strings.TrimPrefix(arg, "--data-path=")
+		}
+	}
+	return ""
+}
+
+// resolveBranchCommit returns the commit a taxonomy branch currently points at.
+func (srv *ILabServer) resolveBranchCommit(branch string) (string, error) {
+	if branch == "" || strings.HasPrefix(branch, "-") {
+		return "", fmt.Errorf("invalid branch '%s'", branch)
+	}
+	out, err := srv.runTaxonomyGit("rev-parse", "--verify", "--quiet", branch+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve branch '%s': %v", branch, err)
+	}
+	return out, nil
+}
+
+// copyTree copies src into dst (which must not exist), hard-linking files when link is set
+// and falling back to a copy when linking fails (e.g. across filesystems).
+func copyTree(src, dst string, link bool) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0755)
+		case !d.Type().IsRegular():
+			// Checkpoints only hold regular files; skip symlinks and the like
+			return nil
+		}
+		if link {
+			if err := os.Link(path, target); err == nil {
+				return nil
+			}
+		}
+		return copyRegularFile(path, target)
+	})
+}
+
+// copyRegularFile copies a single file, preserving its permission bits.
+func copyRegularFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// promoteCheckpointHandler handles POST /checkpoints/{name}/promote.
+func (srv *ILabServer) promoteCheckpointHandler(w http.ResponseWriter, r *http.Request) {
+	checkpointName := mux.Vars(r)["name"]
+	srv.log.Infof("POST /checkpoints/%s/promote called", checkpointName)
+
+	var req PromoteCheckpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		srv.log.Errorf("Error decoding request body: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Mode != "" && req.Mode != "link" && req.Mode != "copy" {
+		http.Error(w, fmt.Sprintf("Invalid mode '%s' (expected 'link' or 'copy')", req.Mode), http.StatusBadRequest)
+		return
+	}
+
+	// The new model name must be valid and must not collide with an existing model
+	modelName := srv.normalizeModelName(req.Name)
+	switch _, _, err := srv.resolveModel(modelName); {
+	case err == nil:
+		http.Error(w, fmt.Sprintf("Model '%s' already exists", modelName), http.StatusConflict)
+		return
+	case !errors.Is(err, errModelNotFound):
+		srv.writeModelError(w, req.Name, err)
+		return
+	}
+	modelPath, err := getFullModelPath(modelName)
+	if err != nil {
+		srv.log.Errorf("Error resolving model path for '%s': %v", modelName, err)
+		http.Error(w, "Failed to resolve model path", http.StatusInternalServerError)
+		return
+	}
+
+	checkpoint, err := srv.findCheckpoint(checkpointName)
+	if errors.Is(err, errCheckpointNotFound) {
+		http.Error(w, fmt.Sprintf("Checkpoint '%s' does not exist", checkpointName), http.StatusNotFound)
+		return
+	} else if err != nil {
+		srv.log.Errorf("Error listing checkpoints: %v", err)
+		http.Error(w, "Failed to list checkpoints", http.StatusInternalServerError)
+		return
+	}
+
+	manifest := ModelProvenance{
+		Model:          modelName,
+		Checkpoint:     checkpoint.Name,
+		CheckpointPath: checkpoint.Path,
+		JobID:          checkpoint.JobID,
+		Branch:         checkpoint.Branch,
+		CommitSHA:      checkpoint.CommitSHA,
+		TaxonomyDirty:  checkpoint.TaxonomyDirty,
+		BaseModel:      checkpoint.BaseModel,
+		Samples:        checkpoint.Samples,
+		Epoch:          checkpoint.Epoch,
+		Evaluations:    checkpoint.Evaluations,
+		PromotedAt:     time.Now(),
+	}
+	if checkpoint.JobID != "" {
+		if job, err := srv.getJob(checkpoint.JobID); err == nil && job != nil {
+			manifest.DatasetFile = job.Dataset
+			if manifest.DatasetFile == "" {
+				manifest.DatasetFile = trainJobDatasetFile(job)
+			}
+		}
+	}
+	// Stage next to the destination and rename, so a failed promotion never leaves a partial
+	// model. Copy unless linking is asked for: a hard-linked model changes with the checkpoint
+	// when training rewrites its files in place.
+	if err := os.MkdirAll(filepath.Dir(modelPath), 0755); err != nil {
+		srv.log.Errorf("Error creating model directory: %v", err)
+		http.Error(w, "Failed to create model directory", http.StatusInternalServerError)
+		return
+	}
+	staging := filepath.Join(filepath.Dir(modelPath), fmt.Sprintf(".promote-%s-%d", filepath.Base(modelPath), time.Now().UnixNano()))
+	err = copyTree(checkpoint.Path, staging, req.Mode == "link")
+	if err == nil {
+		var data []byte
+		if data, err = json.MarshalIndent(manifest, "", "  "); err == nil {
+			err = os.WriteFile(filepath.Join(staging, provenanceManifest), data, 0644)
+		}
+	}
+	if err == nil {
+		err = os.Rename(staging, modelPath)
+	}
+	if err != nil {
+		os.RemoveAll(staging)
+		srv.log.Errorf("Error promoting checkpoint '%s' to model '%s': %v", checkpointName, modelName, err)
+		http.Error(w, fmt.Sprintf("Failed to promote checkpoint '%s'", checkpointName), http.StatusInternalServerError)
+		return
+	}
+	srv.log.Infof("Promoted checkpoint '%s' to model '%s' at %s", checkpointName, modelName, modelPath)
+
+	go srv.forceRefreshModelCache()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     "promoted",
+		"model":      modelName,
+		"path":       modelPath,
+		"provenance": manifest,
+	})
+}
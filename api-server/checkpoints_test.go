@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func floatPtr(f float64) *float64 { return &f }
+
+func TestRemainingEpochs(t *testing.T) {
+	srv := newTestServer(t)
+	job := &Job{
+		JobID:     "t-1",
+		Cmd:       "ilab",
+		Args:      []string{"model", "train", "--num-epochs=3"},
+		Status:    "finished",
+		StartTime: time.Now(),
+		TrainSpec: &TrainingSpec{NumEpochs: intPtr(3)},
+	}
+	if err := srv.createJob(job); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.storeMetricSamples(job.JobID, []metricSample{
+		{name: "samples_seen", step: 10, epoch: floatPtr(0), value: 900},
+		{name: "samples_seen", step: 11, epoch: floatPtr(0), value: 1000},
+		{name: "samples_seen", step: 20, epoch: floatPtr(1), value: 2000},
+		{name: "samples_seen", step: 30, epoch: floatPtr(2), value: 3000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		cp      CheckpointInfo
+		want    int
+		wantErr string
+	}{
+		{name: "epoch in checkpoint name", cp: CheckpointInfo{Name: "epoch_1", JobID: "t-1", Epoch: floatPtr(1)}, want: 2},
+		{name: "epoch from metrics", cp: CheckpointInfo{Name: "samples_1000", JobID: "t-1", Samples: intPtr(1000)}, want: 3},
+		{name: "epoch from later metrics", cp: CheckpointInfo{Name: "samples_1500", JobID: "t-1", Samples: intPtr(1500)}, want: 2},
+		{name: "samples beyond metrics", cp: CheckpointInfo{Name: "samples_5000", JobID: "t-1", Samples: intPtr(5000)}, wantErr: "epoch checkpoint 'samples_5000' was saved in is unknown"},
+		{name: "no samples", cp: CheckpointInfo{Name: "last", JobID: "t-1"}, wantErr: "pass 'epochs'"},
+		{name: "unknown job", cp: CheckpointInfo{Name: "samples_1000", Samples: intPtr(1000)}, wantErr: "epoch count of the job"},
+		{name: "after last epoch", cp: CheckpointInfo{Name: "epoch_3", JobID: "t-1", Epoch: floatPtr(3)}, wantErr: "after all 3 epochs"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := srv.remainingEpochs(&tc.cp)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("remainingEpochs = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrainJobBaseModel(t *testing.T) {
+	srv := newTestServer(t)
+	resumed := &Job{
+		JobID:            "t-2",
+		Cmd:              "ilab",
+		Args:             []string{"model", "train", "--model-path=/ckpt/jobs/t-1/hf_format/samples_1000"},
+		Status:           "finished",
+		StartTime:        time.Now(),
+		ResumeCheckpoint: "samples_1000",
+		BaseModel:        "granite-7b-starter",
+	}
+	if err := srv.createJob(resumed); err != nil {
+		t.Fatal(err)
+	}
+	job, err := srv.getJob(resumed.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := trainJobBaseModel(job); got != "granite-7b-starter" {
+		t.Errorf("resumed job base model = %q, want the recorded granite-7b-starter", got)
+	}
+
+	job.BaseModel = ""
+	if got := trainJobBaseModel(job); got != "" {
+		t.Errorf("resumed job without a record base model = %q, want none", got)
+	}
+
+	modelsDir, err := getModelsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := &Job{JobID: "t-3", Args: []string{"model", "train", "--model-path=" + filepath.Join(modelsDir, "granite-7b-lab")}}
+	if got := trainJobBaseModel(legacy); got != "granite-7b-lab" {
+		t.Errorf("legacy job base model = %q, want granite-7b-lab from --model-path", got)
+	}
+}
+
+func TestSelectBestCheckpoint(t *testing.T) {
+	srv := newTestServer(t)
+	var samples []metricSample
+	for step := 1; step <= 30; step++ {
+		// The loss bottoms out at step 20, when 2000 samples have been seen
+		loss := float64((step-20)*(step-20)) / 100
+		samples = append(samples,
+			metricSample{name: "samples_seen", step: step, epoch: floatPtr(0), value: float64(step * 100)},
+			metricSample{name: "loss", step: step, value: loss})
+	}
+	if err := srv.storeMetricSamples("t-1", samples); err != nil {
+		t.Fatal(err)
+	}
+	checkpoints := []CheckpointInfo{
+		{Name: "t-1-samples_1000", JobID: "t-1", Samples: intPtr(1000)},
+		{Name: "t-1-samples_2000", JobID: "t-1", Samples: intPtr(2000)},
+		{Name: "t-1-samples_3000", JobID: "t-1", Samples: intPtr(3000)},
+		{Name: "t-1-last", JobID: "t-1"},
+		{Name: "t-2-samples_1000", JobID: "t-2", Samples: intPtr(1000), Evaluations: []CheckpointEvaluation{
+			{Evaluator: "qna-eval", Scores: map[string]float64{"accuracy": 1}},
+		}},
+	}
+
+	best, err := srv.selectBestCheckpoint(checkpoints, "t-1")
+	if err != nil || best.Name != "t-1-samples_2000" {
+		t.Errorf("by loss: %+v, %v, want t-1-samples_2000", best, err)
+	}
+
+	checkpoints[0].Evaluations = []CheckpointEvaluation{
+		{Evaluator: "qna-eval", Scores: map[string]float64{"accuracy": 0.9}},
+		{Evaluator: "qna-eval", Result: "not a score"},
+	}
+	checkpoints[2].Evaluations = []CheckpointEvaluation{
+		{Evaluator: "qna-eval", Scores: map[string]float64{"accuracy": 0.95}},
+		{Evaluator: "qna-eval", Scores: map[string]float64{"accuracy": 0.7, "f1": 0.8}},
+	}
+	best, err = srv.selectBestCheckpoint(checkpoints, "t-1")
+	if err != nil || best.Name != "t-1-samples_1000" {
+		t.Errorf("by score: %+v, %v, want t-1-samples_1000", best, err)
+	}
+
+	if _, err := srv.selectBestCheckpoint(checkpoints[4:], "t-3"); !errors.Is(err, errNoCheckpoints) {
+		t.Errorf("no checkpoints: %v, want errNoCheckpoints", err)
+	}
+	unmeasured := []CheckpointInfo{{Name: "t-3-samples_1000", JobID: "t-3", Samples: intPtr(1000)}}
+	if _, err := srv.selectBestCheckpoint(unmeasured, "t-3"); err == nil || !strings.Contains(err.Error(), "evaluation score or a training loss") {
+		t.Errorf("unmeasured checkpoints: %v", err)
+	}
+}
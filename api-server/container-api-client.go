@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// unixAPIClient is a small JSON-over-HTTP client for container engine APIs served on a unix socket.
+type unixAPIClient struct {
+	name       string // engine name used in error messages
+	socketPath string
+	prefix     string // API version prefix, e.g. "/v1.41"
+	client     *http.Client
+}
+
+func newUnixAPIClient(name, socketPath, prefix string) *unixAPIClient {
+	return &unixAPIClient{
+		name:       name,
+		socketPath: socketPath,
+		prefix:     prefix,
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// do issues a request and decodes a JSON response into out (if non-nil).
+func (c *unixAPIClient) do(method, path string, body interface{}, out interface{}) error {
+	resp, err := c.stream(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response for %s %s: %v", c.name, method, path, err)
+	}
+	return nil
+}
+
+// stream issues a request and returns the raw response; callers must close the body.
+func (c *unixAPIClient) stream(method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, "http://"+c.name+c.prefix+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s request %s %s failed: %v", c.name, method, path, err)
+	}
+	// Engines answer 304 Not Modified to a start or stop of a container already in that state
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(resp.Body)
+		err := fmt.Errorf("%s request %s %s returned %d: %s", c.name, method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+		if resp.StatusCode == http.StatusNotFound && strings.HasPrefix(path, "/containers/") {
+			return nil, fmt.Errorf("%w: %v", errContainerNotFound, err)
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// demuxLogStream splits the multiplexed log stream Docker and podman return for non-TTY containers.
+func demuxLogStream(r io.Reader, stdout, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		dst := stdout
+		if header[0] == 2 {
+			dst = stderr
+		}
+		if _, err := io.CopyN(dst, r, size); err != nil {
+			return err
+		}
+	}
+}
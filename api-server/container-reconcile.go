@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Labels applied to every container the server launches.
+const (
+	labelManagedBy       = "ilab.instructlab.ai/managed-by"
+	labelServerID        = "ilab.instructlab.ai/server-id"
+	labelJobID           = "ilab.instructlab.ai/job-id"
+	labelRole            = "ilab.instructlab.ai/role"
+	labelServedModelName = "ilab.instructlab.ai/served-model-name"
+	labelModelPath       = "ilab.instructlab.ai/model-path"
+
+	managedByValue = "ilab-api-server"
+	roleVllm       = "vllm"
+	roleQnaEval    = "qna-eval"
+)
+
+// adoptedContainerPollInterval is how often an adopted container is checked for an exit.
+var adoptedContainerPollInterval = 10 * time.Second
+
+// containerLabels returns the labels identifying a container owned by this server instance.
+func (srv *ILabServer) containerLabels(role string) map[string]string {
+	return map[string]string{
+		labelManagedBy: managedByValue,
+		labelServerID:  srv.serverID,
+		labelRole:      role,
+	}
+}
+
+// ownedContainerFilter selects containers launched by this server instance for the given role.
+func (srv *ILabServer) ownedContainerFilter(role string) ContainerFilter {
+	return ContainerFilter{Labels: srv.containerLabels(role)}
+}
+
+// reconcileContainers matches the containers that are actually running against the
+// jobs table after a restart. Live containers whose job is known are adopted and running
+// serving jobs without a container are marked failed. Leftover containers of this server
+// whose job is no longer running are reported (and stopped when --reap-unowned-containers
+// is set); containers of other server instances on the host are only reported.
+func (srv *ILabServer) reconcileContainers() {
+	srv.log.Info("Reconciling containers against the jobs table...")
+
+	containers, err := srv.containerRuntime.List(ContainerFilter{Labels: map[string]string{labelManagedBy: managedByValue}})
+	if err != nil {
+		srv.log.Errorf("Error listing managed containers: %v", err)
+		return
+	}
+
+	rows, err := srv.db.Query(`
+        SELECT job_id
+        FROM jobs
+        WHERE job_id LIKE 'v-%' AND served_model_name != '' AND status = 'running'
+    `)
+	if err != nil {
+		srv.log.Errorf("Error querying running serving jobs: %v", err)
+		return
+	}
+	runningJobs := make(map[string]bool)
+	for rows.Next() {
+		var jobID string
+		if err := rows.Scan(&jobID); err != nil {
+			srv.log.Errorf("Error scanning row: %v", err)
+			continue
+		}
+		runningJobs[jobID] = true
+	}
+	rows.Close()
+
+	adopted := make(map[string]bool)
+	for _, c := range containers {
+		jobID := c.Labels[labelJobID]
+		if c.Labels[labelServerID] != srv.serverID {
+			srv.log.Infof("Ignoring container %s of another server (server_id=%s, job_id=%s)", c.ID, c.Labels[labelServerID], jobID)
+			continue
+		}
+		if c.Labels[labelRole] != roleVllm || !runningJobs[jobID] {
+			if srv.reapUnowned {
+				srv.log.Warnf("Stopping unowned container %s (server_id=%s, job_id=%s)", c.ID, c.Labels[labelServerID], jobID)
+				if err := srv.containerRuntime.Stop(c.ID); err != nil {
+					srv.log.Errorf("Error stopping unowned container %s: %v", c.ID, err)
+				}
+			} else {
+				srv.log.Warnf("Ignoring unowned container %s (server_id=%s, job_id=%s)", c.ID, c.Labels[labelServerID], jobID)
+			}
+			continue
+		}
+
+		job, err := srv.getJob(jobID)
+		if err != nil || job == nil {
+			srv.log.Errorf("Unable to load job %s for container %s: %v", jobID, c.ID, err)
+			continue
+		}
+		if c.PID != 0 {
+			job.PID = c.PID
+			_ = srv.updateJob(job)
+		}
+
+		srv.jobIDsMutex.Lock()
+		srv.servedModelJobIDs[job.ServedModelName] = job.JobID
+		srv.jobIDsMutex.Unlock()
+		adopted[jobID] = true
+		srv.log.Infof("Adopted container %s for model '%s' (job_id=%s)", c.ID, job.ServedModelName, job.JobID)
+
+		go srv.watchAdoptedContainer(job, c.ID)
+	}
+
+	for jobID := range runningJobs {
+		if adopted[jobID] {
+			continue
+		}
+		job, err := srv.getJob(jobID)
+		if err != nil || job == nil {
+			srv.log.Errorf("Unable to load job %s to mark as failed: %v", jobID, err)
+			continue
+		}
+		now := time.Now()
+		job.Status = "failed"
+		job.EndTime = &now
+		if err := srv.updateJob(job); err != nil {
+			srv.log.Errorf("Error marking job %s as failed: %v", jobID, err)
+			continue
+		}
+		srv.log.Infof("Job %s marked as failed (container missing)", jobID)
+	}
+
+	srv.log.Infof("Container reconciliation completed: %d adopted.", len(adopted))
+}
+
+// watchAdoptedContainer polls a container adopted after a restart and ends its job once
+// the container has stopped: failed on a non-zero exit code, finished otherwise. A container
+// that was removed before its exit code could be read is recorded as failed. Inspect errors
+// other than the container not being found are retried.
+func (srv *ILabServer) watchAdoptedContainer(job *Job, containerID string) {
+	for {
+		time.Sleep(adoptedContainerPollInterval)
+		info, err := srv.containerRuntime.Inspect(containerID)
+		if err != nil && !errors.Is(err, errContainerNotFound) {
+			// The runtime may be restarting; only a container that is gone ends the job
+			srv.log.Warnf("Failed to inspect adopted container %s for job '%s': %v", containerID, job.JobID, err)
+			continue
+		}
+		if err == nil && isContainerRunning(info.Status) {
+			continue
+		}
+
+		job.Lock.Lock()
+		// The event stream may already have recorded the exit.
+		if current, errGet := srv.getJob(job.JobID); errGet == nil && current != nil && current.Status != "running" {
+			job.Status = current.Status
+			job.EndTime = current.EndTime
+		} else {
+			job.Status = "finished"
+			if err != nil || info.ExitCode != 0 {
+				job.Status = "failed"
+			}
+			now := time.Now()
+			job.EndTime = &now
+			if errDB := srv.updateJob(job); errDB != nil {
+				srv.log.Errorf("Failed to update DB for job '%s': %v", job.JobID, errDB)
+			}
+		}
+		job.Lock.Unlock()
+
+		srv.jobIDsMutex.Lock()
+		if srv.servedModelJobIDs[job.ServedModelName] == job.JobID {
+			delete(srv.servedModelJobIDs, job.ServedModelName)
+		}
+		srv.jobIDsMutex.Unlock()
+		srv.log.Infof("Adopted container %s for job '%s' is gone", containerID, job.JobID)
+		return
+	}
+}
+
+// isContainerRunning interprets the status strings reported by the different runtimes.
+func isContainerRunning(status string) bool {
+	switch status {
+	case "running", "Running", "Pending", "created", "configured":
+		return true
+	}
+	// "podman ps" / "docker ps" report human readable statuses such as "Up 3 minutes".
+	return strings.HasPrefix(status, "Up")
+}
+
+// watchContainerEvents follows the runtime's event stream for this server's containers and
+// records exits on the owning job, reconnecting if the stream drops.
+func (srv *ILabServer) watchContainerEvents(source ContainerEventSource) {
+	filter := ContainerFilter{Labels: map[string]string{labelManagedBy: managedByValue, labelServerID: srv.serverID}}
+	for {
+		err := source.Events(filter, srv.handleContainerEvent)
+		srv.log.Warnf("Container event stream ended: %v; reconnecting in 10s", err)
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// handleContainerEvent updates the job that owns a container when the container exits.
+func (srv *ILabServer) handleContainerEvent(event ContainerEvent) {
+	jobID := event.Labels[labelJobID]
+	srv.log.Debugf("Container event %s for container %s (job_id=%s)", event.Action, event.ContainerID, jobID)
+	if jobID == "" || event.Action != "died" {
+		return
+	}
+
+	job, err := srv.getJob(jobID)
+	if err != nil || job == nil {
+		srv.log.Warnf("Container event for unknown job %s: %v", jobID, err)
+		return
+	}
+	if job.Status != "running" {
+		return
+	}
+
+	if event.ExitCode == 0 {
+		job.Status = "finished"
+	} else {
+		job.Status = "failed"
+	}
+	endTime := event.Time
+	job.EndTime = &endTime
+	if err := srv.updateJob(job); err != nil {
+		srv.log.Errorf("Failed to update DB for job '%s': %v", job.JobID, err)
+		return
+	}
+
+	srv.jobIDsMutex.Lock()
+	if srv.servedModelJobIDs[job.ServedModelName] == job.JobID {
+		delete(srv.servedModelJobIDs, job.ServedModelName)
+	}
+	srv.jobIDsMutex.Unlock()
+	srv.log.Infof("Container %s for job '%s' exited with code %d", event.ContainerID, job.JobID, event.ExitCode)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// inspectResult is one answer of scriptedInspectRuntime.Inspect.
+type inspectResult struct {
+	info *ContainerInfo
+	err  error
+}
+
+// scriptedInspectRuntime answers Inspect from a script, repeating its last entry.
+type scriptedInspectRuntime struct {
+	*fakeRuntime
+	mu      sync.Mutex
+	results []inspectResult
+}
+
+func (rt *scriptedInspectRuntime) Inspect(id string) (*ContainerInfo, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	r := rt.results[0]
+	if len(rt.results) > 1 {
+		rt.results = rt.results[1:]
+	}
+	return r.info, r.err
+}
+
+func TestWatchAdoptedContainer(t *testing.T) {
+	interval := adoptedContainerPollInterval
+	adoptedContainerPollInterval = time.Millisecond
+	t.Cleanup(func() { adoptedContainerPollInterval = interval })
+
+	running := inspectResult{info: &ContainerInfo{Status: "running"}}
+	unreachable := inspectResult{err: errors.New("podman request GET /containers/c1/json failed: connection refused")}
+	for _, tc := range []struct {
+		name    string
+		results []inspectResult
+		want    string
+	}{
+		{"exited cleanly", []inspectResult{running, {info: &ContainerInfo{Status: "exited"}}}, "finished"},
+		{"exited with an error", []inspectResult{running, {info: &ContainerInfo{Status: "exited", ExitCode: 137}}}, "failed"},
+		{"removed", []inspectResult{running, {err: fmt.Errorf("%w: c1", errContainerNotFound)}}, "failed"},
+		{"runtime unreachable", []inspectResult{unreachable, unreachable, running, {info: &ContainerInfo{Status: "exited"}}}, "finished"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newTestServer(t)
+			srv.containerRuntime = &scriptedInspectRuntime{fakeRuntime: newFakeRuntime(), results: tc.results}
+			job := &Job{JobID: "v-1", Cmd: "podman", Status: "running", StartTime: time.Now(), ServedModelName: "granite"}
+			if err := srv.createJob(job); err != nil {
+				t.Fatal(err)
+			}
+			srv.servedModelJobIDs["granite"] = job.JobID
+
+			srv.watchAdoptedContainer(job, "c1")
+
+			got, err := srv.getJob(job.JobID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tc.want || got.EndTime == nil {
+				t.Errorf("status = %s, end time %v, want %s", got.Status, got.EndTime, tc.want)
+			}
+			if _, ok := srv.servedModelJobIDs["granite"]; ok {
+				t.Error("the served model still points at the ended job")
+			}
+		})
+	}
+}
+
+func TestInspectMissingContainerIsNotFound(t *testing.T) {
+	rt := startDockerStub(t, &dockerStub{inspectErr: map[string]bool{"gone": true}})
+	if _, err := rt.Inspect("gone"); !errors.Is(err, errContainerNotFound) {
+		t.Errorf("docker Inspect error = %v, want errContainerNotFound", err)
+	}
+	if _, err := newFakeRuntime().Inspect("gone"); !errors.Is(err, errContainerNotFound) {
+		t.Errorf("fake Inspect error = %v, want errContainerNotFound", err)
+	}
+}
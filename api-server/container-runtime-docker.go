@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// dockerAPIVersion is the Docker Engine API version the server speaks.
+const dockerAPIVersion = "v1.41"
+
+// dockerRuntime drives containers through the Docker Engine REST API on a unix socket.
+type dockerRuntime struct {
+	api *unixAPIClient
+	log *zap.SugaredLogger
+}
+
+// dockerProcess tracks a container started through the Engine API.
+type dockerProcess struct {
+	id   string
+	pid  int
+	done chan struct{}
+	err  error
+}
+
+func (p *dockerProcess) ID() string { return p.id }
+func (p *dockerProcess) PID() int   { return p.pid }
+func (p *dockerProcess) Wait() error {
+	<-p.done
+	return p.err
+}
+
+func newDockerRuntime(socketPath string, log *zap.SugaredLogger) *dockerRuntime {
+	if socketPath == "" {
+		socketPath = "/var/run/docker.sock"
+	}
+	return &dockerRuntime{api: newUnixAPIClient("docker", socketPath, "/"+dockerAPIVersion), log: log}
+}
+
+func (rt *dockerRuntime) Name() string {
+	return "docker"
+}
+
+func (rt *dockerRuntime) Start(spec ContainerSpec, stdout, stderr io.Writer) (ContainerProcess, error) {
+	shmSize, err := parseByteSize(spec.ShmSize)
+	if err != nil {
+		return nil, err
+	}
+
+	hostConfig := map[string]interface{}{
+		"AutoRemove":  spec.Remove,
+		"SecurityOpt": spec.SecurityOpt,
+	}
+	if spec.HostNetwork {
+		hostConfig["NetworkMode"] = "host"
+	}
+	if shmSize > 0 {
+		hostConfig["ShmSize"] = shmSize
+	}
+	if spec.PidsLimit != 0 {
+		hostConfig["PidsLimit"] = spec.PidsLimit
+	}
+	var binds []string
+	for _, v := range spec.Volumes {
+		binds = append(binds, fmt.Sprintf("%s:%s", v.HostPath, v.ContainerPath))
+	}
+	hostConfig["Binds"] = binds
+	if len(spec.GPUs) > 0 {
+		request := map[string]interface{}{
+			"Driver":       "nvidia",
+			"Capabilities": [][]string{{"gpu"}},
+		}
+		if len(spec.GPUs) == 1 && spec.GPUs[0] == "all" {
+			request["Count"] = -1
+		} else {
+			request["DeviceIDs"] = spec.GPUs
+		}
+		hostConfig["DeviceRequests"] = []interface{}{request}
+	}
+
+	createBody := map[string]interface{}{
+		"Image":      spec.Image,
+		"Cmd":        spec.Args,
+		"Labels":     spec.Labels,
+		"HostConfig": hostConfig,
+	}
+	if spec.Entrypoint != "" {
+		createBody["Entrypoint"] = []string{spec.Entrypoint}
+	}
+
+	path := "/containers/create"
+	if spec.Name != "" {
+		path += "?name=" + url.QueryEscape(spec.Name)
+	}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := rt.api.do(http.MethodPost, path, createBody, &created); err != nil {
+		return nil, err
+	}
+	proc := &dockerProcess{id: created.ID, done: make(chan struct{})}
+
+	// AutoRemove deletes the container as soon as it exits, so the wait for its exit code
+	// is issued before it starts: a "removed" wait returns the code once it is deleted.
+	waitCondition := "not-running"
+	if spec.Remove {
+		waitCondition = "removed"
+	}
+	waited := make(chan struct{})
+	wait := func() {
+		defer close(waited)
+		var result struct {
+			StatusCode int `json:"StatusCode"`
+		}
+		if err := rt.api.do(http.MethodPost, "/containers/"+created.ID+"/wait?condition="+waitCondition, nil, &result); err != nil {
+			proc.err = err
+			return
+		}
+		if result.StatusCode != 0 {
+			proc.err = fmt.Errorf("container %s exited with status %d", created.ID, result.StatusCode)
+		}
+	}
+	if spec.Remove {
+		go wait()
+	}
+	if err := rt.api.do(http.MethodPost, "/containers/"+created.ID+"/start", nil, nil); err != nil {
+		// Removing the container that never started also ends the pending wait
+		_ = rt.api.do(http.MethodDelete, "/containers/"+created.ID+"?force=true", nil, nil)
+		return nil, err
+	}
+	if !spec.Remove {
+		go wait()
+	}
+
+	if info, err := rt.Inspect(created.ID); err == nil {
+		proc.pid = info.PID
+	}
+
+	go func() {
+		defer close(proc.done)
+		if resp, err := rt.api.stream(http.MethodGet, "/containers/"+created.ID+"/logs?follow=true&stdout=true&stderr=true", nil); err == nil {
+			_ = demuxLogStream(resp.Body, stdout, stderr)
+			resp.Body.Close()
+		}
+		<-waited
+	}()
+
+	return proc, nil
+}
+
+// dockerContainerSummary is an entry of GET /containers/json.
+type dockerContainerSummary struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	Command string            `json:"Command"`
+	Created int64             `json:"Created"`
+	Status  string            `json:"Status"`
+	Labels  map[string]string `json:"Labels"`
+	Ports   []struct {
+		PrivatePort int    `json:"PrivatePort"`
+		PublicPort  int    `json:"PublicPort"`
+		Type        string `json:"Type"`
+	} `json:"Ports"`
+}
+
+func (rt *dockerRuntime) List(filter ContainerFilter) ([]ContainerInfo, error) {
+	filters := map[string][]string{}
+	if filter.Image != "" {
+		filters["ancestor"] = []string{filter.Image}
+	}
+	for k, v := range filter.Labels {
+		filters["label"] = append(filters["label"], fmt.Sprintf("%s=%s", k, v))
+	}
+	filterJSON, err := json.Marshal(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []dockerContainerSummary
+	if err := rt.api.do(http.MethodGet, "/containers/json?filters="+url.QueryEscape(string(filterJSON)), nil, &summaries); err != nil {
+		return nil, err
+	}
+
+	var containers []ContainerInfo
+	for _, s := range summaries {
+		details, err := rt.Inspect(s.ID)
+		if err != nil {
+			// The container may have been removed since it was listed
+			rt.log.Warnf("Skipping container %s: %v", s.ID, err)
+			continue
+		}
+		var ports []string
+		for _, p := range s.Ports {
+			ports = append(ports, fmt.Sprintf("%d->%d/%s", p.PublicPort, p.PrivatePort, p.Type))
+		}
+		details.Command = s.Command
+		details.CreatedAt = time.Unix(s.Created, 0).Format(time.RFC3339)
+		details.Status = s.Status
+		details.Ports = strings.Join(ports, ", ")
+		containers = append(containers, *details)
+	}
+	return containers, nil
+}
+
+func (rt *dockerRuntime) Inspect(id string) (*ContainerInfo, error) {
+	var r struct {
+		ID      string `json:"Id"`
+		Name    string `json:"Name"`
+		Created string `json:"Created"`
+		State   struct {
+			Status   string `json:"Status"`
+			Pid      int    `json:"Pid"`
+			ExitCode int    `json:"ExitCode"`
+		} `json:"State"`
+		Config struct {
+			Image  string            `json:"Image"`
+			Cmd    []string          `json:"Cmd"`
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+	}
+	if err := rt.api.do(http.MethodGet, "/containers/"+url.PathEscape(id)+"/json", nil, &r); err != nil {
+		return nil, err
+	}
+	return &ContainerInfo{
+		ID:        r.ID,
+		Name:      strings.TrimPrefix(r.Name, "/"),
+		Image:     r.Config.Image,
+		Command:   strings.Join(r.Config.Cmd, " "),
+		Args:      r.Config.Cmd,
+		CreatedAt: r.Created,
+		Status:    r.State.Status,
+		PID:       r.State.Pid,
+		ExitCode:  r.State.ExitCode,
+		Labels:    r.Config.Labels,
+	}, nil
+}
+
+func (rt *dockerRuntime) Stop(id string) error {
+	return rt.api.do(http.MethodPost, "/containers/"+url.PathEscape(id)+"/stop", nil, nil)
+}
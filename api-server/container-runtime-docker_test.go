@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// dockerStub is a Docker Engine API stub served on a unix socket.
+type dockerStub struct {
+	mu         sync.Mutex
+	exitCode   int
+	failStart  bool
+	calls      []string
+	inspectErr map[string]bool
+	started    chan struct{}
+}
+
+func (s *dockerStub) record(call string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+}
+
+// startDockerStub serves a stub Engine API and returns a runtime pointed at it.
+func startDockerStub(t *testing.T, stub *dockerStub) *dockerRuntime {
+	t.Helper()
+	// Unix socket paths are limited to ~108 bytes, which t.TempDir can exceed.
+	dir, err := os.MkdirTemp("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socketPath := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stub.started = make(chan struct{})
+
+	prefix := "/" + dockerAPIVersion
+	mux := http.NewServeMux()
+	mux.HandleFunc(prefix+"/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"Id": "abc123", "Names": []string{"/vllm-v-1"}, "Command": "serve", "Created": 1704067200, "Status": "Up 1 minute"},
+			{"Id": "gone", "Names": []string{"/vllm-v-2"}, "Command": "serve", "Created": 1704067200, "Status": "Exited"},
+		})
+	})
+	mux.HandleFunc(prefix+"/containers/create", func(w http.ResponseWriter, r *http.Request) {
+		stub.record("create")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"Id": "new1"})
+	})
+	mux.HandleFunc(prefix+"/containers/", func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, prefix+"/containers/")
+		id, action, _ := strings.Cut(rest, "/")
+		switch {
+		case r.Method == http.MethodDelete:
+			stub.record("delete")
+			close(stub.started)
+			w.WriteHeader(http.StatusNoContent)
+		case action == "start":
+			stub.record("start")
+			if stub.failStart {
+				http.Error(w, "no such image", http.StatusInternalServerError)
+				return
+			}
+			close(stub.started)
+			w.WriteHeader(http.StatusNoContent)
+		case action == "stop":
+			// The container is already stopped
+			stub.record("stop")
+			w.WriteHeader(http.StatusNotModified)
+		case action == "logs":
+			<-stub.started
+			_, _ = w.Write(muxFrame(1, "loading model\n"))
+		case action == "wait":
+			stub.record("wait?" + r.URL.RawQuery)
+			<-stub.started
+			stub.mu.Lock()
+			code := stub.exitCode
+			stub.mu.Unlock()
+			_ = json.NewEncoder(w).Encode(map[string]int{"StatusCode": code})
+		case action == "json":
+			if stub.inspectErr[id] {
+				http.Error(w, "no such container", http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"Id":     id,
+				"Name":   "/vllm-" + id,
+				"State":  map[string]interface{}{"Status": "running", "Pid": 42},
+				"Config": map[string]interface{}{"Image": "vllm:latest", "Cmd": []string{"serve"}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	server := httptest.NewUnstartedServer(mux)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return newDockerRuntime(socketPath, zap.NewNop().Sugar())
+}
+
+func TestDockerStart(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		remove    bool
+		exitCode  int
+		wantErr   bool
+		wantCalls []string
+	}{
+		{"kept", false, 0, false, []string{"create", "start", "wait?condition=not-running"}},
+		{"auto removed", true, 0, false, []string{"create", "start", "wait?condition=removed"}},
+		{"auto removed failure", true, 1, true, []string{"create", "start", "wait?condition=removed"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := &dockerStub{exitCode: tc.exitCode}
+			rt := startDockerStub(t, stub)
+
+			var stdout bytes.Buffer
+			proc, err := rt.Start(ContainerSpec{Name: "vllm-v-1", Image: "vllm:latest", Remove: tc.remove}, &stdout, &bytes.Buffer{})
+			if err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			err = proc.Wait()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Wait error = %v, want error %t", err, tc.wantErr)
+			}
+			if proc.PID() != 42 || stdout.String() != "loading model\n" {
+				t.Errorf("PID = %d, stdout = %q", proc.PID(), stdout.String())
+			}
+
+			stub.mu.Lock()
+			defer stub.mu.Unlock()
+			// The wait of an auto-removed container races its start
+			sort.Strings(stub.calls)
+			if strings.Join(stub.calls, " ") != strings.Join(tc.wantCalls, " ") {
+				t.Errorf("calls = %v, want %v", stub.calls, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestDockerStartFailureRemovesContainer(t *testing.T) {
+	stub := &dockerStub{failStart: true}
+	rt := startDockerStub(t, stub)
+
+	if _, err := rt.Start(ContainerSpec{Image: "vllm:latest", Remove: true}, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Fatal("Start succeeded")
+	}
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if !slices.Contains(stub.calls, "delete") {
+		t.Errorf("calls = %v, want the container deleted", stub.calls)
+	}
+}
+
+func TestDockerStopAlreadyStopped(t *testing.T) {
+	rt := startDockerStub(t, &dockerStub{})
+
+	if err := rt.Stop("abc123"); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+}
+
+func TestDockerListSkipsVanishedContainers(t *testing.T) {
+	rt := startDockerStub(t, &dockerStub{inspectErr: map[string]bool{"gone": true}})
+
+	containers, err := rt.List(ContainerFilter{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(containers) != 1 || containers[0].ID != "abc123" || containers[0].Status != "Up 1 minute" {
+		t.Errorf("containers = %+v", containers)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeRuntime is an in-memory ContainerRuntime. Containers "run" until they are stopped,
+// which lets the serving endpoints be exercised on machines without a container engine.
+type fakeRuntime struct {
+	mu         sync.Mutex
+	seq        int
+	containers map[string]*fakeContainer
+}
+
+// fakeContainer is a container tracked by fakeRuntime.
+type fakeContainer struct {
+	info    ContainerInfo
+	stopped chan struct{}
+	once    sync.Once
+}
+
+func (c *fakeContainer) ID() string { return c.info.ID }
+func (c *fakeContainer) PID() int   { return 0 }
+func (c *fakeContainer) Wait() error {
+	<-c.stopped
+	return nil
+}
+
+func newFakeRuntime() *fakeRuntime {
+	return &fakeRuntime{containers: make(map[string]*fakeContainer)}
+}
+
+func (rt *fakeRuntime) Name() string {
+	return "fake"
+}
+
+func (rt *fakeRuntime) Start(spec ContainerSpec, stdout, stderr io.Writer) (ContainerProcess, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.seq++
+	id := fmt.Sprintf("fake-%d", rt.seq)
+	labels := make(map[string]string, len(spec.Labels))
+	for k, v := range spec.Labels {
+		labels[k] = v
+	}
+	c := &fakeContainer{
+		info: ContainerInfo{
+			ID:        id,
+			Name:      spec.Name,
+			Image:     spec.Image,
+			Command:   strings.Join(spec.Args, " "),
+			Args:      append([]string{}, spec.Args...),
+			CreatedAt: time.Now().Format(time.RFC3339),
+			Status:    "running",
+			Labels:    labels,
+		},
+		stopped: make(chan struct{}),
+	}
+	rt.containers[id] = c
+	fmt.Fprintf(stdout, "fake container %s started from %s\n", id, spec.Image)
+	return c, nil
+}
+
+func (rt *fakeRuntime) List(filter ContainerFilter) ([]ContainerInfo, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	var containers []ContainerInfo
+	for _, c := range rt.containers {
+		if matchesFilter(c.info, filter) {
+			containers = append(containers, c.info)
+		}
+	}
+	return containers, nil
+}
+
+func (rt *fakeRuntime) Inspect(id string) (*ContainerInfo, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	c, err := rt.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	info := c.info
+	return &info, nil
+}
+
+func (rt *fakeRuntime) Stop(id string) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	c, err := rt.lookup(id)
+	if err != nil {
+		return err
+	}
+	c.once.Do(func() { close(c.stopped) })
+	delete(rt.containers, c.info.ID)
+	return nil
+}
+
+// lookup finds a container by ID or name; callers must hold rt.mu.
+func (rt *fakeRuntime) lookup(id string) (*fakeContainer, error) {
+	if c, ok := rt.containers[id]; ok {
+		return c, nil
+	}
+	for _, c := range rt.containers {
+		if c.info.Name == id {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", errContainerNotFound, id)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFakeRuntime(t *testing.T) {
+	rt := newFakeRuntime()
+
+	var stdout bytes.Buffer
+	proc, err := rt.Start(ContainerSpec{
+		Name:   "vllm-v-1",
+		Image:  "vllm:latest",
+		Args:   []string{"serve", "/models/granite"},
+		Labels: map[string]string{labelRole: roleVllm, labelJobID: "v-1"},
+	}, &stdout, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if !strings.Contains(stdout.String(), proc.ID()) {
+		t.Errorf("stdout = %q, want the container ID", stdout.String())
+	}
+	if _, err := rt.Start(ContainerSpec{Name: "other", Image: "busybox"}, &bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		filter ContainerFilter
+		want   int
+	}{
+		{"all", ContainerFilter{}, 2},
+		{"image", ContainerFilter{Image: "vllm:latest"}, 1},
+		{"label", ContainerFilter{Labels: map[string]string{labelJobID: "v-1"}}, 1},
+		{"label mismatch", ContainerFilter{Labels: map[string]string{labelJobID: "v-2"}}, 0},
+	} {
+		containers, err := rt.List(tc.filter)
+		if err != nil {
+			t.Fatalf("List %s: %v", tc.name, err)
+		}
+		if len(containers) != tc.want {
+			t.Errorf("List %s: got %d containers, want %d", tc.name, len(containers), tc.want)
+		}
+	}
+
+	// Containers are found by ID or name
+	info, err := rt.Inspect("vllm-v-1")
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if info.ID != proc.ID() || info.Status != "running" || strings.Join(info.Args, " ") != "serve /models/granite" {
+		t.Errorf("unexpected info %+v", info)
+	}
+
+	waited := make(chan error)
+	go func() { waited <- proc.Wait() }()
+	select {
+	case err := <-waited:
+		t.Fatalf("Wait returned %v before the container was stopped", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	if err := rt.Stop(proc.ID()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err := <-waited; err != nil {
+		t.Errorf("Wait: %v", err)
+	}
+	if _, err := rt.Inspect(proc.ID()); err == nil {
+		t.Error("Inspect succeeded after Stop")
+	}
+	if err := rt.Stop(proc.ID()); err == nil {
+		t.Error("second Stop succeeded")
+	}
+}
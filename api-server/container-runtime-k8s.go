@@ -0,0 +1,464 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serviceAccountDir is where Kubernetes mounts the in-cluster credentials.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// podStartFailures are the waiting and scheduling reasons that keep a Pod Pending for good.
+var podStartFailures = map[string]bool{
+	"ErrImagePull":     true,
+	"ImagePullBackOff": true,
+	"InvalidImageName": true,
+	"Unschedulable":    true,
+}
+
+// kubernetesRuntime runs each container as a standalone Pod through the Kubernetes API,
+// using the in-cluster service account of the api-server.
+type kubernetesRuntime struct {
+	apiURL    string
+	namespace string
+	token     string
+	client    *http.Client
+
+	// pendingTimeout bounds how long a Pod may stay Pending before its process fails
+	pendingTimeout time.Duration
+	pollInterval   time.Duration
+
+	mu    sync.Mutex
+	procs map[string]*kubernetesProcess // running processes by Pod name
+}
+
+// kubernetesProcess tracks a Pod started by kubernetesRuntime.
+type kubernetesProcess struct {
+	name    string
+	done    chan struct{}
+	err     error
+	stopped bool // set by Stop; the Pod disappearing afterwards is a normal stop
+}
+
+func (p *kubernetesProcess) ID() string { return p.name }
+func (p *kubernetesProcess) PID() int   { return 0 }
+func (p *kubernetesProcess) Wait() error {
+	<-p.done
+	return p.err
+}
+
+// newKubernetesRuntime builds a runtime from the in-cluster configuration.
+func newKubernetesRuntime(namespace string, pendingTimeout time.Duration) (*kubernetesRuntime, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("kubernetes runtime requires running in-cluster (KUBERNETES_SERVICE_HOST/PORT not set)")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %v", err)
+	}
+	caCert, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("failed to parse service account CA")
+	}
+	if namespace == "" {
+		ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service account namespace: %v", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+	return &kubernetesRuntime{
+		apiURL:    fmt.Sprintf("https://%s:%s", host, port),
+		namespace: namespace,
+		token:     strings.TrimSpace(string(token)),
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+		pendingTimeout: pendingTimeout,
+		pollInterval:   2 * time.Second,
+		procs:          make(map[string]*kubernetesProcess),
+	}, nil
+}
+
+func (rt *kubernetesRuntime) Name() string {
+	return "kubernetes"
+}
+
+// stream issues a request against the API server; callers must close the body.
+func (rt *kubernetesRuntime) stream(method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, rt.apiURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+rt.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := rt.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes request %s %s failed: %v", method, path, err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(resp.Body)
+		err := fmt.Errorf("kubernetes request %s %s returned %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+		if resp.StatusCode == http.StatusNotFound && strings.HasPrefix(path, rt.podsPath()+"/") {
+			return nil, fmt.Errorf("%w: %v", errContainerNotFound, err)
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (rt *kubernetesRuntime) do(method, path string, body interface{}, out interface{}) error {
+	resp, err := rt.stream(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (rt *kubernetesRuntime) podsPath() string {
+	return fmt.Sprintf("/api/v1/namespaces/%s/pods", url.PathEscape(rt.namespace))
+}
+
+var invalidPodNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// validLabelValue matches values Kubernetes accepts as label values.
+var validLabelValue = regexp.MustCompile(`^(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?$`)
+
+// splitLabels separates spec labels into Pod labels and annotations, since values such as
+// model paths are not valid label values.
+func splitLabels(in map[string]string) (map[string]string, map[string]string) {
+	labels := make(map[string]string)
+	annotations := make(map[string]string)
+	for k, v := range in {
+		if len(v) <= 63 && validLabelValue.MatchString(v) {
+			labels[k] = v
+		} else {
+			annotations[k] = v
+		}
+	}
+	return labels, annotations
+}
+
+// podName turns a container name into a valid DNS-1123 Pod name.
+func podName(name string) string {
+	n := invalidPodNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	n = strings.Trim(n, "-")
+	if len(n) > 63 {
+		n = strings.Trim(n[:63], "-")
+	}
+	if n == "" {
+		n = fmt.Sprintf("ilab-%d", time.Now().UnixNano())
+	}
+	return n
+}
+
+// podManifest translates a ContainerSpec into a Pod object.
+func (rt *kubernetesRuntime) podManifest(name string, spec ContainerSpec) map[string]interface{} {
+	container := map[string]interface{}{
+		"name":  "main",
+		"image": spec.Image,
+		"args":  spec.Args,
+	}
+	if spec.Entrypoint != "" {
+		container["command"] = []string{spec.Entrypoint}
+	}
+
+	var volumes []interface{}
+	var mounts []interface{}
+	for i, v := range spec.Volumes {
+		volName := fmt.Sprintf("vol-%d", i)
+		volumes = append(volumes, map[string]interface{}{
+			"name":     volName,
+			"hostPath": map[string]string{"path": v.HostPath},
+		})
+		mounts = append(mounts, map[string]string{"name": volName, "mountPath": v.ContainerPath})
+	}
+	if spec.ShmSize != "" {
+		volumes = append(volumes, map[string]interface{}{
+			"name":     "dshm",
+			"emptyDir": map[string]string{"medium": "Memory", "sizeLimit": strings.TrimSuffix(spec.ShmSize, "B") + "i"},
+		})
+		mounts = append(mounts, map[string]string{"name": "dshm", "mountPath": "/dev/shm"})
+	}
+	container["volumeMounts"] = mounts
+
+	if len(spec.GPUs) > 0 {
+		// Kubernetes schedules GPUs by count; "all" or a specific index both map to one device each.
+		container["resources"] = map[string]interface{}{
+			"limits": map[string]string{"nvidia.com/gpu": fmt.Sprintf("%d", len(spec.GPUs))},
+		}
+	}
+
+	labels, annotations := splitLabels(spec.Labels)
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name":        name,
+			"labels":      labels,
+			"annotations": annotations,
+		},
+		"spec": map[string]interface{}{
+			"restartPolicy": "Never",
+			"hostNetwork":   spec.HostNetwork,
+			"containers":    []interface{}{container},
+			"volumes":       volumes,
+		},
+	}
+}
+
+// kubernetesPod holds the subset of the Pod object the runtime reads.
+type kubernetesPod struct {
+	Metadata struct {
+		Name              string            `json:"name"`
+		CreationTimestamp string            `json:"creationTimestamp"`
+		Labels            map[string]string `json:"labels"`
+		Annotations       map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		Containers []struct {
+			Image   string   `json:"image"`
+			Command []string `json:"command"`
+			Args    []string `json:"args"`
+			Ports   []struct {
+				ContainerPort int `json:"containerPort"`
+			} `json:"ports"`
+		} `json:"containers"`
+	} `json:"spec"`
+	Status struct {
+		Phase      string `json:"phase"`
+		Conditions []struct {
+			Type    string `json:"type"`
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+		ContainerStatuses []struct {
+			State struct {
+				Waiting *struct {
+					Reason  string `json:"reason"`
+					Message string `json:"message"`
+				} `json:"waiting"`
+				Terminated *struct {
+					ExitCode int `json:"exitCode"`
+				} `json:"terminated"`
+			} `json:"state"`
+		} `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+// startFailure returns why a Pending Pod will never start, e.g. its image cannot be pulled
+// or it cannot be scheduled, or nil while it may still start.
+func (p *kubernetesPod) startFailure() error {
+	for _, c := range p.Status.Conditions {
+		if c.Type == "PodScheduled" && podStartFailures[c.Reason] {
+			return fmt.Errorf("pod %s cannot start: %s: %s", p.Metadata.Name, c.Reason, c.Message)
+		}
+	}
+	for _, c := range p.Status.ContainerStatuses {
+		if w := c.State.Waiting; w != nil && podStartFailures[w.Reason] {
+			return fmt.Errorf("pod %s cannot start: %s: %s", p.Metadata.Name, w.Reason, w.Message)
+		}
+	}
+	return nil
+}
+
+func (p *kubernetesPod) toContainerInfo() ContainerInfo {
+	labels := make(map[string]string)
+	for k, v := range p.Metadata.Annotations {
+		labels[k] = v
+	}
+	for k, v := range p.Metadata.Labels {
+		labels[k] = v
+	}
+	info := ContainerInfo{
+		ID:        p.Metadata.Name,
+		Name:      p.Metadata.Name,
+		CreatedAt: p.Metadata.CreationTimestamp,
+		Status:    p.Status.Phase,
+		Labels:    labels,
+	}
+	if len(p.Spec.Containers) > 0 {
+		c := p.Spec.Containers[0]
+		info.Image = c.Image
+		info.Args = c.Args
+		info.Command = strings.Join(append(append([]string{}, c.Command...), c.Args...), " ")
+	}
+	if len(p.Status.ContainerStatuses) > 0 && p.Status.ContainerStatuses[0].State.Terminated != nil {
+		info.ExitCode = p.Status.ContainerStatuses[0].State.Terminated.ExitCode
+	}
+	return info
+}
+
+func (rt *kubernetesRuntime) getPod(name string) (*kubernetesPod, error) {
+	var pod kubernetesPod
+	if err := rt.do(http.MethodGet, rt.podsPath()+"/"+url.PathEscape(name), nil, &pod); err != nil {
+		return nil, err
+	}
+	return &pod, nil
+}
+
+func (rt *kubernetesRuntime) Start(spec ContainerSpec, stdout, stderr io.Writer) (ContainerProcess, error) {
+	name := podName(spec.Name)
+	if err := rt.do(http.MethodPost, rt.podsPath(), rt.podManifest(name, spec), nil); err != nil {
+		return nil, err
+	}
+
+	proc := &kubernetesProcess{name: name, done: make(chan struct{})}
+	rt.mu.Lock()
+	rt.procs[name] = proc
+	rt.mu.Unlock()
+	go func() {
+		defer close(proc.done)
+		defer func() {
+			rt.mu.Lock()
+			delete(rt.procs, name)
+			rt.mu.Unlock()
+		}()
+
+		// Wait for the Pod to leave Pending before attaching to its logs, giving up on
+		// Pods that will never start.
+		deadline := time.Now().Add(rt.pendingTimeout)
+		for {
+			pod, err := rt.getPod(name)
+			if err != nil {
+				proc.err = rt.podGone(proc, err)
+				return
+			}
+			if pod.Status.Phase != "Pending" {
+				break
+			}
+			if err := pod.startFailure(); err != nil {
+				proc.err = err
+				_ = rt.Stop(name)
+				return
+			}
+			if rt.pendingTimeout > 0 && time.Now().After(deadline) {
+				proc.err = fmt.Errorf("pod %s still pending after %s", name, rt.pendingTimeout)
+				_ = rt.Stop(name)
+				return
+			}
+			time.Sleep(rt.pollInterval)
+		}
+
+		if resp, err := rt.stream(http.MethodGet, rt.podsPath()+"/"+url.PathEscape(name)+"/log?follow=true", nil); err == nil {
+			_, _ = io.Copy(stdout, resp.Body)
+			resp.Body.Close()
+		}
+
+		for {
+			pod, err := rt.getPod(name)
+			if err != nil {
+				proc.err = rt.podGone(proc, err)
+				return
+			}
+			switch pod.Status.Phase {
+			case "Succeeded":
+				proc.err = nil
+			case "Failed":
+				proc.err = fmt.Errorf("pod %s failed", name)
+			default:
+				time.Sleep(rt.pollInterval)
+				continue
+			}
+			break
+		}
+		if spec.Remove {
+			_ = rt.Stop(name)
+		}
+	}()
+	return proc, nil
+}
+
+// podGone returns the error a process ends with when reading its Pod failed with err: none
+// when Stop deleted the Pod, err otherwise.
+func (rt *kubernetesRuntime) podGone(proc *kubernetesProcess, err error) error {
+	rt.mu.Lock()
+	stopped := proc.stopped
+	rt.mu.Unlock()
+	if stopped && errors.Is(err, errContainerNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (rt *kubernetesRuntime) List(filter ContainerFilter) ([]ContainerInfo, error) {
+	path := rt.podsPath()
+	if len(filter.Labels) > 0 {
+		var selectors []string
+		for k, v := range filter.Labels {
+			selectors = append(selectors, fmt.Sprintf("%s=%s", k, v))
+		}
+		sort.Strings(selectors)
+		path += "?labelSelector=" + url.QueryEscape(strings.Join(selectors, ","))
+	}
+
+	var list struct {
+		Items []kubernetesPod `json:"items"`
+	}
+	if err := rt.do(http.MethodGet, path, nil, &list); err != nil {
+		return nil, err
+	}
+
+	var containers []ContainerInfo
+	for i := range list.Items {
+		info := list.Items[i].toContainerInfo()
+		if info.Status != "Pending" && info.Status != "Running" {
+			continue
+		}
+		if matchesFilter(info, filter) {
+			containers = append(containers, info)
+		}
+	}
+	return containers, nil
+}
+
+func (rt *kubernetesRuntime) Inspect(id string) (*ContainerInfo, error) {
+	pod, err := rt.getPod(id)
+	if err != nil {
+		return nil, err
+	}
+	info := pod.toContainerInfo()
+	return &info, nil
+}
+
+func (rt *kubernetesRuntime) Stop(id string) error {
+	rt.mu.Lock()
+	if proc := rt.procs[id]; proc != nil {
+		proc.stopped = true
+	}
+	rt.mu.Unlock()
+	return rt.do(http.MethodDelete, rt.podsPath()+"/"+url.PathEscape(id), nil, nil)
+}
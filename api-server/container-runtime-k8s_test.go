@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// kubernetesStub is a Kubernetes API server stub holding a single Pod.
+type kubernetesStub struct {
+	mu         sync.Mutex
+	phases     []string // phases returned by successive reads of the Pod; the last one sticks
+	waiting    string   // waiting reason of the container while Pending
+	condition  string   // reason of the PodScheduled condition while Pending
+	deleted    bool
+	calls      []string
+	logsClosed chan struct{}
+}
+
+func (s *kubernetesStub) record(call string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call)
+}
+
+// startKubernetesStub serves a stub API server and returns a runtime pointed at it.
+func startKubernetesStub(t *testing.T, stub *kubernetesStub) *kubernetesRuntime {
+	t.Helper()
+	stub.logsClosed = make(chan struct{})
+	podPath := "/api/v1/namespaces/ilab/pods"
+	mux := http.NewServeMux()
+	mux.HandleFunc(podPath, func(w http.ResponseWriter, r *http.Request) {
+		stub.record("create")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("{}"))
+	})
+	mux.HandleFunc(podPath+"/", func(w http.ResponseWriter, r *http.Request) {
+		name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, podPath+"/"), "/")
+		stub.mu.Lock()
+		defer stub.mu.Unlock()
+		switch {
+		case r.Method == http.MethodDelete:
+			stub.calls = append(stub.calls, "delete")
+			if !stub.deleted {
+				stub.deleted = true
+				close(stub.logsClosed)
+			}
+			_, _ = w.Write([]byte("{}"))
+		case stub.deleted:
+			http.Error(w, `{"kind":"Status","reason":"NotFound"}`, http.StatusNotFound)
+		case action == "log":
+			stub.calls = append(stub.calls, "log")
+			_, _ = w.Write([]byte("loading model\n"))
+			w.(http.Flusher).Flush()
+			if stub.phases[len(stub.phases)-1] == "Running" {
+				// Follow the log of a Pod that keeps running until it is deleted
+				stub.mu.Unlock()
+				<-stub.logsClosed
+				stub.mu.Lock()
+			}
+		default:
+			phase := stub.phases[0]
+			if len(stub.phases) > 1 {
+				stub.phases = stub.phases[1:]
+			}
+			pod := map[string]interface{}{
+				"metadata": map[string]interface{}{"name": name},
+				"status":   map[string]interface{}{"phase": phase},
+			}
+			status := pod["status"].(map[string]interface{})
+			if phase == "Pending" && stub.waiting != "" {
+				status["containerStatuses"] = []interface{}{map[string]interface{}{
+					"state": map[string]interface{}{"waiting": map[string]string{"reason": stub.waiting, "message": "pull failed"}},
+				}}
+			}
+			if phase == "Pending" && stub.condition != "" {
+				status["conditions"] = []interface{}{map[string]string{
+					"type": "PodScheduled", "reason": stub.condition, "message": "0/3 nodes are available",
+				}}
+			}
+			_ = json.NewEncoder(w).Encode(pod)
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return &kubernetesRuntime{
+		apiURL:         server.URL,
+		namespace:      "ilab",
+		client:         server.Client(),
+		pendingTimeout: time.Minute,
+		pollInterval:   time.Millisecond,
+		procs:          make(map[string]*kubernetesProcess),
+	}
+}
+
+func TestKubernetesStart(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		stub           *kubernetesStub
+		pendingTimeout time.Duration
+		wantErr        string
+		wantLog        bool
+		wantDelete     bool
+	}{
+		{name: "succeeded", stub: &kubernetesStub{phases: []string{"Pending", "Running", "Succeeded"}}, wantLog: true, wantDelete: true},
+		{name: "failed", stub: &kubernetesStub{phases: []string{"Running", "Failed"}}, wantErr: "pod vllm-v-1 failed", wantLog: true, wantDelete: true},
+		{name: "image pull backoff", stub: &kubernetesStub{phases: []string{"Pending"}, waiting: "ImagePullBackOff"}, wantErr: "ImagePullBackOff", wantDelete: true},
+		{name: "image pull error", stub: &kubernetesStub{phases: []string{"Pending"}, waiting: "ErrImagePull"}, wantErr: "ErrImagePull", wantDelete: true},
+		{name: "unschedulable", stub: &kubernetesStub{phases: []string{"Pending"}, condition: "Unschedulable"}, wantErr: "Unschedulable", wantDelete: true},
+		{name: "still creating", stub: &kubernetesStub{phases: []string{"Pending", "Pending", "Succeeded"}, waiting: "ContainerCreating"}, wantLog: true, wantDelete: true},
+		{name: "pending timeout", stub: &kubernetesStub{phases: []string{"Pending"}}, pendingTimeout: 20 * time.Millisecond, wantErr: "still pending", wantDelete: true},
+		{name: "vanished", stub: &kubernetesStub{phases: []string{"Running"}, deleted: true}, wantErr: "container not found"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := tc.stub
+			rt := startKubernetesStub(t, stub)
+			if tc.pendingTimeout != 0 {
+				rt.pendingTimeout = tc.pendingTimeout
+			}
+			if stub.deleted {
+				close(stub.logsClosed)
+			}
+
+			var stdout bytes.Buffer
+			proc, err := rt.Start(ContainerSpec{Name: "vllm-v-1", Image: "vllm:latest", Remove: true}, &stdout, &bytes.Buffer{})
+			if err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			err = proc.Wait()
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("Wait: %v", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Errorf("Wait error = %v, want it to contain %q", err, tc.wantErr)
+			}
+			if got := stdout.String() == "loading model\n"; got != tc.wantLog {
+				t.Errorf("stdout = %q, want the log %t", stdout.String(), tc.wantLog)
+			}
+
+			stub.mu.Lock()
+			defer stub.mu.Unlock()
+			if got := slices.Contains(stub.calls, "delete"); got != tc.wantDelete {
+				t.Errorf("calls = %v, want the pod deleted %t", stub.calls, tc.wantDelete)
+			}
+			if len(rt.procs) != 0 {
+				t.Errorf("procs = %v, want the ended process forgotten", rt.procs)
+			}
+		})
+	}
+}
+
+func TestKubernetesStopIsNormalExit(t *testing.T) {
+	stub := &kubernetesStub{phases: []string{"Running"}}
+	rt := startKubernetesStub(t, stub)
+
+	proc, err := rt.Start(ContainerSpec{Name: "vllm-v-1", Image: "vllm:latest"}, &bytes.Buffer{}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stub.mu.Lock()
+		following := slices.Contains(stub.calls, "log")
+		stub.mu.Unlock()
+		if following {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the runtime did not follow the pod log")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := rt.Stop(proc.ID()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if err := proc.Wait(); err != nil {
+		t.Errorf("Wait after Stop = %v, want a normal stop", err)
+	}
+}
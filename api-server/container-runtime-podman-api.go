@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// podmanAPIVersion is the libpod REST API version the server speaks.
+const podmanAPIVersion = "v4.0.0"
+
+// podmanAPIRuntime drives containers through the podman libpod REST API on its unix socket.
+type podmanAPIRuntime struct {
+	api *unixAPIClient
+}
+
+// podmanAPIProcess tracks a container started through the libpod API.
+type podmanAPIProcess struct {
+	id   string
+	pid  int
+	done chan struct{}
+	err  error
+}
+
+func (p *podmanAPIProcess) ID() string { return p.id }
+func (p *podmanAPIProcess) PID() int   { return p.pid }
+func (p *podmanAPIProcess) Wait() error {
+	<-p.done
+	return p.err
+}
+
+// defaultPodmanSocket returns the socket the podman system service listens on for this user.
+func defaultPodmanSocket() string {
+	if os.Getuid() == 0 {
+		return "/run/podman/podman.sock"
+	}
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = filepath.Join("/run/user", strconv.Itoa(os.Getuid()))
+	}
+	return filepath.Join(runtimeDir, "podman", "podman.sock")
+}
+
+func newPodmanAPIRuntime(socketPath string) *podmanAPIRuntime {
+	if socketPath == "" {
+		socketPath = defaultPodmanSocket()
+	}
+	return &podmanAPIRuntime{api: newUnixAPIClient("podman", socketPath, "/"+podmanAPIVersion+"/libpod")}
+}
+
+func (rt *podmanAPIRuntime) Name() string {
+	return "podman"
+}
+
+// ping checks that the podman service answers on the socket.
+func (rt *podmanAPIRuntime) ping() error {
+	resp, err := rt.api.stream(http.MethodGet, "/_ping", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (rt *podmanAPIRuntime) Start(spec ContainerSpec, stdout, stderr io.Writer) (ContainerProcess, error) {
+	shmSize, err := parseByteSize(spec.ShmSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// Body follows the libpod SpecGenerator schema.
+	createBody := map[string]interface{}{
+		"name":    spec.Name,
+		"image":   spec.Image,
+		"command": spec.Args,
+		"labels":  spec.Labels,
+		"remove":  spec.Remove,
+	}
+	if spec.Entrypoint != "" {
+		createBody["entrypoint"] = []string{spec.Entrypoint}
+	}
+	if spec.HostNetwork {
+		createBody["netns"] = map[string]string{"nsmode": "host"}
+	}
+	if shmSize > 0 {
+		createBody["shm_size"] = shmSize
+	}
+	if spec.PidsLimit != 0 {
+		createBody["resource_limits"] = map[string]interface{}{
+			"pids": map[string]int{"limit": spec.PidsLimit},
+		}
+	}
+	var selinuxOpts []string
+	for _, opt := range spec.SecurityOpt {
+		if strings.HasPrefix(opt, "label=") {
+			selinuxOpts = append(selinuxOpts, strings.TrimPrefix(opt, "label="))
+		}
+	}
+	if len(selinuxOpts) > 0 {
+		createBody["selinux_opts"] = selinuxOpts
+	}
+	var mounts []map[string]interface{}
+	for _, v := range spec.Volumes {
+		mounts = append(mounts, map[string]interface{}{
+			"type":        "bind",
+			"source":      v.HostPath,
+			"destination": v.ContainerPath,
+		})
+	}
+	if len(mounts) > 0 {
+		createBody["mounts"] = mounts
+	}
+	var devices []map[string]string
+	for _, gpu := range spec.GPUs {
+		devices = append(devices, map[string]string{"path": fmt.Sprintf("nvidia.com/gpu=%s", gpu)})
+	}
+	if len(devices) > 0 {
+		createBody["devices"] = devices
+	}
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := rt.api.do(http.MethodPost, "/containers/create", createBody, &created); err != nil {
+		return nil, err
+	}
+	proc := &podmanAPIProcess{id: created.ID, done: make(chan struct{})}
+
+	// The wait is issued before the start so the exit code is read even when remove deletes
+	// the container as soon as it exits. A failed wait, e.g. on a container that is already
+	// gone, leaves the exit status unknown, which is reported as an error.
+	waited := make(chan struct{})
+	go func() {
+		defer close(waited)
+		var exitCode int
+		if err := rt.api.do(http.MethodPost, "/containers/"+created.ID+"/wait?condition=stopped", nil, &exitCode); err != nil {
+			proc.err = fmt.Errorf("exit status of container %s unknown: %v", created.ID, err)
+			return
+		}
+		if exitCode != 0 {
+			proc.err = fmt.Errorf("container %s exited with status %d", created.ID, exitCode)
+		}
+	}()
+	if err := rt.api.do(http.MethodPost, "/containers/"+created.ID+"/start", nil, nil); err != nil {
+		// Removing the container that never started also ends the pending wait
+		_ = rt.api.do(http.MethodDelete, "/containers/"+created.ID+"?force=true", nil, nil)
+		return nil, err
+	}
+
+	if info, err := rt.Inspect(created.ID); err == nil {
+		proc.pid = info.PID
+	}
+
+	go func() {
+		defer close(proc.done)
+		_ = rt.Logs(created.ID, true, stdout, stderr)
+		<-waited
+	}()
+
+	return proc, nil
+}
+
+// Logs copies the container output to stdout/stderr, following it until the container exits
+// when follow is set.
+func (rt *podmanAPIRuntime) Logs(id string, follow bool, stdout, stderr io.Writer) error {
+	path := fmt.Sprintf("/containers/%s/logs?stdout=true&stderr=true&follow=%t", url.PathEscape(id), follow)
+	resp, err := rt.api.stream(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return demuxLogStream(resp.Body, stdout, stderr)
+}
+
+// podmanListEntry is an entry of GET /libpod/containers/json.
+type podmanListEntry struct {
+	ID        string            `json:"Id"`
+	Names     []string          `json:"Names"`
+	Image     string            `json:"Image"`
+	Command   []string          `json:"Command"`
+	CreatedAt string            `json:"CreatedAt"`
+	State     string            `json:"State"`
+	Status    string            `json:"Status"`
+	Pid       int               `json:"Pid"`
+	Labels    map[string]string `json:"Labels"`
+	Ports     []struct {
+		HostPort      int    `json:"host_port"`
+		ContainerPort int    `json:"container_port"`
+		Protocol      string `json:"protocol"`
+	} `json:"Ports"`
+}
+
+// podmanFilters encodes a ContainerFilter as the JSON filters query parameter.
+func podmanFilters(filter ContainerFilter) (string, error) {
+	filters := map[string][]string{}
+	if filter.Image != "" {
+		filters["ancestor"] = []string{filter.Image}
+	}
+	for k, v := range filter.Labels {
+		filters["label"] = append(filters["label"], fmt.Sprintf("%s=%s", k, v))
+	}
+	filterJSON, err := json.Marshal(filters)
+	if err != nil {
+		return "", err
+	}
+	return url.QueryEscape(string(filterJSON)), nil
+}
+
+func (rt *podmanAPIRuntime) List(filter ContainerFilter) ([]ContainerInfo, error) {
+	filters, err := podmanFilters(filter)
+	if err != nil {
+		return nil, err
+	}
+	var entries []podmanListEntry
+	if err := rt.api.do(http.MethodGet, "/containers/json?filters="+filters, nil, &entries); err != nil {
+		return nil, err
+	}
+
+	var containers []ContainerInfo
+	for _, e := range entries {
+		var ports []string
+		for _, p := range e.Ports {
+			ports = append(ports, fmt.Sprintf("%d->%d/%s", p.HostPort, p.ContainerPort, p.Protocol))
+		}
+		info := ContainerInfo{
+			ID:        e.ID,
+			Image:     e.Image,
+			Command:   strings.Join(e.Command, " "),
+			Args:      e.Command, // the list entry carries the full command, no per-container inspect needed
+			CreatedAt: e.CreatedAt,
+			Status:    e.State,
+			Ports:     strings.Join(ports, ", "),
+			PID:       e.Pid,
+			Labels:    e.Labels,
+		}
+		if len(e.Names) > 0 {
+			info.Name = e.Names[0]
+		}
+		containers = append(containers, info)
+	}
+	return containers, nil
+}
+
+func (rt *podmanAPIRuntime) Inspect(id string) (*ContainerInfo, error) {
+	var r podmanInspect
+	if err := rt.api.do(http.MethodGet, "/containers/"+url.PathEscape(id)+"/json", nil, &r); err != nil {
+		return nil, err
+	}
+	return &ContainerInfo{
+		ID:        r.ID,
+		Name:      r.Name,
+		Image:     r.ImageName,
+		Command:   strings.Join(r.Config.Cmd, " "),
+		Args:      r.Config.Cmd,
+		CreatedAt: r.Created,
+		Status:    r.State.Status,
+		PID:       r.State.Pid,
+		ExitCode:  r.State.ExitCode,
+		Labels:    r.Config.Labels,
+	}, nil
+}
+
+func (rt *podmanAPIRuntime) Stop(id string) error {
+	return rt.api.do(http.MethodPost, "/containers/"+url.PathEscape(id)+"/stop", nil, nil)
+}
+
+// Events streams container lifecycle events matching filter to handler until the
+// connection drops.
+func (rt *podmanAPIRuntime) Events(filter ContainerFilter, handler func(ContainerEvent)) error {
+	filters := map[string][]string{"type": {"container"}}
+	for k, v := range filter.Labels {
+		filters["label"] = append(filters["label"], fmt.Sprintf("%s=%s", k, v))
+	}
+	filterJSON, err := json.Marshal(filters)
+	if err != nil {
+		return err
+	}
+	resp, err := rt.api.stream(http.MethodGet, "/events?stream=true&filters="+url.QueryEscape(string(filterJSON)), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var raw struct {
+			Action string `json:"Action"`
+			Actor  struct {
+				ID         string            `json:"ID"`
+				Attributes map[string]string `json:"Attributes"`
+			} `json:"Actor"`
+			TimeNano int64 `json:"timeNano"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &raw); err != nil {
+			continue
+		}
+		event := ContainerEvent{
+			ContainerID: raw.Actor.ID,
+			Action:      raw.Action,
+			Labels:      raw.Actor.Attributes,
+			Time:        time.Unix(0, raw.TimeNano),
+			ExitCode:    -1,
+		}
+		if code, err := strconv.Atoi(raw.Actor.Attributes["containerExitCode"]); err == nil {
+			event.ExitCode = code
+		}
+		handler(event)
+	}
+	return scanner.Err()
+}
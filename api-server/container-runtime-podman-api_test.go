@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// podmanStub is a libpod API stub served on a unix socket.
+type podmanStub struct {
+	mu       sync.Mutex
+	exitCode int
+	// waitStatus, when set, is the HTTP status wait answers with, e.g. 404 for a
+	// container that is already removed
+	waitStatus int
+	failStart  bool
+	created    map[string]interface{}
+	stopped    []string
+	deleted    []string
+}
+
+// startPodmanStub serves a stub libpod API and returns a runtime pointed at it.
+func startPodmanStub(t *testing.T, stub *podmanStub) *podmanAPIRuntime {
+	t.Helper()
+	// Unix socket paths are limited to ~108 bytes, which t.TempDir can exceed.
+	dir, err := os.MkdirTemp("", "podman")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socketPath := filepath.Join(dir, "podman.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := "/" + podmanAPIVersion + "/libpod"
+	mux := http.NewServeMux()
+	mux.HandleFunc(prefix+"/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		var filters map[string][]string
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if got := filters["label"]; len(got) != 1 || got[0] != labelRole+"="+roleVllm {
+			http.Error(w, "unexpected filters", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]map[string]interface{}{{
+			"Id":        "abc123",
+			"Names":     []string{"vllm-v-1"},
+			"Image":     "vllm:latest",
+			"Command":   []string{"serve", "/models/granite", "--port", "8000"},
+			"CreatedAt": "2024-01-01T00:00:00Z",
+			"State":     "running",
+			"Pid":       42,
+			"Labels":    map[string]string{labelRole: roleVllm},
+			"Ports":     []map[string]interface{}{{"host_port": 8000, "container_port": 8000, "protocol": "tcp"}},
+		}})
+	})
+	mux.HandleFunc(prefix+"/containers/create", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		stub.mu.Lock()
+		stub.created = body
+		stub.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"Id": "new1"})
+	})
+	mux.HandleFunc(prefix+"/containers/", func(w http.ResponseWriter, r *http.Request) {
+		rest := strings.TrimPrefix(r.URL.Path, prefix+"/containers/")
+		id, action, _ := strings.Cut(rest, "/")
+		if r.Method == http.MethodDelete {
+			stub.mu.Lock()
+			stub.deleted = append(stub.deleted, id)
+			stub.mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		switch action {
+		case "start":
+			if stub.failStart {
+				http.Error(w, "no such image", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case "stop":
+			stub.mu.Lock()
+			stub.stopped = append(stub.stopped, id)
+			stub.mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		case "logs":
+			_, _ = w.Write(muxFrame(1, "loading model\n"))
+			_, _ = w.Write(muxFrame(2, "warning\n"))
+		case "wait":
+			stub.mu.Lock()
+			code, status := stub.exitCode, stub.waitStatus
+			stub.mu.Unlock()
+			if status != 0 {
+				http.Error(w, "no such container", status)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(code)
+		case "json":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"Id":        id,
+				"Name":      "vllm-" + id,
+				"ImageName": "vllm:latest",
+				"Created":   "2024-01-01T00:00:00Z",
+				"State":     map[string]interface{}{"Status": "exited", "Pid": 0, "ExitCode": 3},
+				"Config":    map[string]interface{}{"Cmd": []string{"serve", "/models/granite"}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	mux.HandleFunc(prefix+"/events", func(w http.ResponseWriter, r *http.Request) {
+		for _, line := range []string{
+			`{"Action":"start","Actor":{"ID":"abc123","Attributes":{"ilab.instructlab.ai/job-id":"v-1"}},"timeNano":1}`,
+			`not json`,
+			`{"Action":"died","Actor":{"ID":"abc123","Attributes":{"ilab.instructlab.ai/job-id":"v-1","containerExitCode":"137"}},"timeNano":2}`,
+		} {
+			_, _ = w.Write([]byte(line + "\n"))
+		}
+	})
+
+	server := httptest.NewUnstartedServer(mux)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return newPodmanAPIRuntime(socketPath)
+}
+
+// muxFrame encodes payload as one frame of a multiplexed log stream.
+func muxFrame(stream byte, payload string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+func TestPodmanAPIList(t *testing.T) {
+	rt := startPodmanStub(t, &podmanStub{})
+
+	containers, err := rt.List(ContainerFilter{Labels: map[string]string{labelRole: roleVllm}})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(containers) != 1 {
+		t.Fatalf("got %d containers, want 1", len(containers))
+	}
+	c := containers[0]
+	if c.ID != "abc123" || c.Name != "vllm-v-1" || c.PID != 42 || c.Status != "running" {
+		t.Errorf("unexpected container %+v", c)
+	}
+	if c.Ports != "8000->8000/tcp" {
+		t.Errorf("Ports = %q", c.Ports)
+	}
+	if got := strings.Join(c.Args, " "); got != "serve /models/granite --port 8000" {
+		t.Errorf("Args = %q", got)
+	}
+}
+
+func TestPodmanAPIInspect(t *testing.T) {
+	rt := startPodmanStub(t, &podmanStub{})
+
+	info, err := rt.Inspect("abc123")
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if info.ID != "abc123" || info.Status != "exited" || info.ExitCode != 3 {
+		t.Errorf("unexpected info %+v", info)
+	}
+}
+
+func TestPodmanAPIStart(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		exitCode int
+		wantErr  bool
+	}{
+		{"success", 0, false},
+		{"failure", 1, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := &podmanStub{exitCode: tc.exitCode}
+			rt := startPodmanStub(t, stub)
+
+			var stdout, stderr bytes.Buffer
+			proc, err := rt.Start(ContainerSpec{
+				Name:        "vllm-v-1",
+				Image:       "vllm:latest",
+				Args:        []string{"serve", "/models/granite"},
+				HostNetwork: true,
+				ShmSize:     "1G",
+				Remove:      true,
+				Labels:      map[string]string{labelJobID: "v-1"},
+			}, &stdout, &stderr)
+			if err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			if proc.ID() != "new1" {
+				t.Errorf("ID = %q, want new1", proc.ID())
+			}
+			err = proc.Wait()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Wait error = %v, want error %t", err, tc.wantErr)
+			}
+			if stdout.String() != "loading model\n" || stderr.String() != "warning\n" {
+				t.Errorf("stdout = %q, stderr = %q", stdout.String(), stderr.String())
+			}
+
+			stub.mu.Lock()
+			defer stub.mu.Unlock()
+			if stub.created["name"] != "vllm-v-1" || stub.created["remove"] != true {
+				t.Errorf("unexpected create body %v", stub.created)
+			}
+			if stub.created["shm_size"] != float64(1<<30) {
+				t.Errorf("shm_size = %v", stub.created["shm_size"])
+			}
+			if netns, _ := stub.created["netns"].(map[string]interface{}); netns["nsmode"] != "host" {
+				t.Errorf("netns = %v", stub.created["netns"])
+			}
+		})
+	}
+}
+
+func TestPodmanAPIStop(t *testing.T) {
+	stub := &podmanStub{}
+	rt := startPodmanStub(t, stub)
+
+	if err := rt.Stop("abc123"); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if len(stub.stopped) != 1 || stub.stopped[0] != "abc123" {
+		t.Errorf("stopped = %v", stub.stopped)
+	}
+}
+
+func TestPodmanAPIEvents(t *testing.T) {
+	rt := startPodmanStub(t, &podmanStub{})
+
+	var events []ContainerEvent
+	err := rt.Events(ContainerFilter{Labels: map[string]string{labelManagedBy: managedByValue}}, func(e ContainerEvent) {
+		events = append(events, e)
+	})
+	if err != nil {
+		t.Fatalf("Events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if events[0].Action != "start" || events[0].ExitCode != -1 {
+		t.Errorf("unexpected start event %+v", events[0])
+	}
+	died := events[1]
+	if died.Action != "died" || died.ExitCode != 137 || died.Labels[labelJobID] != "v-1" || died.ContainerID != "abc123" {
+		t.Errorf("unexpected died event %+v", died)
+	}
+}
+
+func TestPodmanAPIStartFailureRemovesContainer(t *testing.T) {
+	stub := &podmanStub{failStart: true}
+	rt := startPodmanStub(t, stub)
+
+	if _, err := rt.Start(ContainerSpec{Name: "vllm-v-1", Image: "vllm:latest", Remove: true}, &bytes.Buffer{}, &bytes.Buffer{}); err == nil {
+		t.Fatal("Start succeeded")
+	}
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.deleted) != 1 || stub.deleted[0] != "new1" {
+		t.Errorf("deleted = %v, want the created container", stub.deleted)
+	}
+}
+
+func TestVllmJobFailsWhenExitStatusIsLost(t *testing.T) {
+	srv := newTestServer(t)
+	srv.containerRuntime = startPodmanStub(t, &podmanStub{waitStatus: http.StatusNotFound})
+
+	w := httptest.NewRecorder()
+	srv.runVllmContainerHandler("/models/granite", "8000", "pre-train", 0, "/models", "/models", w)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// The container was removed before its exit code was read, so the serve did not succeed
+	job := waitForJob(t, srv, resp["job_id"])
+	if job.Status != "failed" {
+		t.Errorf("status = %q, want failed", job.Status)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// podmanCLIRuntime drives containers by shelling out to the podman CLI.
+type podmanCLIRuntime struct {
+	log *zap.SugaredLogger
+}
+
+// podmanCLIProcess wraps the foreground "podman run" process.
+type podmanCLIProcess struct {
+	name string
+	cmd  *exec.Cmd
+}
+
+func (p *podmanCLIProcess) ID() string  { return p.name }
+func (p *podmanCLIProcess) PID() int    { return p.cmd.Process.Pid }
+func (p *podmanCLIProcess) Wait() error { return p.cmd.Wait() }
+
+func newPodmanCLIRuntime(log *zap.SugaredLogger) *podmanCLIRuntime {
+	return &podmanCLIRuntime{log: log}
+}
+
+func (rt *podmanCLIRuntime) Name() string {
+	return "podman"
+}
+
+// runArgs builds the "podman run" argument list for spec.
+func (rt *podmanCLIRuntime) runArgs(spec ContainerSpec) []string {
+	args := []string{"run"}
+	if spec.Remove {
+		args = append(args, "--rm")
+	}
+	if spec.Name != "" {
+		args = append(args, "--name", spec.Name)
+	}
+	for _, gpu := range spec.GPUs {
+		args = append(args, "--device", fmt.Sprintf("nvidia.com/gpu=%s", gpu))
+	}
+	for _, opt := range spec.SecurityOpt {
+		args = append(args, "--security-opt", opt)
+	}
+	if spec.HostNetwork {
+		args = append(args, "--net", "host")
+	}
+	if spec.ShmSize != "" {
+		args = append(args, "--shm-size", spec.ShmSize)
+	}
+	if spec.PidsLimit != 0 {
+		args = append(args, "--pids-limit", fmt.Sprintf("%d", spec.PidsLimit))
+	}
+	for _, v := range spec.Volumes {
+		args = append(args, "-v", fmt.Sprintf("%s:%s", v.HostPath, v.ContainerPath))
+	}
+	for k, v := range spec.Labels {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", k, v))
+	}
+	if spec.Entrypoint != "" {
+		args = append(args, "--entrypoint", spec.Entrypoint)
+	}
+	args = append(args, spec.Image)
+	return append(args, spec.Args...)
+}
+
+func (rt *podmanCLIRuntime) Start(spec ContainerSpec, stdout, stderr io.Writer) (ContainerProcess, error) {
+	cmd := exec.Command("podman", rt.runArgs(spec)...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting podman run: %v", err)
+	}
+	return &podmanCLIProcess{name: spec.Name, cmd: cmd}, nil
+}
+
+func (rt *podmanCLIRuntime) List(filter ContainerFilter) ([]ContainerInfo, error) {
+	// Define a custom format with a pipe delimiter to avoid splitting on spaces.
+	format := "{{.ID}}|{{.Image}}|{{.Command}}|{{.CreatedAt}}|{{.Status}}|{{.Ports}}|{{.Names}}"
+
+	args := []string{"ps"}
+	if filter.Image != "" {
+		args = append(args, "--filter", "ancestor="+filter.Image)
+	}
+	for k, v := range filter.Labels {
+		args = append(args, "--filter", fmt.Sprintf("label=%s=%s", k, v))
+	}
+	args = append(args, "--format", format)
+
+	cmd := exec.Command("podman", args...)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error running podman ps: %v, stderr: %s", err, stderr.String())
+	}
+
+	var containers []ContainerInfo
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		parts := strings.Split(line, "|")
+		if len(parts) != 7 {
+			continue
+		}
+		info := ContainerInfo{
+			ID:        strings.TrimSpace(parts[0]),
+			Image:     strings.TrimSpace(parts[1]),
+			Command:   strings.TrimSpace(parts[2]),
+			CreatedAt: strings.TrimSpace(parts[3]),
+			Status:    strings.TrimSpace(parts[4]),
+			Ports:     strings.TrimSpace(parts[5]),
+			Name:      strings.TrimSpace(parts[6]),
+		}
+
+		// Inspect the container to get the full command
+		details, err := rt.Inspect(info.ID)
+		if err != nil {
+			// The container may have been removed since it was listed
+			rt.log.Warnf("Skipping container %s: %v", info.ID, err)
+			continue
+		}
+		info.Args = details.Args
+		info.PID = details.PID
+		info.Labels = details.Labels
+		containers = append(containers, info)
+	}
+	return containers, nil
+}
+
+// podmanInspect holds the subset of "podman inspect" output the server uses.
+type podmanInspect struct {
+	ID        string `json:"Id"`
+	Name      string `json:"Name"`
+	ImageName string `json:"ImageName"`
+	Created   string `json:"Created"`
+	State     struct {
+		Status   string `json:"Status"`
+		Pid      int    `json:"Pid"`
+		ExitCode int    `json:"ExitCode"`
+	} `json:"State"`
+	Config struct {
+		Cmd    []string          `json:"Cmd"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+}
+
+func (rt *podmanCLIRuntime) Inspect(id string) (*ContainerInfo, error) {
+	cmd := exec.Command("podman", "inspect", "--type", "container", id)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(strings.ToLower(stderr.String()), "no such container") {
+			return nil, fmt.Errorf("%w: %s", errContainerNotFound, id)
+		}
+		return nil, fmt.Errorf("error inspecting container %s: %v, stderr: %s", id, err, stderr.String())
+	}
+
+	var results []podmanInspect
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil {
+		return nil, fmt.Errorf("error unmarshalling inspect output for container %s: %v", id, err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w: %s", errContainerNotFound, id)
+	}
+	r := results[0]
+	return &ContainerInfo{
+		ID:        r.ID,
+		Name:      r.Name,
+		Image:     r.ImageName,
+		Command:   strings.Join(r.Config.Cmd, " "),
+		Args:      r.Config.Cmd,
+		CreatedAt: r.Created,
+		Status:    r.State.Status,
+		PID:       r.State.Pid,
+		ExitCode:  r.State.ExitCode,
+		Labels:    r.Config.Labels,
+	}, nil
+}
+
+func (rt *podmanCLIRuntime) Stop(id string) error {
+	cmd := exec.Command("podman", "stop", id)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error stopping container %s: %v, stderr: %s", id, err, stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vllmImage is the image used for vLLM model serving containers.
+const vllmImage = "registry.redhat.io/rhelai1/instructlab-nvidia-rhel9:1.4-1738905416"
+
+// qnaEvalImage is the image used by the /qna-eval endpoint.
+const qnaEvalImage = "quay.io/bsalisbu/qna-eval"
+
+// errContainerNotFound is returned, wrapped, by runtimes for a container that does not exist.
+var errContainerNotFound = errors.New("container not found")
+
+// VolumeMount maps a host path into a container.
+type VolumeMount struct {
+	HostPath      string
+	ContainerPath string
+}
+
+// ContainerSpec describes a container to launch, independent of the runtime backend.
+type ContainerSpec struct {
+	Name        string
+	Image       string
+	Entrypoint  string
+	Args        []string
+	GPUs        []string // GPU indexes, or "all"
+	Volumes     []VolumeMount
+	Labels      map[string]string
+	HostNetwork bool
+	ShmSize     string // e.g. "10G"
+	PidsLimit   int    // 0 keeps the runtime default, -1 means unlimited
+	SecurityOpt []string
+	Remove      bool // remove the container once it exits
+}
+
+// ContainerFilter narrows down a container listing.
+type ContainerFilter struct {
+	Image  string
+	Labels map[string]string
+}
+
+// ContainerInfo is the runtime-agnostic view of a container.
+type ContainerInfo struct {
+	ID        string
+	Name      string
+	Image     string
+	Command   string   // human readable command line
+	Args      []string // arguments passed to the entrypoint (e.g. "serve <model> ...")
+	CreatedAt string
+	Status    string
+	Ports     string
+	PID       int
+	ExitCode  int // exit status once the container has stopped
+	Labels    map[string]string
+}
+
+// ContainerProcess is a handle to a container started by a ContainerRuntime.
+type ContainerProcess interface {
+	// ID returns the identifier that can be passed back to Inspect/Stop.
+	ID() string
+	// PID returns the host PID backing the container, or 0 if it is not local.
+	PID() int
+	// Wait blocks until the container exits and returns an error if it did not exit cleanly.
+	Wait() error
+}
+
+// ContainerRuntime is implemented by every container backend the server can drive.
+type ContainerRuntime interface {
+	// Name returns the runtime identifier recorded on jobs (e.g. "podman").
+	Name() string
+	// Start launches a container and streams its output to stdout/stderr until it exits.
+	Start(spec ContainerSpec, stdout, stderr io.Writer) (ContainerProcess, error)
+	// List returns the containers matching the filter.
+	List(filter ContainerFilter) ([]ContainerInfo, error)
+	// Inspect returns the details of a single container.
+	Inspect(id string) (*ContainerInfo, error)
+	// Stop stops a running container.
+	Stop(id string) error
+}
+
+// ContainerEvent is a lifecycle event reported by a runtime (e.g. "start", "died").
+type ContainerEvent struct {
+	ContainerID string
+	Action      string
+	Labels      map[string]string
+	ExitCode    int // -1 when the event carries no exit code
+	Time        time.Time
+}
+
+// ContainerEventSource is implemented by runtimes that can push container events.
+type ContainerEventSource interface {
+	// Events streams events for containers matching filter until the stream ends.
+	Events(filter ContainerFilter, handler func(ContainerEvent)) error
+}
+
+// newContainerRuntime builds the runtime selected with --container-runtime.
+func (srv *ILabServer) newContainerRuntime() (ContainerRuntime, error) {
+	switch srv.containerRuntimeName {
+	case "", "podman":
+		// Prefer the libpod REST API; fall back to the CLI if the podman service isn't running.
+		rt := newPodmanAPIRuntime(srv.containerSocket)
+		if err := rt.ping(); err != nil {
+			srv.log.Warnf("Podman API socket unavailable (%v); falling back to the podman CLI", err)
+			return newPodmanCLIRuntime(srv.log), nil
+		}
+		return rt, nil
+	case "podman-cli":
+		return newPodmanCLIRuntime(srv.log), nil
+	case "docker":
+		return newDockerRuntime(srv.containerSocket, srv.log), nil
+	case "kubernetes":
+		return newKubernetesRuntime(srv.k8sNamespace, srv.k8sPendingTimeout)
+	case "fake":
+		return newFakeRuntime(), nil
+	default:
+		return nil, fmt.Errorf("unknown container runtime '%s'", srv.containerRuntimeName)
+	}
+}
+
+// matchesFilter reports whether a container satisfies the image and label constraints of filter.
+func matchesFilter(info ContainerInfo, filter ContainerFilter) bool {
+	if filter.Image != "" && info.Image != filter.Image {
+		return false
+	}
+	for k, v := range filter.Labels {
+		if info.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// parseByteSize converts sizes such as "10G", "512m" or "1024" into bytes.
+func parseByteSize(size string) (int64, error) {
+	s := strings.TrimSpace(strings.ToLower(size))
+	if s == "" {
+		return 0, nil
+	}
+	s = strings.TrimSuffix(s, "b")
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(s, "m"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(s, "g"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s': %v", size, err)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// curatedDatasetsDir is where derived datasets are written, relative to the datasets directory.
+	curatedDatasetsDir = "curated"
+	// lineageFile sits next to the files of a derived dataset and records how they were made.
+	lineageFile = "lineage.json"
+)
+
+// resourceNamePattern restricts user-chosen names (derived datasets, presets) to a single
+// safe path component.
+var resourceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// CurateSplit describes a train/test split of the curated records.
+type CurateSplit struct {
+	TestRatio float64 `json:"test_ratio"`
+	Seed      int64   `json:"seed"`
+}
+
+// CurateDatasetRequest is the body of POST /data/curate.
+type CurateDatasetRequest struct {
+	Name       string       `json:"name"`
+	Sources    []string     `json:"sources"`
+	IncludeIDs []string     `json:"include_ids,omitempty"`
+	ExcludeIDs []string     `json:"exclude_ids,omitempty"`
+	Dedupe     bool         `json:"dedupe"`
+	Split      *CurateSplit `json:"split,omitempty"`
+}
+
+// LineageSource is one input of a derived dataset.
+type LineageSource struct {
+	Dataset      string `json:"dataset"`
+	Path         string `json:"path"`
+	JobID        string `json:"job_id,omitempty"`
+	Records      int    `json:"records"`
+	InvalidLines int    `json:"invalid_lines"`
+}
+
+// LineageOutput is one file of a derived dataset.
+type LineageOutput struct {
+	Dataset string `json:"dataset"`
+	Path    string `json:"path"`
+	Records int    `json:"records"`
+}
+
+// DatasetLineage records how a derived dataset was produced. It is stored as lineage.json
+// next to the dataset files.
+type DatasetLineage struct {
+	Name       string                   `json:"name"`
+	CreatedAt  time.Time                `json:"created_at"`
+	Sources    []LineageSource          `json:"sources"`
+	IncludeIDs []string                 `json:"include_ids,omitempty"`
+	ExcludeIDs []string                 `json:"exclude_ids,omitempty"`
+	Dedupe     bool                     `json:"dedupe"`
+	Split      *CurateSplit             `json:"split,omitempty"`
+	Excluded   int                      `json:"excluded"`
+	Duplicates int                      `json:"duplicates"`
+	UnknownIDs []string                 `json:"unknown_ids,omitempty"`
+	Outputs    map[string]LineageOutput `json:"outputs"`
+}
+
+// recordContentHash hashes the normalized conversational content of a record: roles and
+// text, lowercased with whitespace collapsed. IDs and metadata are ignored, so the same
+// sample generated twice hashes the same.
+func recordContentHash(record map[string]json.RawMessage) string {
+	text, roles := recordText(record, detectRecordSchema(record))
+	normalized := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	sum := sha256.Sum256([]byte(strings.Join(roles, ",") + "\x00" + normalized))
+	return hex.EncodeToString(sum[:8])
+}
+
+// recordID returns the record's own "id" field if it has one, otherwise its content hash.
+func recordID(record map[string]json.RawMessage) string {
+	if raw, ok := record["id"]; ok {
+		var s string
+		if json.Unmarshal(raw, &s) == nil && s != "" {
+			return s
+		}
+		var n json.Number
+		if json.Unmarshal(raw, &n) == nil {
+			return n.String()
+		}
+	}
+	return recordContentHash(record)
+}
+
+// datasetJobID returns the generate job that produced a dataset, if it lives in a job's output directory.
+func datasetJobID(name string) string {
+	first := strings.SplitN(name, "/", 2)[0]
+	if strings.HasPrefix(first, "g-") && first != name {
+		return first
+	}
+	return ""
+}
+
+// writeJSONLines writes raw JSONL records to path.
+func writeJSONLines(path string, lines [][]byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, line := range lines {
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// splitRecords shuffles record indices with the given seed and assigns round(n*ratio) of them
+// to the test split. Both splits keep the input order.
+func splitRecords(lines [][]byte, split *CurateSplit) (train, test [][]byte) {
+	order := rand.New(rand.NewSource(split.Seed)).Perm(len(lines))
+	testCount := int(float64(len(lines))*split.TestRatio + 0.5)
+	isTest := make([]bool, len(lines))
+	for _, i := range order[:testCount] {
+		isTest[i] = true
+	}
+	for i, line := range lines {
+		if isTest[i] {
+			test = append(test, line)
+		} else {
+			train = append(train, line)
+		}
+	}
+	return train, test
+}
+
+// curateDataset reads the source datasets in order, applies the include/exclude filters and
+// deduplication, and writes the result (optionally split) to dir. Lines that are not valid
+// JSON are dropped and counted per source.
+func curateDataset(req *CurateDatasetRequest, sources []LineageSource, dir string) (*DatasetLineage, error) {
+	include := make(map[string]bool, len(req.IncludeIDs))
+	for _, id := range req.IncludeIDs {
+		include[id] = true
+	}
+	exclude := make(map[string]bool, len(req.ExcludeIDs))
+	for _, id := range req.ExcludeIDs {
+		exclude[id] = true
+	}
+	seenIDs := make(map[string]bool)
+	seenContent := make(map[string]bool)
+
+	lineage := &DatasetLineage{
+		Name:       req.Name,
+		CreatedAt:  time.Now(),
+		IncludeIDs: req.IncludeIDs,
+		ExcludeIDs: req.ExcludeIDs,
+		Dedupe:     req.Dedupe,
+		Split:      req.Split,
+		Outputs:    make(map[string]LineageOutput),
+	}
+	var kept [][]byte
+	for i := range sources {
+		src := &sources[i]
+		err := scanDatasetLines(src.Path, func(_ int, data []byte) bool {
+			var record map[string]json.RawMessage
+			if json.Unmarshal(data, &record) != nil {
+				src.InvalidLines++
+				return true
+			}
+			src.Records++
+			id := recordID(record)
+			seenIDs[id] = true
+			if exclude[id] || (len(include) > 0 && !include[id]) {
+				lineage.Excluded++
+				return true
+			}
+			if req.Dedupe {
+				hash := recordContentHash(record)
+				if seenContent[hash] {
+					lineage.Duplicates++
+					return true
+				}
+				seenContent[hash] = true
+			}
+			kept = append(kept, append([]byte(nil), data...))
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dataset '%s': %v", src.Dataset, err)
+		}
+	}
+	lineage.Sources = sources
+
+	for _, id := range append(append([]string(nil), req.IncludeIDs...), req.ExcludeIDs...) {
+		if !seenIDs[id] {
+			lineage.UnknownIDs = append(lineage.UnknownIDs, id)
+		}
+	}
+	sort.Strings(lineage.UnknownIDs)
+
+	outputs := map[string][][]byte{"train": kept}
+	if req.Split != nil {
+		outputs["train"], outputs["test"] = splitRecords(kept, req.Split)
+	}
+	for split, lines := range outputs {
+		if err := writeJSONLines(filepath.Join(dir, split+".jsonl"), lines); err != nil {
+			return nil, err
+		}
+		lineage.Outputs[split] = LineageOutput{Records: len(lines)}
+	}
+	return lineage, nil
+}
+
+// curateDatasetHandler handles POST /data/curate. It writes a derived dataset to
+// curated/<name>/ under the datasets directory: train.jsonl, test.jsonl when split, and
+// lineage.json.
+func (srv *ILabServer) curateDatasetHandler(w http.ResponseWriter, r *http.Request) {
+	srv.log.Info("POST /data/curate called")
+
+	var req CurateDatasetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		srv.log.Errorf("Error decoding request body: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		req.Name = fmt.Sprintf("curated-%d", time.Now().Unix())
+	}
+	if !resourceNamePattern.MatchString(req.Name) {
+		http.Error(w, fmt.Sprintf("Invalid dataset name '%s'", req.Name), http.StatusBadRequest)
+		return
+	}
+	if len(req.Sources) == 0 {
+		http.Error(w, "At least one source dataset is required", http.StatusBadRequest)
+		return
+	}
+	if req.Split != nil && (req.Split.TestRatio <= 0 || req.Split.TestRatio >= 1) {
+		http.Error(w, "Invalid 'split.test_ratio': must be between 0 and 1", http.StatusBadRequest)
+		return
+	}
+
+	datasetsDir, err := getDatasetsDir()
+	if err != nil {
+		srv.log.Errorf("Error resolving datasets directory: %v", err)
+		http.Error(w, "Failed to resolve datasets directory", http.StatusInternalServerError)
+		return
+	}
+
+	// Sources accept the same references as training: names, absolute paths or generate job IDs
+	sources := make([]LineageSource, 0, len(req.Sources))
+	for _, ref := range req.Sources {
+		path, err := srv.resolveTrainingDataset(ref)
+		if err != nil {
+			srv.writeDatasetError(w, ref, err)
+			return
+		}
+		rel, _ := filepath.Rel(datasetsDir, path)
+		name := filepath.ToSlash(rel)
+		sources = append(sources, LineageSource{Dataset: name, Path: path, JobID: datasetJobID(name)})
+	}
+
+	outputDir := filepath.Join(datasetsDir, curatedDatasetsDir, req.Name)
+	if _, err := os.Stat(outputDir); err == nil {
+		http.Error(w, fmt.Sprintf("Dataset '%s' already exists", req.Name), http.StatusConflict)
+		return
+	}
+
+	// Stage in a hidden directory and rename, so GET /data never lists a partial dataset
+	staging := filepath.Join(filepath.Dir(outputDir), fmt.Sprintf(".curate-%s-%d", req.Name, time.Now().UnixNano()))
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		srv.log.Errorf("Error creating curated dataset directory: %v", err)
+		http.Error(w, "Failed to create dataset directory", http.StatusInternalServerError)
+		return
+	}
+	lineage, err := curateDataset(&req, sources, staging)
+	if err == nil {
+		for split, out := range lineage.Outputs {
+			out.Dataset = filepath.ToSlash(filepath.Join(curatedDatasetsDir, req.Name, split+".jsonl"))
+			out.Path = filepath.Join(outputDir, split+".jsonl")
+			lineage.Outputs[split] = out
+		}
+		var data []byte
+		if data, err = json.MarshalIndent(lineage, "", "  "); err == nil {
+			err = os.WriteFile(filepath.Join(staging, lineageFile), data, 0644)
+		}
+	}
+	if err == nil {
+		err = os.Rename(staging, outputDir)
+	}
+	if err != nil {
+		os.RemoveAll(staging)
+		srv.log.Errorf("Error curating dataset '%s': %v", req.Name, err)
+		http.Error(w, fmt.Sprintf("Failed to curate dataset '%s'", req.Name), http.StatusInternalServerError)
+		return
+	}
+	srv.log.Infof("Curated dataset '%s' from %d sources: %d excluded, %d duplicates, outputs %v",
+		req.Name, len(sources), lineage.Excluded, lineage.Duplicates, lineage.Outputs)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(lineage)
+}
+
+// getDatasetLineageHandler handles GET /data/{dataset}/lineage for datasets created by POST /data/curate.
+func (srv *ILabServer) getDatasetLineageHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["dataset"]
+	srv.log.Infof("GET /data/%s/lineage called", name)
+
+	path, err := resolveDataset(name)
+	if err != nil {
+		srv.writeDatasetError(w, name, err)
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(path), lineageFile))
+	if errors.Is(err, os.ErrNotExist) || !strings.HasPrefix(name, curatedDatasetsDir+"/") {
+		http.Error(w, fmt.Sprintf("Dataset '%s' has no lineage; only curated datasets record one", name), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		srv.log.Errorf("Error reading lineage for '%s': %v", name, err)
+		http.Error(w, "Failed to read lineage", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,553 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	errInvalidDatasetName = errors.New("invalid dataset name")
+	errDatasetNotFound    = errors.New("dataset not found")
+	errDatasetNotReady    = errors.New("generate job has not finished")
+)
+
+// Dataset record schemas.
+const (
+	schemaMessages = "messages" // {"messages": [{"role": ..., "content": ...}], "metadata": ...}
+	schemaSkills   = "skills"   // instruction/input/output, question/answer or system/user/assistant fields
+	schemaUnknown  = "unknown"
+)
+
+const (
+	defaultRecordsLimit = 20
+	maxRecordsLimit     = 500
+	// maxRecordBytes bounds a single JSONL line; SDG records with embedded documents can be large.
+	maxRecordBytes = 64 << 20
+	// maxSourceLabel is how much of a source document is used to label it in stats.
+	maxSourceLabel = 80
+)
+
+// tokenHistogramBounds are the upper bounds of the token length histogram buckets.
+var tokenHistogramBounds = []int{64, 128, 256, 512, 1024, 2048, 4096}
+
+// DatasetRecord is one line of a dataset file.
+type DatasetRecord struct {
+	Line   int             `json:"line"`
+	ID     string          `json:"id,omitempty"`
+	Schema string          `json:"schema,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// DatasetRecordsResponse is returned by GET /data/{dataset}/records.
+type DatasetRecordsResponse struct {
+	Dataset    string          `json:"dataset"`
+	Schema     string          `json:"schema"`
+	Offset     int             `json:"offset"`
+	Limit      int             `json:"limit"`
+	Records    []DatasetRecord `json:"records"`
+	NextOffset *int            `json:"next_offset,omitempty"`
+}
+
+// HistogramBucket counts records whose approximate token length is in [Min, Max).
+type HistogramBucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max,omitempty"` // omitted for the open-ended last bucket
+	Count int `json:"count"`
+}
+
+// DatasetStats is returned by GET /data/{dataset}/stats.
+type DatasetStats struct {
+	Dataset         string            `json:"dataset"`
+	Schema          string            `json:"schema"`
+	Records         int               `json:"records"`
+	InvalidLines    int               `json:"invalid_lines"`
+	Schemas         map[string]int    `json:"schemas"`
+	Roles           map[string]int    `json:"roles,omitempty"`
+	TokenHistogram  []HistogramBucket `json:"token_histogram"`
+	MinTokens       int               `json:"min_tokens"`
+	MaxTokens       int               `json:"max_tokens"`
+	MeanTokens      float64           `json:"mean_tokens"`
+	SourceDocuments map[string]int    `json:"source_documents"`
+}
+
+// datasetMessage is an entry of a messages-format record.
+type datasetMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// resolveDataset validates a dataset name (a path relative to the datasets directory, as
+// listed by GET /data) and returns the file path.
+func resolveDataset(name string) (string, error) {
+	if name == "" || filepath.IsAbs(name) || strings.HasPrefix(name, "-") || !strings.HasSuffix(name, ".jsonl") {
+		return "", errInvalidDatasetName
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." || part == "." || part == "" {
+			return "", errInvalidDatasetName
+		}
+	}
+	datasetsDir, err := getDatasetsDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(datasetsDir, filepath.FromSlash(name))
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", errDatasetNotFound
+	}
+	return path, nil
+}
+
+// writeDatasetError maps resolveDataset errors to HTTP responses.
+func (srv *ILabServer) writeDatasetError(w http.ResponseWriter, name string, err error) {
+	switch {
+	case errors.Is(err, errInvalidDatasetName):
+		http.Error(w, fmt.Sprintf("Invalid dataset name '%s'", name), http.StatusBadRequest)
+	case errors.Is(err, errDatasetNotFound):
+		http.Error(w, fmt.Sprintf("Dataset '%s' not found", name), http.StatusNotFound)
+	case errors.Is(err, errDatasetNotReady):
+		http.Error(w, fmt.Sprintf("Dataset '%s' is not available: %v", name, err), http.StatusConflict)
+	default:
+		srv.log.Errorf("Error resolving dataset '%s': %v", name, err)
+		http.Error(w, "Failed to resolve dataset", http.StatusInternalServerError)
+	}
+}
+
+// scanDatasetLines calls fn for every non-empty line of a JSONL file with its 1-based line number.
+// fn returns false to stop early.
+func scanDatasetLines(path string, fn func(line int, data []byte) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 1<<20), maxRecordBytes)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := scanner.Bytes()
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+		if !fn(line, data) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// detectRecordSchema classifies a decoded record.
+func detectRecordSchema(record map[string]json.RawMessage) string {
+	if raw, ok := record["messages"]; ok {
+		var msgs []datasetMessage
+		if json.Unmarshal(raw, &msgs) == nil {
+			return schemaMessages
+		}
+	}
+	for _, pair := range [][2]string{{"instruction", "output"}, {"question", "answer"}, {"question", "response"}, {"user", "assistant"}} {
+		_, a := record[pair[0]]
+		_, b := record[pair[1]]
+		if a && b {
+			return schemaSkills
+		}
+	}
+	return schemaUnknown
+}
+
+// recordText returns the conversational text of a record, used for token length estimates.
+func recordText(record map[string]json.RawMessage, schema string) (string, []string) {
+	var parts, roles []string
+	switch schema {
+	case schemaMessages:
+		var msgs []datasetMessage
+		_ = json.Unmarshal(record["messages"], &msgs)
+		for _, m := range msgs {
+			parts = append(parts, m.Content)
+			roles = append(roles, m.Role)
+		}
+	default:
+		keys := make([]string, 0, len(record))
+		for k := range record {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if k == "metadata" || k == "id" {
+				continue
+			}
+			var s string
+			if json.Unmarshal(record[k], &s) == nil {
+				parts = append(parts, s)
+			}
+		}
+	}
+	return strings.Join(parts, "\n"), roles
+}
+
+// approxTokens estimates a token count at roughly four characters per token, the usual
+// rule of thumb for English text with Llama-family tokenizers.
+func approxTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// recordSource returns a label for the source document a record was generated from, read
+// from its metadata (which SDG stores as a JSON-encoded string).
+func recordSource(record map[string]json.RawMessage) string {
+	raw, ok := record["metadata"]
+	if !ok {
+		return ""
+	}
+	var meta map[string]interface{}
+	if json.Unmarshal(raw, &meta) != nil {
+		var encoded string
+		if json.Unmarshal(raw, &encoded) != nil || json.Unmarshal([]byte(encoded), &meta) != nil {
+			return ""
+		}
+	}
+	for _, key := range []string{"document_outline", "leaf_node_path", "source", "document", "sdg_document", "dataset"} {
+		if s, ok := meta[key].(string); ok && s != "" {
+			return sourceLabel(s)
+		}
+	}
+	return ""
+}
+
+// sourceLabel shortens long source documents, keeping a hash so distinct documents stay distinct.
+func sourceLabel(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= maxSourceLabel {
+		return s
+	}
+	sum := sha1.Sum([]byte(s))
+	return fmt.Sprintf("%s... [%s]", string([]rune(s)[:maxSourceLabel]), hex.EncodeToString(sum[:4]))
+}
+
+// parseBoundedInt parses an optional non-negative query parameter.
+func parseBoundedInt(value string, def, max int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("must be a non-negative integer")
+	}
+	if max > 0 && n > max {
+		n = max
+	}
+	return n, nil
+}
+
+// getDatasetRecordsHandler handles GET /data/{dataset}/records?offset=&limit=.
+func (srv *ILabServer) getDatasetRecordsHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["dataset"]
+	srv.log.Infof("GET /data/%s/records called", name)
+
+	offset, err := parseBoundedInt(r.URL.Query().Get("offset"), 0, 0)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid 'offset': %v", err), http.StatusBadRequest)
+		return
+	}
+	limit, err := parseBoundedInt(r.URL.Query().Get("limit"), defaultRecordsLimit, maxRecordsLimit)
+	if err != nil || limit == 0 {
+		http.Error(w, "Invalid 'limit': must be a positive integer", http.StatusBadRequest)
+		return
+	}
+
+	path, err := resolveDataset(name)
+	if err != nil {
+		srv.writeDatasetError(w, name, err)
+		return
+	}
+
+	resp := DatasetRecordsResponse{Dataset: name, Offset: offset, Limit: limit, Records: []DatasetRecord{}}
+	schemas := make(map[string]int)
+	index := 0
+	err = scanDatasetLines(path, func(line int, data []byte) bool {
+		if index < offset {
+			index++
+			return true
+		}
+		if len(resp.Records) == limit {
+			// One more record exists past this page
+			next := offset + limit
+			resp.NextOffset = &next
+			return false
+		}
+		index++
+
+		rec := DatasetRecord{Line: line}
+		var record map[string]json.RawMessage
+		if err := json.Unmarshal(data, &record); err != nil {
+			rec.Error = fmt.Sprintf("invalid JSON: %v", err)
+		} else {
+			rec.ID = recordID(record)
+			rec.Schema = detectRecordSchema(record)
+			rec.Data = append(json.RawMessage(nil), data...)
+			schemas[rec.Schema]++
+		}
+		resp.Records = append(resp.Records, rec)
+		return true
+	})
+	if err != nil {
+		srv.log.Errorf("Error reading dataset '%s': %v", path, err)
+		http.Error(w, "Failed to read dataset", http.StatusInternalServerError)
+		return
+	}
+	resp.Schema = dominantSchema(schemas)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+	srv.log.Infof("GET /data/%s/records returned %d records from offset %d", name, len(resp.Records), offset)
+}
+
+// dominantSchema returns the most common schema, or "unknown" if nothing was classified.
+func dominantSchema(schemas map[string]int) string {
+	best, bestCount := schemaUnknown, 0
+	for _, schema := range []string{schemaMessages, schemaSkills, schemaUnknown} {
+		if schemas[schema] > bestCount {
+			best, bestCount = schema, schemas[schema]
+		}
+	}
+	return best
+}
+
+// computeDatasetStats reads a whole dataset file and aggregates its statistics.
+func computeDatasetStats(name, path string) (*DatasetStats, error) {
+	stats := &DatasetStats{
+		Dataset:         name,
+		Schemas:         make(map[string]int),
+		Roles:           make(map[string]int),
+		SourceDocuments: make(map[string]int),
+	}
+	for i, bound := range tokenHistogramBounds {
+		min := 0
+		if i > 0 {
+			min = tokenHistogramBounds[i-1]
+		}
+		stats.TokenHistogram = append(stats.TokenHistogram, HistogramBucket{Min: min, Max: bound})
+	}
+	stats.TokenHistogram = append(stats.TokenHistogram, HistogramBucket{Min: tokenHistogramBounds[len(tokenHistogramBounds)-1]})
+
+	totalTokens := 0
+	err := scanDatasetLines(path, func(_ int, data []byte) bool {
+		var record map[string]json.RawMessage
+		if err := json.Unmarshal(data, &record); err != nil {
+			stats.InvalidLines++
+			return true
+		}
+		stats.Records++
+		schema := detectRecordSchema(record)
+		stats.Schemas[schema]++
+
+		text, roles := recordText(record, schema)
+		for _, role := range roles {
+			stats.Roles[role]++
+		}
+		tokens := approxTokens(text)
+		totalTokens += tokens
+		if stats.Records == 1 || tokens < stats.MinTokens {
+			stats.MinTokens = tokens
+		}
+		if tokens > stats.MaxTokens {
+			stats.MaxTokens = tokens
+		}
+		bucket := len(tokenHistogramBounds)
+		for i, bound := range tokenHistogramBounds {
+			if tokens < bound {
+				bucket = i
+				break
+			}
+		}
+		stats.TokenHistogram[bucket].Count++
+
+		if source := recordSource(record); source != "" {
+			stats.SourceDocuments[source]++
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Schema = dominantSchema(stats.Schemas)
+	if stats.Records > 0 {
+		stats.MeanTokens = float64(totalTokens) / float64(stats.Records)
+	}
+	if len(stats.Roles) == 0 {
+		stats.Roles = nil
+	}
+	return stats, nil
+}
+
+// getDatasetStatsHandler handles GET /data/{dataset}/stats.
+func (srv *ILabServer) getDatasetStatsHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["dataset"]
+	srv.log.Infof("GET /data/%s/stats called", name)
+
+	path, err := resolveDataset(name)
+	if err != nil {
+		srv.writeDatasetError(w, name, err)
+		return
+	}
+
+	stats, err := computeDatasetStats(name, path)
+	if err != nil {
+		srv.log.Errorf("Error reading dataset '%s': %v", path, err)
+		http.Error(w, "Failed to read dataset", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(stats)
+	srv.log.Infof("GET /data/%s/stats => %d records, %d invalid lines", name, stats.Records, stats.InvalidLines)
+}
+
+// Prefixes of the SDG output files training consumes. Single-phase training and the
+// knowledge phase of phased training use the knowledge data; the skills phase the skills data.
+const (
+	trainingDatasetPrefix = "knowledge_train_msgs_"
+	skillsDatasetPrefix   = "skills_train_msgs_"
+)
+
+// latestTrainingDataset returns the newest "knowledge_train_msgs_*.jsonl" file under dir.
+func latestTrainingDataset(dir string) (string, error) {
+	return latestDatasetWithPrefix(dir, trainingDatasetPrefix)
+}
+
+// latestDatasetWithPrefix returns the newest "<prefix>*.jsonl" file under dir.
+func latestDatasetWithPrefix(dir, prefix string) (string, error) {
+	var latest string
+	var latestTime time.Time
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasPrefix(d.Name(), prefix) || !strings.HasSuffix(d.Name(), ".jsonl") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if latest == "" || info.ModTime().After(latestTime) {
+			latest, latestTime = path, info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read dataset directory: %v", err)
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no dataset file found with the prefix '%s' in %s", prefix, dir)
+	}
+	return latest, nil
+}
+
+// generateOutputDir returns the directory a generate job writes its datasets to.
+func generateOutputDir(jobID string) (string, error) {
+	datasetsDir, err := getDatasetsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(datasetsDir, jobID), nil
+}
+
+// generatedDataset returns the training dataset produced by a finished generate job.
+func (srv *ILabServer) generatedDataset(jobID string) (string, error) {
+	return srv.generatedDatasetWithPrefix(jobID, trainingDatasetPrefix)
+}
+
+// generatedDatasetWithPrefix returns the newest "<prefix>*.jsonl" file produced by a
+// finished generate job.
+func (srv *ILabServer) generatedDatasetWithPrefix(jobID, prefix string) (string, error) {
+	job, err := srv.getJob(jobID)
+	if err != nil {
+		return "", err
+	}
+	if job == nil || !strings.HasPrefix(jobID, "g-") {
+		return "", errDatasetNotFound
+	}
+	if job.Status != "finished" {
+		return "", fmt.Errorf("%w: %s is %s", errDatasetNotReady, jobID, job.Status)
+	}
+	outputDir, err := generateJobOutputDir(job)
+	if err != nil {
+		return "", err
+	}
+	path, err := latestDatasetWithPrefix(outputDir, prefix)
+	if err != nil {
+		return "", errDatasetNotFound
+	}
+	return path, nil
+}
+
+// datasetGenerateJob returns the generate job that wrote the dataset file at path, or nil
+// when it wasn't written by a known generate job. When several jobs share an output
+// directory, the newest one that finished is taken.
+func (srv *ILabServer) datasetGenerateJob(path string) *Job {
+	if path == "" {
+		return nil
+	}
+	jobs, err := srv.listAllJobs()
+	if err != nil {
+		srv.log.Errorf("Error listing jobs: %v", err)
+		return nil
+	}
+	var found *Job
+	for _, job := range jobs {
+		if !strings.HasPrefix(job.JobID, "g-") || job.Status != "finished" {
+			continue
+		}
+		outputDir, err := generateJobOutputDir(job)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(outputDir, filepath.Clean(path))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if found == nil || job.StartTime.After(found.StartTime) {
+			found = job
+		}
+	}
+	return found
+}
+
+// resolveTrainingDataset turns a dataset reference from a training request into a file path.
+// The reference is either a generate job ID, whose output is used, or a dataset listed by
+// GET /data, given by name or by absolute path.
+func (srv *ILabServer) resolveTrainingDataset(ref string) (string, error) {
+	if strings.HasPrefix(ref, "g-") {
+		return srv.generatedDataset(ref)
+	}
+	if filepath.IsAbs(ref) {
+		datasetsDir, err := getDatasetsDir()
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(datasetsDir, filepath.Clean(ref))
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", errInvalidDatasetName
+		}
+		ref = filepath.ToSlash(rel)
+	}
+	return resolveDataset(ref)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Bounds for the SDG tuning parameters of POST /data/generate.
+const (
+	maxSDGScaleFactor = 1000
+	minChunkWordCount = 100
+	maxChunkWordCount = 10000
+)
+
+// GenerateDataRequest is the (optional) body of POST /data/generate. Unset fields fall back
+// to the server's --pipeline and to ilab's defaults.
+type GenerateDataRequest struct {
+	Pipeline       string `json:"pipeline,omitempty"`
+	EndpointURL    string `json:"endpoint_url,omitempty"`
+	SDGScaleFactor *int   `json:"sdg_scale_factor,omitempty"`
+	ChunkWordCount *int   `json:"chunk_word_count,omitempty"`
+	NumCPUs        *int   `json:"num_cpus,omitempty"`
+	OutputDir      string `json:"output_dir,omitempty"`
+	BranchName     string `json:"branchName,omitempty"`
+}
+
+// validate checks the request and fills in the pipeline default. It returns an error
+// suitable for a 400 response.
+func (req *GenerateDataRequest) validate(defaultPipeline string) error {
+	if req.Pipeline == "" {
+		req.Pipeline = defaultPipeline
+	}
+	if req.Pipeline == "" {
+		req.Pipeline = "full"
+	}
+	switch req.Pipeline {
+	case "simple", "full", "accelerated":
+		// Valid
+	default:
+		return fmt.Errorf("'pipeline' must be 'simple', 'accelerated' or 'full'; got '%s'", req.Pipeline)
+	}
+
+	if req.EndpointURL != "" {
+		u, err := url.Parse(req.EndpointURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("'endpoint_url' must be an http or https URL; got '%s'", req.EndpointURL)
+		}
+	}
+	if req.SDGScaleFactor != nil && (*req.SDGScaleFactor < 1 || *req.SDGScaleFactor > maxSDGScaleFactor) {
+		return fmt.Errorf("'sdg_scale_factor' must be between 1 and %d", maxSDGScaleFactor)
+	}
+	if req.ChunkWordCount != nil && (*req.ChunkWordCount < minChunkWordCount || *req.ChunkWordCount > maxChunkWordCount) {
+		return fmt.Errorf("'chunk_word_count' must be between %d and %d", minChunkWordCount, maxChunkWordCount)
+	}
+	if req.NumCPUs != nil && (*req.NumCPUs < 1 || *req.NumCPUs > runtime.NumCPU()) {
+		return fmt.Errorf("'num_cpus' must be between 1 and %d", runtime.NumCPU())
+	}
+	if req.OutputDir != "" {
+		if _, err := resolveGenerateOutputDir(req.OutputDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveGenerateOutputDir validates a requested output directory, a path relative to the
+// datasets directory, and returns its absolute path. The directory must not hold any files
+// yet, so the job's output can be told apart from older data.
+func resolveGenerateOutputDir(dir string) (string, error) {
+	if filepath.IsAbs(dir) {
+		return "", fmt.Errorf("'output_dir' must be relative to the datasets directory")
+	}
+	for _, part := range strings.Split(dir, "/") {
+		if part == "" || part == "." || part == ".." || strings.HasPrefix(part, ".") || strings.HasPrefix(part, "-") {
+			return "", fmt.Errorf("invalid 'output_dir' '%s'", dir)
+		}
+	}
+	datasetsDir, err := getDatasetsDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(datasetsDir, filepath.FromSlash(dir))
+	if entries, err := os.ReadDir(path); err == nil && len(entries) > 0 {
+		return "", fmt.Errorf("'output_dir' '%s' already contains data", dir)
+	}
+	return path, nil
+}
+
+// generateArgs builds the "ilab data generate" arguments for a validated request.
+func (req *GenerateDataRequest) generateArgs(outputDir string) []string {
+	args := []string{"data", "generate", "--pipeline", req.Pipeline, fmt.Sprintf("--output-dir=%s", outputDir)}
+	if req.EndpointURL != "" {
+		args = append(args, fmt.Sprintf("--endpoint-url=%s", req.EndpointURL))
+	}
+	if req.SDGScaleFactor != nil {
+		args = append(args, fmt.Sprintf("--sdg-scale-factor=%d", *req.SDGScaleFactor))
+	}
+	if req.ChunkWordCount != nil {
+		args = append(args, fmt.Sprintf("--chunk-word-count=%d", *req.ChunkWordCount))
+	}
+	if req.NumCPUs != nil {
+		args = append(args, fmt.Sprintf("--num-cpus=%d", *req.NumCPUs))
+	}
+	return args
+}
+
+// generateJobOutputDir returns the --output-dir a generate job was started with, falling
+// back to the per-job default.
+func generateJobOutputDir(job *Job) (string, error) {
+	for _, arg := range job.Args {
+		if strings.HasPrefix(arg, "--output-dir=") {
+			return strings.TrimPrefix(arg, "--output-dir="), nil
+		}
+	}
+	return generateOutputDir(job.JobID)
+}
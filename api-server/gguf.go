@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// ggufMagic is the first four bytes of every GGUF file ("GGUF", little endian).
+const ggufMagic = 0x46554747
+
+// GGUF metadata value types.
+const (
+	ggufTypeUint8 uint32 = iota
+	ggufTypeInt8
+	ggufTypeUint16
+	ggufTypeInt16
+	ggufTypeUint32
+	ggufTypeInt32
+	ggufTypeFloat32
+	ggufTypeBool
+	ggufTypeString
+	ggufTypeArray
+	ggufTypeUint64
+	ggufTypeInt64
+	ggufTypeFloat64
+)
+
+// ggufMaxStringLen bounds metadata strings so a corrupt header can't make us allocate gigabytes.
+const ggufMaxStringLen = 1 << 20
+
+var errNotGGUF = errors.New("not a GGUF file")
+
+// ggufFileTypes maps general.file_type (llama.cpp's llama_ftype) to its quantization name.
+var ggufFileTypes = map[uint32]string{
+	0: "F32", 1: "F16", 2: "Q4_0", 3: "Q4_1", 7: "Q8_0", 8: "Q5_0", 9: "Q5_1",
+	10: "Q2_K", 11: "Q3_K_S", 12: "Q3_K_M", 13: "Q3_K_L", 14: "Q4_K_S", 15: "Q4_K_M",
+	16: "Q5_K_S", 17: "Q5_K_M", 18: "Q6_K", 19: "IQ2_XXS", 20: "IQ2_XS", 21: "Q2_K_S",
+	22: "IQ3_XS", 23: "IQ3_XXS", 24: "IQ1_S", 25: "IQ4_NL", 26: "IQ3_S", 27: "IQ3_M",
+	28: "IQ2_S", 29: "IQ2_M", 30: "IQ4_XS", 31: "IQ1_M", 32: "BF16",
+}
+
+// GGUFInfo is the subset of GGUF metadata the server reports.
+type GGUFInfo struct {
+	Version      uint32
+	Name         string
+	Architecture string
+	Quantization string
+}
+
+// ggufReader reads little-endian GGUF primitives.
+type ggufReader struct {
+	r       *bufio.Reader
+	version uint32
+}
+
+func (g *ggufReader) u32() (uint32, error) {
+	var v uint32
+	err := binary.Read(g.r, binary.LittleEndian, &v)
+	return v, err
+}
+
+func (g *ggufReader) u64() (uint64, error) {
+	var v uint64
+	err := binary.Read(g.r, binary.LittleEndian, &v)
+	return v, err
+}
+
+// count reads a length or count, which GGUF v1 stores as uint32 and later versions as uint64.
+func (g *ggufReader) count() (uint64, error) {
+	if g.version == 1 {
+		v, err := g.u32()
+		return uint64(v), err
+	}
+	return g.u64()
+}
+
+func (g *ggufReader) str() (string, error) {
+	n, err := g.count()
+	if err != nil {
+		return "", err
+	}
+	if n > ggufMaxStringLen {
+		return "", fmt.Errorf("gguf string of %d bytes exceeds limit", n)
+	}
+	buf := make([]byte, n)
+	_, err = io.ReadFull(g.r, buf)
+	return string(buf), err
+}
+
+func (g *ggufReader) skip(n uint64) error {
+	_, err := g.r.Discard(int(n))
+	return err
+}
+
+// ggufScalarSize returns the encoded size of a fixed-width value type.
+func ggufScalarSize(t uint32) (uint64, bool) {
+	switch t {
+	case ggufTypeUint8, ggufTypeInt8, ggufTypeBool:
+		return 1, true
+	case ggufTypeUint16, ggufTypeInt16:
+		return 2, true
+	case ggufTypeUint32, ggufTypeInt32, ggufTypeFloat32:
+		return 4, true
+	case ggufTypeUint64, ggufTypeInt64, ggufTypeFloat64:
+		return 8, true
+	}
+	return 0, false
+}
+
+// skipValue skips over a metadata value of type t.
+func (g *ggufReader) skipValue(t uint32) error {
+	if size, ok := ggufScalarSize(t); ok {
+		return g.skip(size)
+	}
+	switch t {
+	case ggufTypeString:
+		n, err := g.count()
+		if err != nil {
+			return err
+		}
+		return g.skip(n)
+	case ggufTypeArray:
+		elemType, err := g.u32()
+		if err != nil {
+			return err
+		}
+		n, err := g.count()
+		if err != nil {
+			return err
+		}
+		if size, ok := ggufScalarSize(elemType); ok {
+			if n > math.MaxInt64/size {
+				return fmt.Errorf("gguf array of %d elements is too large", n)
+			}
+			return g.skip(n * size)
+		}
+		for i := uint64(0); i < n; i++ {
+			if err := g.skipValue(elemType); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown gguf value type %d", t)
+}
+
+// readGGUFInfo reads the metadata header of a GGUF file. It stops as soon as the
+// architecture, name and file type are known, so tensor data is never read.
+func readGGUFInfo(path string) (*GGUFInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	g := &ggufReader{r: bufio.NewReaderSize(f, 1<<16)}
+	magic, err := g.u32()
+	if err != nil || magic != ggufMagic {
+		return nil, errNotGGUF
+	}
+	if g.version, err = g.u32(); err != nil {
+		return nil, err
+	}
+	if _, err := g.count(); err != nil { // tensor count
+		return nil, err
+	}
+	kvCount, err := g.count()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &GGUFInfo{Version: g.version}
+	haveFileType := false
+	for i := uint64(0); i < kvCount; i++ {
+		key, err := g.str()
+		if err != nil {
+			return nil, err
+		}
+		valueType, err := g.u32()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case key == "general.architecture" && valueType == ggufTypeString:
+			info.Architecture, err = g.str()
+		case key == "general.name" && valueType == ggufTypeString:
+			info.Name, err = g.str()
+		case key == "general.file_type" && valueType == ggufTypeUint32:
+			var ft uint32
+			if ft, err = g.u32(); err == nil {
+				haveFileType = true
+				info.Quantization = ggufFileTypes[ft]
+				if info.Quantization == "" {
+					info.Quantization = fmt.Sprintf("file_type_%d", ft)
+				}
+			}
+		default:
+			err = g.skipValue(valueType)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if info.Architecture != "" && info.Name != "" && haveFileType {
+			break
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ggufBuilder writes a GGUF header for tests.
+type ggufBuilder struct {
+	buf     bytes.Buffer
+	version uint32
+}
+
+func newGGUFBuilder(version uint32, kvCount uint64) *ggufBuilder {
+	b := &ggufBuilder{version: version}
+	b.u32(ggufMagic)
+	b.u32(version)
+	b.count(3) // tensors
+	b.count(kvCount)
+	return b
+}
+
+func (b *ggufBuilder) u32(v uint32) { _ = binary.Write(&b.buf, binary.LittleEndian, v) }
+func (b *ggufBuilder) u64(v uint64) { _ = binary.Write(&b.buf, binary.LittleEndian, v) }
+
+func (b *ggufBuilder) count(n uint64) {
+	if b.version == 1 {
+		b.u32(uint32(n))
+		return
+	}
+	b.u64(n)
+}
+
+func (b *ggufBuilder) str(s string) {
+	b.count(uint64(len(s)))
+	b.buf.WriteString(s)
+}
+
+// kv writes a key and the type of its value; the caller writes the value.
+func (b *ggufBuilder) kv(key string, valueType uint32) *ggufBuilder {
+	b.str(key)
+	b.u32(valueType)
+	return b
+}
+
+func (b *ggufBuilder) write(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "model.gguf")
+	if err := os.WriteFile(path, b.buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadGGUFInfo(t *testing.T) {
+	for _, version := range []uint32{1, 3} {
+		b := newGGUFBuilder(version, 7)
+		b.kv("general.architecture", ggufTypeString).str("llama")
+		// Arrays of fixed-width values, of strings and of arrays are skipped
+		b.kv("tokenizer.ggml.scores", ggufTypeArray).u32(ggufTypeFloat32)
+		b.count(3)
+		b.buf.Write(make([]byte, 12))
+		b.kv("tokenizer.ggml.tokens", ggufTypeArray).u32(ggufTypeString)
+		b.count(2)
+		b.str("<s>")
+		b.str("</s>")
+		b.kv("nested", ggufTypeArray).u32(ggufTypeArray)
+		b.count(1)
+		b.u32(ggufTypeUint16)
+		b.count(2)
+		b.buf.Write(make([]byte, 4))
+		b.kv("llama.context_length", ggufTypeUint64).u64(4096)
+		b.kv("general.name", ggufTypeString).str("granite-7b-lab")
+		b.kv("general.file_type", ggufTypeUint32).u32(15)
+
+		info, err := readGGUFInfo(b.write(t))
+		if err != nil {
+			t.Fatalf("v%d: %v", version, err)
+		}
+		want := GGUFInfo{Version: version, Name: "granite-7b-lab", Architecture: "llama", Quantization: "Q4_K_M"}
+		if *info != want {
+			t.Errorf("v%d: got %+v, want %+v", version, *info, want)
+		}
+	}
+}
+
+func TestReadGGUFInfoFileType(t *testing.T) {
+	for _, tc := range []struct {
+		fileType uint32
+		want     string
+	}{
+		{0, "F32"},
+		{1, "F16"},
+		{7, "Q8_0"},
+		{15, "Q4_K_M"},
+		{32, "BF16"},
+		{99, "file_type_99"},
+	} {
+		b := newGGUFBuilder(3, 1)
+		b.kv("general.file_type", ggufTypeUint32).u32(tc.fileType)
+		info, err := readGGUFInfo(b.write(t))
+		if err != nil {
+			t.Fatalf("file type %d: %v", tc.fileType, err)
+		}
+		if info.Quantization != tc.want {
+			t.Errorf("file type %d: quantization = %q, want %q", tc.fileType, info.Quantization, tc.want)
+		}
+	}
+}
+
+func TestReadGGUFInfoStopsEarly(t *testing.T) {
+	b := newGGUFBuilder(3, 1000)
+	b.kv("general.name", ggufTypeString).str("granite")
+	b.kv("general.file_type", ggufTypeUint32).u32(1)
+	b.kv("general.architecture", ggufTypeString).str("llama")
+	// Nothing past the three keys is read: an unknown value type, then the end of the file
+	b.kv("corrupt", 99)
+
+	info, err := readGGUFInfo(b.write(t))
+	if err != nil {
+		t.Fatalf("readGGUFInfo: %v", err)
+	}
+	if info.Name != "granite" || info.Architecture != "llama" || info.Quantization != "F16" {
+		t.Errorf("got %+v", *info)
+	}
+}
+
+func TestReadGGUFInfoRejectsCorruptHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		build   func() *ggufBuilder
+		wantErr string
+	}{
+		{
+			name: "oversized string",
+			build: func() *ggufBuilder {
+				b := newGGUFBuilder(3, 1)
+				b.u64(ggufMaxStringLen + 1)
+				return b
+			},
+			wantErr: "exceeds limit",
+		},
+		{
+			name: "huge v1 string length",
+			build: func() *ggufBuilder {
+				b := newGGUFBuilder(1, 1)
+				b.u32(1 << 31)
+				return b
+			},
+			wantErr: "exceeds limit",
+		},
+		{
+			name: "truncated string",
+			build: func() *ggufBuilder {
+				b := newGGUFBuilder(3, 1)
+				b.kv("general.name", ggufTypeString)
+				b.u64(100)
+				b.buf.WriteString("short")
+				return b
+			},
+			wantErr: "unexpected EOF",
+		},
+		{
+			name: "overflowing array",
+			build: func() *ggufBuilder {
+				b := newGGUFBuilder(3, 2)
+				b.kv("tokenizer.ggml.scores", ggufTypeArray).u32(ggufTypeFloat32)
+				b.u64(1 << 62)
+				b.kv("general.name", ggufTypeString).str("granite")
+				return b
+			},
+			wantErr: "too large",
+		},
+		{
+			name: "unknown value type",
+			build: func() *ggufBuilder {
+				b := newGGUFBuilder(3, 1)
+				b.kv("general.weird", 99)
+				return b
+			},
+			wantErr: "unknown gguf value type 99",
+		},
+		{
+			name: "missing key-value pairs",
+			build: func() *ggufBuilder {
+				return newGGUFBuilder(3, 2)
+			},
+			wantErr: "EOF",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readGGUFInfo(tc.build().write(t))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("err = %v, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+
+	notGGUF := filepath.Join(t.TempDir(), "model.bin")
+	if err := os.WriteFile(notGGUF, []byte("PK\x03\x04 not a gguf file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readGGUFInfo(notGGUF); !errors.Is(err, errNotGGUF) {
+		t.Errorf("non-GGUF file: %v, want errNotGGUF", err)
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -19,62 +21,12 @@ import (
 // HTTP Handlers
 // -----------------------------------------------------------------------------
 
-// getModelsHandler is the HTTP handler for the /models endpoint.
-func (srv *ILabServer) getModelsHandler(w http.ResponseWriter, r *http.Request) {
-	srv.log.Info("GET /models called")
-
-	srv.modelCache.Mutex.Lock()
-	cachedTime := srv.modelCache.Time
-	cachedModels := make([]Model, len(srv.modelCache.Models))
-	copy(cachedModels, srv.modelCache.Models)
-	srv.modelCache.Mutex.Unlock()
-
-	if len(cachedModels) > 0 && time.Since(cachedTime) < 20*time.Minute {
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(cachedModels); err != nil {
-			srv.log.Errorf("Error encoding cached models: %v", err)
-			http.Error(w, "Failed to encode models", http.StatusInternalServerError)
-			return
-		}
-		srv.log.Info("GET /models returned cached models.")
-		return
-	}
-
-	srv.log.Info("Cache is empty or stale. Refreshing model cache now...")
-	srv.refreshModelCache()
-
-	srv.modelCache.Mutex.Lock()
-	cachedTime = srv.modelCache.Time
-	cachedModels = make([]Model, len(srv.modelCache.Models))
-	copy(cachedModels, srv.modelCache.Models)
-	srv.modelCache.Mutex.Unlock()
-
-	if len(cachedModels) > 0 {
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(cachedModels); err != nil {
-			srv.log.Errorf("Error encoding refreshed models: %v", err)
-			http.Error(w, "Failed to encode models", http.StatusInternalServerError)
-			return
-		}
-		srv.log.Info("GET /models returned refreshed models.")
-	} else {
-		http.Error(w, "Failed to retrieve models", http.StatusInternalServerError)
-		srv.log.Info("GET /models failed to retrieve models.")
-	}
-}
-
 // getDataHandler is the HTTP handler for the /data endpoint.
 func (srv *ILabServer) getDataHandler(w http.ResponseWriter, r *http.Request) {
 	srv.log.Info("GET /data called")
-	output, err := srv.runIlabCommand("data", "list")
+	dataList, err := srv.listDatasets()
 	if err != nil {
-		srv.log.Errorf("Error running 'ilab data list': %v", err)
-		http.Error(w, string(output), http.StatusInternalServerError)
-		return
-	}
-	dataList, err := srv.parseDataList(output)
-	if err != nil {
-		srv.log.Errorf("Error parsing data list: %v", err)
+		srv.log.Errorf("Error listing datasets: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -86,7 +38,26 @@ func (srv *ILabServer) getDataHandler(w http.ResponseWriter, r *http.Request) {
 // generateDataHandler is the HTTP handler for the /data/generate endpoint.
 func (srv *ILabServer) generateDataHandler(w http.ResponseWriter, r *http.Request) {
 	srv.log.Info("POST /data/generate called")
-	jobID, err := srv.startGenerateJob()
+
+	// The body is optional; an empty body runs generation with the server defaults
+	var reqBody GenerateDataRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil && err != io.EOF {
+		srv.log.Errorf("Error parsing request body: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := reqBody.validate(srv.pipelineType); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if reqBody.BranchName != "" {
+		if err := srv.validateBranch(reqBody.BranchName); err != nil {
+			srv.writeBranchError(w, reqBody.BranchName, err)
+			return
+		}
+	}
+
+	jobID, err := srv.startGenerateJob(&reqBody)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -101,9 +72,14 @@ func (srv *ILabServer) trainModelHandler(w http.ResponseWriter, r *http.Request)
 	srv.log.Info("POST /model/train called")
 
 	var reqBody struct {
-		ModelName  string `json:"modelName"`
-		BranchName string `json:"branchName"`
-		Epochs     *int   `json:"epochs,omitempty"`
+		ModelName       string          `json:"modelName"`
+		BranchName      string          `json:"branchName"`
+		Epochs          *int            `json:"epochs,omitempty"`
+		Dataset         string          `json:"dataset,omitempty"` // Optional: dataset name/path from GET /data, or a generate job ID
+		Preset          string          `json:"preset,omitempty"`
+		Hyperparameters json.RawMessage `json:"hyperparameters,omitempty"` // a TrainingSpec, decoded strictly
+		// Optional: checkpoint name from GET /checkpoints to continue training from
+		ResumeFromCheckpoint string `json:"resume_from_checkpoint,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
 		srv.log.Errorf("Error parsing request body: %v", err)
@@ -111,9 +87,9 @@ func (srv *ILabServer) trainModelHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if reqBody.ModelName == "" || reqBody.BranchName == "" {
-		srv.log.Info("Missing required parameters: modelName or branchName")
-		http.Error(w, "Missing required parameters: modelName or branchName", http.StatusBadRequest)
+	if reqBody.BranchName == "" {
+		srv.log.Info("Missing required parameter: branchName")
+		http.Error(w, "Missing required parameter: branchName", http.StatusBadRequest)
 		return
 	}
 	if reqBody.Epochs != nil && *reqBody.Epochs <= 0 {
@@ -121,23 +97,111 @@ func (srv *ILabServer) trainModelHandler(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "'epochs' must be a positive integer", http.StatusBadRequest)
 		return
 	}
+	spec, err := srv.resolveTrainingSpec(reqBody.Preset, reqBody.Hyperparameters, reqBody.Epochs)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errTrainingPresetNotFound) {
+			status = http.StatusNotFound
+		}
+		srv.log.Infof("Invalid training spec: %v", err)
+		http.Error(w, fmt.Sprintf("Invalid training spec: %v", err), status)
+		return
+	}
 
-	sanitizedModelName := srv.sanitizeModelName(reqBody.ModelName)
-	srv.log.Infof("Sanitized modelName: '%s'", sanitizedModelName)
+	var resumeFrom *CheckpointInfo
+	var sanitizedModelName string
+	if reqBody.ResumeFromCheckpoint != "" {
+		resumeFrom, err = srv.resolveResumeCheckpoint(reqBody.ResumeFromCheckpoint)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errCheckpointNotFound) {
+				status = http.StatusNotFound
+			}
+			srv.log.Infof("Cannot resume from checkpoint '%s': %v", reqBody.ResumeFromCheckpoint, err)
+			http.Error(w, fmt.Sprintf("Cannot resume from checkpoint '%s': %v", reqBody.ResumeFromCheckpoint, err), status)
+			return
+		}
+		// Without 'epochs', run the epochs the checkpoint's job had left rather than a full count
+		if spec.NumEpochs == nil {
+			remaining, err := srv.remainingEpochs(resumeFrom)
+			if err != nil {
+				srv.log.Infof("Cannot resume from checkpoint '%s': %v", reqBody.ResumeFromCheckpoint, err)
+				http.Error(w, fmt.Sprintf("Cannot resume from checkpoint '%s': %v", reqBody.ResumeFromCheckpoint, err), http.StatusBadRequest)
+				return
+			}
+			if remaining > 0 {
+				spec.NumEpochs = &remaining
+				srv.log.Infof("Resuming with the %d epoch(s) job '%s' had left", remaining, resumeFrom.JobID)
+			}
+		}
+		// The checkpoint takes the place of the base model
+		if reqBody.ModelName != "" {
+			srv.log.Infof("Resuming from checkpoint '%s'; ignoring modelName '%s'", resumeFrom.Name, reqBody.ModelName)
+		}
+		sanitizedModelName = resumeFrom.BaseModel
+	} else {
+		if reqBody.ModelName == "" {
+			reqBody.ModelName = srv.defaultBaseModel()
+			srv.log.Infof("No modelName provided. Using the default base model: %s", reqBody.ModelName)
+		}
 
-	// Git checkout
-	gitCheckoutCmd := exec.Command("git", "checkout", reqBody.BranchName)
-	gitCheckoutCmd.Dir = srv.taxonomyPath
-	gitOutput, err := gitCheckoutCmd.CombinedOutput()
-	srv.log.Infof("Git checkout output: %s", string(gitOutput))
-	if err != nil {
-		srv.log.Errorf("Error checking out branch '%s': %v", reqBody.BranchName, err)
-		http.Error(w, fmt.Sprintf("Failed to checkout branch '%s': %s", reqBody.BranchName, string(gitOutput)), http.StatusInternalServerError)
+		sanitizedModelName, _, err = srv.resolveModel(reqBody.ModelName)
+		if err != nil {
+			srv.writeModelError(w, reqBody.ModelName, err)
+			return
+		}
+		srv.log.Infof("Sanitized modelName: '%s'", sanitizedModelName)
+	}
+
+	var dataPath string
+	if reqBody.Dataset == "" && resumeFrom != nil && resumeFrom.JobID != "" {
+		// Resume on the data the checkpoint was trained on, if it is still there
+		if parent, err := srv.getJob(resumeFrom.JobID); err == nil && parent != nil && parent.Dataset != "" {
+			if _, err := os.Stat(parent.Dataset); err == nil {
+				dataPath = parent.Dataset
+				srv.log.Infof("Resuming on the dataset of job '%s': '%s'", parent.JobID, dataPath)
+			}
+		}
+	}
+	if reqBody.Dataset != "" {
+		dataPath, err = srv.resolveTrainingDataset(reqBody.Dataset)
+		if err != nil {
+			srv.writeDatasetError(w, reqBody.Dataset, err)
+			return
+		}
+		srv.log.Infof("Training on dataset: '%s'", dataPath)
+	}
+
+	trainReq := &TrainJobRequest{
+		ModelName:  sanitizedModelName,
+		BranchName: reqBody.BranchName,
+		Spec:       spec,
+		DataPath:   dataPath,
+		ResumeFrom: resumeFrom,
+	}
+
+	if err := srv.validateBranch(reqBody.BranchName); err != nil {
+		srv.writeBranchError(w, reqBody.BranchName, err)
 		return
 	}
-	srv.log.Infof("Successfully checked out branch: '%s'", reqBody.BranchName)
 
-	jobID, err := srv.startTrainJob(sanitizedModelName, reqBody.BranchName, reqBody.Epochs)
+	// A dry run reports the command without starting the job
+	if r.URL.Query().Get("dry_run") == "true" {
+		plan, err := srv.buildTrainCommand(trainReq, dryRunJobID)
+		if err != nil {
+			srv.log.Errorf("Error building train command: %v", err)
+			http.Error(w, fmt.Sprintf("Failed to build train command: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(plan)
+		srv.log.Infof("POST /model/train dry run: %s", plan.Command)
+		return
+	}
+
+	// Training reads no taxonomy files, so the branch is only recorded on the job; the
+	// shared taxonomy working copy is not checked out
+	jobID, err := srv.startTrainJob(trainReq)
 	if err != nil {
 		srv.log.Errorf("Error starting train job: %v", err)
 		http.Error(w, "Failed to start train job", http.StatusInternalServerError)
@@ -364,13 +428,51 @@ func (srv *ILabServer) getJobStatusHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+	response := map[string]interface{}{
 		"job_id":  job.JobID,
 		"status":  job.Status,
 		"branch":  job.Branch,
 		"command": job.Cmd,
-	})
+	}
+	if job.Dataset != "" {
+		response["dataset"] = job.Dataset
+	}
+	if job.TrainSpec != nil {
+		response["train_spec"] = job.TrainSpec
+	}
+	if job.ParentJobID != "" {
+		response["parent_job_id"] = job.ParentJobID
+	}
+	if job.ResumeCheckpoint != "" {
+		response["resume_checkpoint"] = job.ResumeCheckpoint
+	}
+	if job.Phase != "" {
+		response["phase"] = job.Phase
+	}
+	if job.CommitSHA != "" {
+		response["commit_sha"] = job.CommitSHA
+		response["taxonomy_dirty"] = job.TaxonomyDirty
+	}
+	if strings.HasPrefix(job.JobID, "pt-") {
+		if phases, err := srv.trainingPhases(job.JobID); err == nil {
+			response["phases"] = phases
+		} else {
+			srv.log.Errorf("Error listing the phases of job %s: %v", job.JobID, err)
+		}
+	}
+	if strings.HasPrefix(job.JobID, "d-") {
+		if progress, err := parseDownloadProgress(job.LogFile); err == nil {
+			if job.Status == "finished" {
+				progress.Percent = 100
+			}
+			response["progress"] = progress
+		}
+	} else if progress, ok, err := srv.jobProgress(job); ok && err == nil {
+		response["progress"] = progress
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
 	srv.log.Infof("GET /jobs/%s/status successful, status: %s", jobID, job.Status)
 }
 
@@ -425,44 +527,40 @@ func (srv *ILabServer) listJobsHandler(w http.ResponseWriter, r *http.Request) {
 // -----------------------------------------------------------------------------
 
 // listCheckpointsHandler is the HTTP handler for the /checkpoints endpoint.
+// An optional job_id query parameter limits the list to checkpoints produced by that training job.
 func (srv *ILabServer) listCheckpointsHandler(w http.ResponseWriter, r *http.Request) {
-	srv.log.Info("GET /checkpoints called")
-
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		srv.log.Errorf("Error getting user home directory: %v", err)
-		http.Error(w, "Failed to get user home directory", http.StatusInternalServerError)
-		return
-	}
-
-	checkpointsDir := filepath.Join(homeDir, ".local", "share", "instructlab", "checkpoints", "hf_format")
-	if _, err := os.Stat(checkpointsDir); os.IsNotExist(err) {
-		srv.log.Infof("Checkpoints directory does not exist: %s", checkpointsDir)
-		http.Error(w, "Checkpoints directory does not exist", http.StatusNotFound)
-		return
-	}
+	jobID := r.URL.Query().Get("job_id")
+	srv.log.Infof("GET /checkpoints called, job_id=%q", jobID)
 
-	entries, err := ioutil.ReadDir(checkpointsDir)
+	checkpoints, err := srv.listCheckpoints()
 	if err != nil {
-		srv.log.Errorf("Error reading checkpoints directory: %v", err)
-		http.Error(w, "Failed to read checkpoints directory", http.StatusInternalServerError)
+		if os.IsNotExist(err) {
+			srv.log.Infof("Checkpoints directory does not exist: %v", err)
+			http.Error(w, "Checkpoints directory does not exist", http.StatusNotFound)
+			return
+		}
+		srv.log.Errorf("Error listing checkpoints: %v", err)
+		http.Error(w, "Failed to list checkpoints", http.StatusInternalServerError)
 		return
 	}
 
-	var directories []string
-	for _, entry := range entries {
-		if entry.IsDir() {
-			directories = append(directories, entry.Name())
+	if jobID != "" {
+		filtered := []CheckpointInfo{}
+		for _, cp := range checkpoints {
+			if cp.JobID == jobID {
+				filtered = append(filtered, cp)
+			}
 		}
+		checkpoints = filtered
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(directories); err != nil {
-		srv.log.Errorf("Error encoding directories to JSON: %v", err)
-		http.Error(w, "Failed to encode directories", http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(checkpoints); err != nil {
+		srv.log.Errorf("Error encoding checkpoints to JSON: %v", err)
+		http.Error(w, "Failed to encode checkpoints", http.StatusInternalServerError)
 		return
 	}
-	srv.log.Infof("GET /checkpoints successful, %d directories returned", len(directories))
+	srv.log.Infof("GET /checkpoints successful, %d checkpoints returned", len(checkpoints))
 }
 
 // -----------------------------------------------------------------------------
@@ -498,29 +596,41 @@ func (srv *ILabServer) runQnaEval(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cmd := exec.Command("podman", "run", "--rm",
-		"--device", "nvidia.com/gpu=all",
-		"-v", fmt.Sprintf("%s:%s", homeDir, homeDir),
-		"quay.io/bsalisbu/qna-eval",
-		"--model_path", req.ModelPath,
-		"--yaml_file", req.YamlFile,
-	)
+	spec := ContainerSpec{
+		Name:    fmt.Sprintf("qna-eval-%d", time.Now().UnixNano()),
+		Image:   qnaEvalImage,
+		GPUs:    []string{"all"},
+		Volumes: []VolumeMount{{HostPath: homeDir, ContainerPath: homeDir}},
+		Labels:  srv.containerLabels(roleQnaEval),
+		Remove:  true,
+		Args: []string{
+			"--model_path", req.ModelPath,
+			"--yaml_file", req.YamlFile,
+		},
+	}
 
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
 
-	srv.log.Infof("Executing Podman command: %v", cmd.Args)
-	err = cmd.Run()
+	srv.log.Infof("Running %s container %s: %v", srv.containerRuntime.Name(), spec.Image, spec.Args)
+	proc, err := srv.containerRuntime.Start(spec, &stdout, &stderr)
+	if err == nil {
+		err = proc.Wait()
+	}
 	if err != nil {
-		srv.log.Errorf("Podman command failed: %v, stderr: %s", err, stderr.String())
-		response := map[string]string{"error": stderr.String()}
+		srv.log.Errorf("Container command failed: %v, stderr: %s", err, stderr.String())
+		errMsg := stderr.String()
+		if errMsg == "" {
+			errMsg = err.Error()
+		}
+		response := map[string]string{"error": errMsg}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		_ = json.NewEncoder(w).Encode(response)
 		return
 	}
 
+	srv.recordCheckpointEvaluation(req.ModelPath, "qna-eval", stdout.String())
+
 	response := map[string]string{
 		"result": stdout.String(),
 	}
@@ -545,16 +655,16 @@ func (srv *ILabServer) serveLatestCheckpointHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
-	homeDir, err := os.UserHomeDir()
+	checkpointsDir, err := getCheckpointsDir()
 	if err != nil {
 		srv.log.Errorf("Error getting user home directory: %v", err)
 		http.Error(w, "Failed to get home directory", http.StatusInternalServerError)
 		return
 	}
 
-	checkpointsDir := filepath.Join(homeDir, ".local", "share", "instructlab", "checkpoints", "hf_format")
-	if _, err := os.Stat(checkpointsDir); os.IsNotExist(err) {
-		srv.log.Errorf("Checkpoints directory does not exist: %s", checkpointsDir)
+	// The shared checkpoints directory and the job output directories share this parent
+	if _, err := os.Stat(filepath.Dir(checkpointsDir)); os.IsNotExist(err) {
+		srv.log.Errorf("Checkpoints directory does not exist: %s", filepath.Dir(checkpointsDir))
 		http.Error(w, "Checkpoints directory does not exist", http.StatusNotFound)
 		return
 	}
@@ -562,7 +672,11 @@ func (srv *ILabServer) serveLatestCheckpointHandler(w http.ResponseWriter, r *ht
 	var modelPath string
 	if req.Checkpoint != "" {
 		// If a checkpoint is provided, construct the model path accordingly.
-		modelPath = filepath.Join(checkpointsDir, req.Checkpoint)
+		if cp, err := srv.findCheckpoint(req.Checkpoint); err == nil {
+			modelPath = cp.Path
+		} else {
+			modelPath = filepath.Join(checkpointsDir, req.Checkpoint)
+		}
 		srv.log.Infof("Checkpoint provided: %s", modelPath)
 
 		// Verify that the specified checkpoint directory exists.
@@ -572,14 +686,24 @@ func (srv *ILabServer) serveLatestCheckpointHandler(w http.ResponseWriter, r *ht
 			return
 		}
 	} else {
-		// If no checkpoint is provided, find the latest "samples_*" directory.
-		latestDir, err := srv.findLatestDirWithPrefix(checkpointsDir, "samples_")
+		// If no checkpoint is provided, pick the latest one using the requested rule.
+		if !validLatestRule(req.LatestBy) {
+			http.Error(w, fmt.Sprintf("Invalid value for 'latest_by': '%s' (expected 'newest' or 'samples')", req.LatestBy), http.StatusBadRequest)
+			return
+		}
+		checkpoints, err := srv.listCheckpoints()
 		if err != nil {
-			srv.log.Errorf("Error finding latest checkpoint: %v", err)
+			srv.log.Errorf("Error listing checkpoints: %v", err)
 			http.Error(w, "Failed to find the latest checkpoint", http.StatusInternalServerError)
 			return
 		}
-		modelPath = latestDir
+		latest, err := selectLatestCheckpoint(checkpoints, req.LatestBy, req.JobID)
+		if err != nil {
+			srv.log.Errorf("Error finding latest checkpoint (latest_by=%q, job_id=%q): %v", req.LatestBy, req.JobID, err)
+			http.Error(w, "No matching checkpoint found", http.StatusNotFound)
+			return
+		}
+		modelPath = latest.Path
 		srv.log.Infof("No checkpoint provided. Using the latest checkpoint: %s", modelPath)
 	}
 
@@ -597,40 +721,28 @@ func (srv *ILabServer) serveLatestCheckpointHandler(w http.ResponseWriter, r *ht
 		)
 	} else {
 		// Basic local serve
-		srv.log.Infof("Serving model at %s on port 8001", modelPath)
-		srv.serveModelHandler(modelPath, "8001", w)
+		srv.log.Infof("Serving model at %s as 'post-train' on port 8001", modelPath)
+		srv.serveModelHandler("post-train", modelPath, 8001, w)
 	}
 }
 
-// serveBaseModelHandler serves the "base" model on port 8000.
+// serveBaseModelHandler serves the requested (or the server's default) base model on port 8000.
 func (srv *ILabServer) serveBaseModelHandler(w http.ResponseWriter, r *http.Request) {
 	srv.log.Info("POST /model/serve-base called")
 
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		srv.log.Errorf("Error getting user home directory: %v", err)
-		http.Error(w, "Failed to get home directory", http.StatusInternalServerError)
+	var req ServeBaseModelRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		srv.log.Errorf("Error decoding request body: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if srv.useVllm {
-		// Spawn container for "pre-train" model
-		baseModelPath := filepath.Join(homeDir, ".cache", "instructlab", "models", "granite-8b-starter-v1")
-		srv.log.Infof("Serving base model using vllm at %s on port 8000", baseModelPath)
-		srv.runVllmContainerHandler(
-			baseModelPath,
-			"8000",
-			"pre-train",
-			0,
-			srv.homeDir,
-			srv.homeDir,
-			w,
-		)
-	} else {
-		baseModelPath := filepath.Join(homeDir, ".cache", "instructlab", "models", "granite-7b-lab-Q4_K_M.gguf")
-		srv.log.Infof("Serving base model at %s on port 8000", baseModelPath)
-		srv.serveModelHandler(baseModelPath, "8000", w)
+	modelName := req.ModelName
+	if modelName == "" {
+		modelName = srv.defaultBaseModel()
+		srv.log.Infof("No model_name provided. Using the default base model: %s", modelName)
 	}
+	srv.serveBaseModel(modelName, req, w)
 }
 
 // runVllmContainerHandler spawns a container for vllm-openai with the specified parameters.
@@ -659,33 +771,38 @@ func (srv *ILabServer) runVllmContainerHandler(
 
 	srv.log.Infof("No existing job found for model '%s'. Starting a new job.", servedModelName)
 
-	cmdArgs := []string{
-		"run", "--rm", "-it",
-		"--device", fmt.Sprintf("nvidia.com/gpu=%d", gpuIndex),
-		"--security-opt", "label=disable",
-		"--net", "host",
-		"--shm-size", "10G",
-		"--pids-limit", "-1",
-		"-v", fmt.Sprintf("%s:%s", hostVolume, containerVolume),
-		"--entrypoint", "/opt/app-root/bin/vllm",
-		"registry.redhat.io/rhelai1/instructlab-nvidia-rhel9:1.4-1738905416",
-		"serve", modelPath,
-		"--served-model-name", servedModelName,
-		"--load-format", "safetensors",
-		"--host", "127.0.0.1",
-		"--port", port,
-	}
-
-	// Log the command for debugging
-	fullCmd := fmt.Sprintf("podman %s", strings.Join(cmdArgs, " "))
-	srv.log.Infof("Executing Podman command: %s", fullCmd)
-
 	// Create a unique job ID and a log file
 	jobID := fmt.Sprintf("v-%d", time.Now().UnixNano())
 	logFilePath := filepath.Join("logs", fmt.Sprintf("%s.log", jobID))
 	srv.log.Infof("Starting vllm container with job_id: %s, logs: %s", jobID, logFilePath)
 
-	cmd := exec.Command("podman", cmdArgs...)
+	spec := ContainerSpec{
+		Name:        fmt.Sprintf("vllm-%s", jobID),
+		Image:       vllmImage,
+		Entrypoint:  "/opt/app-root/bin/vllm",
+		GPUs:        []string{fmt.Sprintf("%d", gpuIndex)},
+		SecurityOpt: []string{"label=disable"},
+		HostNetwork: true,
+		ShmSize:     "10G",
+		PidsLimit:   -1,
+		Volumes:     []VolumeMount{{HostPath: hostVolume, ContainerPath: containerVolume}},
+		Labels:      srv.containerLabels(roleVllm),
+		Remove:      true,
+		Args: []string{
+			"serve", modelPath,
+			"--served-model-name", servedModelName,
+			"--load-format", "safetensors",
+			"--host", "127.0.0.1",
+			"--port", port,
+		},
+	}
+
+	spec.Labels[labelJobID] = jobID
+	spec.Labels[labelServedModelName] = servedModelName
+	spec.Labels[labelModelPath] = modelPath
+
+	// Log the command for debugging
+	srv.log.Infof("Starting %s container %s: %s", srv.containerRuntime.Name(), spec.Name, strings.Join(spec.Args, " "))
 
 	// Open the log file
 	logFile, err := os.Create(logFilePath)
@@ -694,26 +811,25 @@ func (srv *ILabServer) runVllmContainerHandler(
 		http.Error(w, "Failed to create log file for vllm job", http.StatusInternalServerError)
 		return
 	}
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
 
 	// Start the container
-	if err := cmd.Start(); err != nil {
-		srv.log.Errorf("Error starting podman container for vllm job %s: %v", jobID, err)
+	proc, err := srv.containerRuntime.Start(spec, logFile, logFile)
+	if err != nil {
+		srv.log.Errorf("Error starting container for vllm job %s: %v", jobID, err)
 		logFile.Close()
 		http.Error(w, "Failed to start vllm container", http.StatusInternalServerError)
 		return
 	}
 
-	srv.log.Infof("Vllm container started with PID %d for job_id: %s", cmd.Process.Pid, jobID)
+	srv.log.Infof("Vllm container %s started with PID %d for job_id: %s", proc.ID(), proc.PID(), jobID)
 
 	// Create a Job record and store it in the DB
 	newJob := &Job{
 		JobID:           jobID,
-		Cmd:             "podman",
-		Args:            cmdArgs,
+		Cmd:             srv.containerRuntime.Name(),
+		Args:            spec.Args,
 		Status:          "running",
-		PID:             cmd.Process.Pid,
+		PID:             proc.PID(),
 		LogFile:         logFilePath,
 		StartTime:       time.Now(),
 		ServedModelName: servedModelName,
@@ -733,31 +849,37 @@ func (srv *ILabServer) runVllmContainerHandler(
 	go func() {
 		defer logFile.Close()
 
-		err := cmd.Wait()
+		err := proc.Wait()
 		newJob.Lock.Lock()
 		defer newJob.Lock.Unlock()
 
-		if err != nil {
-			newJob.Status = "failed"
-			srv.log.Errorf("Vllm job '%s' failed: %v", newJob.JobID, err)
-		} else if cmd.ProcessState.Success() {
-			newJob.Status = "finished"
-			srv.log.Infof("Vllm job '%s' finished successfully", newJob.JobID)
+		// A container event may already have recorded the exit code; keep that result.
+		if current, errGet := srv.getJob(newJob.JobID); errGet == nil && current != nil && current.Status != "running" {
+			newJob.Status = current.Status
+			newJob.EndTime = current.EndTime
+			srv.log.Infof("Vllm job '%s' already %s", newJob.JobID, current.Status)
 		} else {
-			newJob.Status = "failed"
-			srv.log.Warnf("Vllm job '%s' failed (unknown reason)", newJob.JobID)
-		}
-
-		now := time.Now()
-		newJob.EndTime = &now
-
-		if errDB := srv.updateJob(newJob); errDB != nil {
-			srv.log.Errorf("Failed to update DB for job '%s': %v", newJob.JobID, errDB)
+			if err != nil {
+				newJob.Status = "failed"
+				srv.log.Errorf("Vllm job '%s' failed: %v", newJob.JobID, err)
+			} else {
+				newJob.Status = "finished"
+				srv.log.Infof("Vllm job '%s' finished successfully", newJob.JobID)
+			}
+
+			now := time.Now()
+			newJob.EndTime = &now
+
+			if errDB := srv.updateJob(newJob); errDB != nil {
+				srv.log.Errorf("Failed to update DB for job '%s': %v", newJob.JobID, errDB)
+			}
 		}
 
 		// **Remove the mapping from servedModelJobIDs if job is finished or failed**
 		srv.jobIDsMutex.Lock()
-		delete(srv.servedModelJobIDs, servedModelName)
+		if srv.servedModelJobIDs[servedModelName] == newJob.JobID {
+			delete(srv.servedModelJobIDs, servedModelName)
+		}
 		srv.jobIDsMutex.Unlock()
 		srv.log.Infof("Removed mapping for model '%s' from servedModelJobIDs", servedModelName)
 	}()
@@ -771,123 +893,6 @@ func (srv *ILabServer) runVllmContainerHandler(
 	srv.log.Infof("POST /model/serve-%s response sent successfully with job_id: %s", servedModelName, jobID)
 }
 
-// serveModelHandler starts serving a model on the specified port (CPU-based approach).
-func (srv *ILabServer) serveModelHandler(modelPath, port string, w http.ResponseWriter) {
-	srv.modelLock.Lock()
-	defer srv.modelLock.Unlock()
-
-	srv.log.Infof("serveModelHandler called with modelPath=%s, port=%s", modelPath, port)
-
-	var targetProcess **exec.Cmd
-	if port == "8000" {
-		targetProcess = &srv.modelProcessBase
-	} else if port == "8001" {
-		targetProcess = &srv.modelProcessLatest
-	} else {
-		http.Error(w, "Invalid port specified", http.StatusBadRequest)
-		return
-	}
-
-	if _, err := os.Stat(modelPath); os.IsNotExist(err) {
-		srv.log.Errorf("Model path does not exist: %s", modelPath)
-		http.Error(w, fmt.Sprintf("Model path does not exist: %s", modelPath), http.StatusNotFound)
-		return
-	}
-
-	if *targetProcess != nil && (*targetProcess).Process != nil {
-		srv.log.Infof("Stopping existing model process on port %s...", port)
-		if err := (*targetProcess).Process.Kill(); err != nil {
-			srv.log.Errorf("Failed to kill existing model process on port %s: %v", port, err)
-			http.Error(w, "Failed to stop existing model process", http.StatusInternalServerError)
-			return
-		}
-		*targetProcess = nil
-	}
-
-	cmdArgs := []string{
-		"serve",
-		"--model-path", modelPath,
-		"--host", "0.0.0.0",
-		"--port", port,
-	}
-	cmdPath := srv.getIlabCommand()
-	cmd := exec.Command(cmdPath, cmdArgs...)
-	if !srv.rhelai {
-		cmd.Dir = srv.baseDir
-	}
-
-	jobID := fmt.Sprintf("ml-%d", time.Now().UnixNano())
-	logFilePath := filepath.Join("logs", fmt.Sprintf("%s.log", jobID))
-	srv.log.Infof("Model serve logs: %s", logFilePath)
-
-	logFile, err := os.Create(logFilePath)
-	if err != nil {
-		srv.log.Errorf("Error creating model run log file: %v", err)
-		http.Error(w, "Failed to create log file", http.StatusInternalServerError)
-		return
-	}
-
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
-
-	srv.log.Info("Attempting to start model process...")
-	if err := cmd.Start(); err != nil {
-		srv.log.Errorf("Error starting model process: %v", err)
-		logFile.Close()
-		http.Error(w, "Failed to start model process", http.StatusInternalServerError)
-		return
-	}
-	*targetProcess = cmd
-	srv.log.Infof("Model process started with PID %d on port %s", cmd.Process.Pid, port)
-
-	serveJob := &Job{
-		JobID:     jobID,
-		Cmd:       cmdPath,
-		Args:      cmdArgs,
-		Status:    "running",
-		PID:       cmd.Process.Pid,
-		LogFile:   logFilePath,
-		StartTime: time.Now(),
-	}
-	_ = srv.createJob(serveJob)
-
-	go func() {
-		err := cmd.Wait()
-		logFile.Sync()
-		logFile.Close()
-
-		serveJob.Lock.Lock()
-		defer serveJob.Lock.Unlock()
-
-		if err != nil {
-			serveJob.Status = "failed"
-			srv.log.Infof("Model run job '%s' on port %s failed: %v", jobID, port, err)
-		} else if cmd.ProcessState.Success() {
-			serveJob.Status = "finished"
-			srv.log.Infof("Model run job '%s' on port %s finished successfully", jobID, port)
-		} else {
-			serveJob.Status = "failed"
-			srv.log.Infof("Model run job '%s' on port %s failed (unknown reason)", jobID, port)
-		}
-		now := time.Now()
-		serveJob.EndTime = &now
-		_ = srv.updateJob(serveJob)
-
-		srv.modelLock.Lock()
-		defer srv.modelLock.Unlock()
-		if port == "8000" && srv.modelProcessBase == cmd {
-			srv.modelProcessBase = nil
-		}
-		if port == "8001" && srv.modelProcessLatest == cmd {
-			srv.modelProcessLatest = nil
-		}
-	}()
-
-	srv.log.Infof("Model serve started successfully on port %s, returning job_id: %s", port, jobID)
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "model process started", "job_id": jobID})
-}
-
 // getRunningJobByModel retrieves a running job for the specified served_model_name.
 // Returns nil if no such job exists.
 func (srv *ILabServer) getRunningJobByModel(servedModelName string) (*Job, error) {
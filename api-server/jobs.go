@@ -1,7 +1,9 @@
 package main
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -41,6 +43,129 @@ func (srv *ILabServer) initDB() {
 	if err != nil {
 		srv.log.Fatalf("Failed to create jobs table: %v", err)
 	}
+
+	// Columns added after the jobs table was first released
+	for column, columnType := range map[string]string{"dataset": "TEXT", "train_spec": "TEXT", "parent_job_id": "TEXT", "resume_checkpoint": "TEXT", "phase": "TEXT", "commit_sha": "TEXT", "taxonomy_dirty": "INTEGER", "base_model": "TEXT"} {
+		if err := srv.addColumnIfMissing("jobs", column, columnType); err != nil {
+			srv.log.Fatalf("Failed to migrate jobs table: %v", err)
+		}
+	}
+
+	// Key/value settings that must survive restarts (e.g. the server instance ID)
+	_, err = srv.db.Exec(`
+    CREATE TABLE IF NOT EXISTS server_info (
+        key TEXT PRIMARY KEY,
+        value TEXT
+    );
+    `)
+	if err != nil {
+		srv.log.Fatalf("Failed to create server_info table: %v", err)
+	}
+
+	// Checkpoint provenance and evaluation results
+	_, err = srv.db.Exec(`
+    CREATE TABLE IF NOT EXISTS checkpoints (
+        name TEXT PRIMARY KEY,
+        path TEXT,
+        job_id TEXT,
+        branch TEXT,
+        base_model TEXT,
+        recorded_at TEXT
+    );
+    CREATE TABLE IF NOT EXISTS checkpoint_evaluations (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        checkpoint TEXT,
+        evaluator TEXT,
+        result TEXT,
+        created_at TEXT
+    );
+    `)
+	if err != nil {
+		srv.log.Fatalf("Failed to create checkpoint tables: %v", err)
+	}
+	for column, columnType := range map[string]string{"commit_sha": "TEXT", "taxonomy_dirty": "INTEGER"} {
+		if err := srv.addColumnIfMissing("checkpoints", column, columnType); err != nil {
+			srv.log.Fatalf("Failed to migrate checkpoints table: %v", err)
+		}
+	}
+
+	// Training metrics series, one row per metric and step
+	_, err = srv.db.Exec(`
+    CREATE TABLE IF NOT EXISTS training_metrics (
+        job_id TEXT,
+        name TEXT,
+        step INTEGER,
+        epoch REAL,
+        value REAL,
+        recorded_at TEXT,
+        UNIQUE (job_id, name, step)
+    );
+    `)
+	if err != nil {
+		srv.log.Fatalf("Failed to create training_metrics table: %v", err)
+	}
+
+	// Named training hyperparameter presets
+	_, err = srv.db.Exec(`
+    CREATE TABLE IF NOT EXISTS training_presets (
+        name TEXT PRIMARY KEY,
+        spec TEXT,
+        created_at TEXT,
+        updated_at TEXT
+    );
+    `)
+	if err != nil {
+		srv.log.Fatalf("Failed to create training_presets table: %v", err)
+	}
+}
+
+// addColumnIfMissing adds a column to an existing table, so databases created by older
+// versions of the server keep working.
+func (srv *ILabServer) addColumnIfMissing(table, column, columnType string) error {
+	rows, err := srv.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, ctype string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	_, err = srv.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, columnType))
+	return err
+}
+
+// getOrCreateServerID returns the persisted server instance ID, generating one on first start.
+func (srv *ILabServer) getOrCreateServerID() (string, error) {
+	var id string
+	err := srv.db.QueryRow("SELECT value FROM server_info WHERE key = 'server_id'").Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to read server ID: %v", err)
+	}
+
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate server ID: %v", err)
+	}
+	id = hex.EncodeToString(buf)
+	if _, err := srv.db.Exec("INSERT INTO server_info (key, value) VALUES ('server_id', ?)", id); err != nil {
+		return "", fmt.Errorf("failed to store server ID: %v", err)
+	}
+	return id, nil
 }
 
 // -----------------------------------------------------------------------------
@@ -58,9 +183,13 @@ func (srv *ILabServer) createJob(job *Job) error {
 		s := job.EndTime.Format(time.RFC3339)
 		endTimeStr = &s
 	}
+	trainSpec, err := marshalTrainingSpec(job.TrainSpec)
+	if err != nil {
+		return err
+	}
 	_, err = srv.db.Exec(`
-        INSERT INTO jobs (job_id, cmd, args, status, pid, log_file, start_time, end_time, branch, served_model_name)
-        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        INSERT INTO jobs (job_id, cmd, args, status, pid, log_file, start_time, end_time, branch, served_model_name, dataset, train_spec, parent_job_id, resume_checkpoint, phase, commit_sha, taxonomy_dirty, base_model)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
     `,
 		job.JobID,
 		job.Cmd,
@@ -72,6 +201,14 @@ func (srv *ILabServer) createJob(job *Job) error {
 		endTimeStr,
 		job.Branch,
 		job.ServedModelName,
+		job.Dataset,
+		trainSpec,
+		job.ParentJobID,
+		job.ResumeCheckpoint,
+		job.Phase,
+		job.CommitSHA,
+		job.TaxonomyDirty,
+		job.BaseModel,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert job: %v", err)
@@ -81,11 +218,12 @@ func (srv *ILabServer) createJob(job *Job) error {
 
 // getJob fetches a single job by job_id.
 func (srv *ILabServer) getJob(jobID string) (*Job, error) {
-	row := srv.db.QueryRow("SELECT job_id, cmd, args, status, pid, log_file, start_time, end_time, branch, served_model_name FROM jobs WHERE job_id = ?", jobID)
+	row := srv.db.QueryRow("SELECT job_id, cmd, args, status, pid, log_file, start_time, end_time, branch, served_model_name, dataset, train_spec, parent_job_id, resume_checkpoint, phase, commit_sha, taxonomy_dirty, base_model FROM jobs WHERE job_id = ?", jobID)
 
 	var j Job
 	var argsJSON string
-	var startTimeStr, endTimeStr sql.NullString
+	var startTimeStr, endTimeStr, dataset, trainSpec, parentJobID, resumeCheckpoint, phase, commitSHA, baseModel sql.NullString
+	var taxonomyDirty sql.NullBool
 
 	err := row.Scan(
 		&j.JobID,
@@ -98,6 +236,14 @@ func (srv *ILabServer) getJob(jobID string) (*Job, error) {
 		&endTimeStr,
 		&j.Branch,
 		&j.ServedModelName,
+		&dataset,
+		&trainSpec,
+		&parentJobID,
+		&resumeCheckpoint,
+		&phase,
+		&commitSHA,
+		&taxonomyDirty,
+		&baseModel,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil // not found
@@ -108,6 +254,10 @@ func (srv *ILabServer) getJob(jobID string) (*Job, error) {
 	if err := json.Unmarshal([]byte(argsJSON), &j.Args); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal job Args: %v", err)
 	}
+	j.Dataset = dataset.String
+	j.TrainSpec = unmarshalTrainingSpec(trainSpec)
+	j.ParentJobID, j.ResumeCheckpoint, j.Phase = parentJobID.String, resumeCheckpoint.String, phase.String
+	j.CommitSHA, j.TaxonomyDirty, j.BaseModel = commitSHA.String, taxonomyDirty.Bool, baseModel.String
 	if startTimeStr.Valid {
 		t, err := time.Parse(time.RFC3339, startTimeStr.String)
 		if err == nil {
@@ -135,9 +285,13 @@ func (srv *ILabServer) updateJob(job *Job) error {
 		s := job.EndTime.Format(time.RFC3339)
 		endTimeStr = &s
 	}
+	trainSpec, err := marshalTrainingSpec(job.TrainSpec)
+	if err != nil {
+		return err
+	}
 	_, err = srv.db.Exec(`
         UPDATE jobs
-        SET cmd = ?, args = ?, status = ?, pid = ?, log_file = ?, start_time = ?, end_time = ?, branch = ?, served_model_name = ?
+        SET cmd = ?, args = ?, status = ?, pid = ?, log_file = ?, start_time = ?, end_time = ?, branch = ?, served_model_name = ?, dataset = ?, train_spec = ?, parent_job_id = ?, resume_checkpoint = ?, phase = ?, commit_sha = ?, taxonomy_dirty = ?, base_model = ?
         WHERE job_id = ?
     `,
 		job.Cmd,
@@ -149,6 +303,14 @@ func (srv *ILabServer) updateJob(job *Job) error {
 		endTimeStr,
 		job.Branch,
 		job.ServedModelName,
+		job.Dataset,
+		trainSpec,
+		job.ParentJobID,
+		job.ResumeCheckpoint,
+		job.Phase,
+		job.CommitSHA,
+		job.TaxonomyDirty,
+		job.BaseModel,
 		job.JobID,
 	)
 	if err != nil {
@@ -159,7 +321,7 @@ func (srv *ILabServer) updateJob(job *Job) error {
 
 // listAllJobs returns all jobs in the DB.
 func (srv *ILabServer) listAllJobs() ([]*Job, error) {
-	rows, err := srv.db.Query("SELECT job_id, cmd, args, status, pid, log_file, start_time, end_time, branch FROM jobs")
+	rows, err := srv.db.Query("SELECT job_id, cmd, args, status, pid, log_file, start_time, end_time, branch, dataset, train_spec, parent_job_id, resume_checkpoint, phase, commit_sha, taxonomy_dirty, base_model FROM jobs")
 	if err != nil {
 		return nil, err
 	}
@@ -169,7 +331,8 @@ func (srv *ILabServer) listAllJobs() ([]*Job, error) {
 	for rows.Next() {
 		var j Job
 		var argsJSON string
-		var startTimeStr, endTimeStr sql.NullString
+		var startTimeStr, endTimeStr, dataset, trainSpec, parentJobID, resumeCheckpoint, phase, commitSHA, baseModel sql.NullString
+		var taxonomyDirty sql.NullBool
 
 		err := rows.Scan(
 			&j.JobID,
@@ -181,6 +344,14 @@ func (srv *ILabServer) listAllJobs() ([]*Job, error) {
 			&startTimeStr,
 			&endTimeStr,
 			&j.Branch,
+			&dataset,
+			&trainSpec,
+			&parentJobID,
+			&resumeCheckpoint,
+			&phase,
+			&commitSHA,
+			&taxonomyDirty,
+			&baseModel,
 		)
 		if err != nil {
 			return nil, err
@@ -189,6 +360,10 @@ func (srv *ILabServer) listAllJobs() ([]*Job, error) {
 		if err := json.Unmarshal([]byte(argsJSON), &j.Args); err != nil {
 			srv.log.Infof("Warning: failed to unmarshal job Args for job %s: %v", j.JobID, err)
 		}
+		j.Dataset = dataset.String
+		j.TrainSpec = unmarshalTrainingSpec(trainSpec)
+		j.ParentJobID, j.ResumeCheckpoint, j.Phase = parentJobID.String, resumeCheckpoint.String, phase.String
+		j.CommitSHA, j.TaxonomyDirty, j.BaseModel = commitSHA.String, taxonomyDirty.Bool, baseModel.String
 		if startTimeStr.Valid {
 			t, err := time.Parse(time.RFC3339, startTimeStr.String)
 			if err == nil {
@@ -212,8 +387,9 @@ func (srv *ILabServer) listAllJobs() ([]*Job, error) {
 // -----------------------------------------------------------------------------
 
 // checkRunningJobs checks the status of "running" jobs and marks them as failed if their processes are not running.
+// Serving jobs backed by containers are left to reconcileContainers.
 func (srv *ILabServer) checkRunningJobs() {
-	rows, err := srv.db.Query("SELECT job_id, pid FROM jobs WHERE status = 'running'")
+	rows, err := srv.db.Query("SELECT job_id, pid FROM jobs WHERE status = 'running' AND (served_model_name IS NULL OR served_model_name = '')")
 	if err != nil {
 		srv.log.Errorf("Error querying running jobs: %v", err)
 		return
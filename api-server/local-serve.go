@@ -0,0 +1,485 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// LocalServe is a model served by a local "ilab model serve" process (CPU/MPS hosts without vLLM).
+type LocalServe struct {
+	Name      string    `json:"name"`
+	ModelPath string    `json:"model_path"`
+	Port      int       `json:"port"`
+	JobID     string    `json:"job_id"`
+	PID       int       `json:"pid"`
+	StartTime time.Time `json:"start_time"`
+	Adopted   bool      `json:"adopted"` // true if recovered after a server restart
+
+	cmd     *exec.Cmd
+	stopped bool // set when the process was stopped on request
+}
+
+// LocalServeRequest is used by the POST /serves endpoint.
+type LocalServeRequest struct {
+	Name      string `json:"name"`
+	ModelPath string `json:"model_path"`
+	Port      int    `json:"port,omitempty"` // Optional: allocated automatically if omitted
+}
+
+var (
+	errLocalServeLimit   = errors.New("maximum number of concurrent local serves reached")
+	errPortUnavailable   = errors.New("port is unavailable")
+	errLocalServeMissing = errors.New("no local serve with that name")
+	errLocalServeStarted = errors.New("another local serve with that name was started")
+)
+
+// localServePortSearch is how many ports above --serve-port-start are considered for allocation.
+const localServePortSearch = 100
+
+// portAvailable reports whether nothing is listening on the given port.
+func portAvailable(port int) bool {
+	l, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", port))
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
+
+// allocateServePort picks a free port, honouring preferred if it is free. Callers must hold localServesMu.
+func (srv *ILabServer) allocateServePort(preferred int) (int, error) {
+	used := make(map[int]bool)
+	for _, s := range srv.localServes {
+		used[s.Port] = true
+	}
+	if preferred != 0 {
+		if used[preferred] || !portAvailable(preferred) {
+			return 0, fmt.Errorf("%w: %d", errPortUnavailable, preferred)
+		}
+		return preferred, nil
+	}
+	for port := srv.servePortStart; port < srv.servePortStart+localServePortSearch; port++ {
+		if !used[port] && portAvailable(port) {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free port in range %d-%d", srv.servePortStart, srv.servePortStart+localServePortSearch-1)
+}
+
+// resolveServeModelPath returns the absolute path of a model to serve, given as a path in the
+// models directory or relative to it. Like resolveModel, it rejects paths outside of it.
+func resolveServeModelPath(modelPath string) (string, error) {
+	modelsDir, err := getModelsDir()
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(modelPath, "-") {
+		return "", errInvalidModelName
+	}
+	if !filepath.IsAbs(modelPath) {
+		modelPath = filepath.Join(modelsDir, modelPath)
+	}
+	modelPath = filepath.Clean(modelPath)
+	rel, err := filepath.Rel(modelsDir, modelPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errInvalidModelName
+	}
+	return modelPath, nil
+}
+
+// startLocalServe launches "ilab model serve" for modelPath under the given deployment name.
+// An existing deployment with the same name is replaced; it is only stopped once the model
+// path, the serve limit and the port have been checked, so a failing request leaves it running.
+func (srv *ILabServer) startLocalServe(name, modelPath string, port int) (*LocalServe, error) {
+	if _, err := os.Stat(modelPath); err != nil {
+		return nil, err
+	}
+
+	srv.localServesMu.Lock()
+	existing, replacing := srv.localServes[name]
+	active := len(srv.localServes)
+	if replacing {
+		active--
+	}
+	if active >= srv.maxLocalServes {
+		srv.localServesMu.Unlock()
+		return nil, errLocalServeLimit
+	}
+	// Reuse the port of the deployment being replaced unless another was requested; it is
+	// released by stopping that deployment, any other port must be free now
+	reusePort := replacing && (port == 0 || port == existing.Port)
+	if reusePort {
+		port = existing.Port
+	} else {
+		var err error
+		if port, err = srv.allocateServePort(port); err != nil {
+			srv.localServesMu.Unlock()
+			return nil, err
+		}
+	}
+	if replacing {
+		srv.log.Infof("Stopping existing local serve '%s' on port %d...", name, existing.Port)
+		if err := srv.killLocalServe(existing); err != nil {
+			srv.localServesMu.Unlock()
+			return nil, fmt.Errorf("failed to stop existing local serve '%s': %v", name, err)
+		}
+		delete(srv.localServes, name)
+	}
+	srv.localServesMu.Unlock()
+
+	if reusePort {
+		// Give the old process a moment to release its port, without blocking other serves
+		for i := 0; i < 20 && !portAvailable(port); i++ {
+			time.Sleep(250 * time.Millisecond)
+		}
+	}
+
+	srv.localServesMu.Lock()
+	defer srv.localServesMu.Unlock()
+	// Check again under the lock, which deletePath holds while it removes a path, and as
+	// other serves may have started while it was released
+	if _, err := os.Stat(modelPath); err != nil {
+		return nil, err
+	}
+	if _, ok := srv.localServes[name]; ok {
+		return nil, errLocalServeStarted
+	}
+	if len(srv.localServes) >= srv.maxLocalServes {
+		return nil, errLocalServeLimit
+	}
+	port, err := srv.allocateServePort(port)
+	if err != nil {
+		return nil, err
+	}
+
+	cmdArgs := []string{
+		"serve",
+		"--model-path", modelPath,
+		"--host", "0.0.0.0",
+		"--port", strconv.Itoa(port),
+	}
+	cmdPath := srv.getIlabCommand()
+	cmd := exec.Command(cmdPath, cmdArgs...)
+	if !srv.rhelai {
+		cmd.Dir = srv.baseDir
+	}
+
+	jobID := fmt.Sprintf("ml-%d", time.Now().UnixNano())
+	logFilePath := filepath.Join("logs", fmt.Sprintf("%s.log", jobID))
+	srv.log.Infof("Model serve logs: %s", logFilePath)
+
+	logFile, err := os.Create(logFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log file: %v", err)
+	}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return nil, fmt.Errorf("failed to start model process: %v", err)
+	}
+	srv.log.Infof("Model process '%s' started with PID %d on port %d", name, cmd.Process.Pid, port)
+
+	serve := &LocalServe{
+		Name:      name,
+		ModelPath: modelPath,
+		Port:      port,
+		JobID:     jobID,
+		PID:       cmd.Process.Pid,
+		StartTime: time.Now(),
+		cmd:       cmd,
+	}
+	srv.localServes[name] = serve
+
+	serveJob := &Job{
+		JobID:           jobID,
+		Cmd:             cmdPath,
+		Args:            cmdArgs,
+		Status:          "running",
+		PID:             cmd.Process.Pid,
+		LogFile:         logFilePath,
+		StartTime:       serve.StartTime,
+		ServedModelName: name,
+	}
+	if err := srv.createJob(serveJob); err != nil {
+		srv.log.Errorf("Failed to create job in DB for %s: %v", jobID, err)
+	}
+
+	go func() {
+		err := cmd.Wait()
+		logFile.Sync()
+		logFile.Close()
+
+		srv.localServesMu.Lock()
+		stopped := serve.stopped
+		srv.localServesMu.Unlock()
+
+		serveJob.Lock.Lock()
+		if err != nil && !stopped {
+			serveJob.Status = "failed"
+			srv.log.Infof("Model run job '%s' on port %d failed: %v", jobID, port, err)
+		} else {
+			serveJob.Status = "finished"
+			srv.log.Infof("Model run job '%s' on port %d finished successfully", jobID, port)
+		}
+		now := time.Now()
+		serveJob.EndTime = &now
+		_ = srv.updateJob(serveJob)
+		serveJob.Lock.Unlock()
+
+		srv.localServesMu.Lock()
+		if srv.localServes[name] == serve {
+			delete(srv.localServes, name)
+		}
+		srv.localServesMu.Unlock()
+	}()
+
+	return serve, nil
+}
+
+// killLocalServe terminates the process behind a local serve. Callers must hold localServesMu.
+func (srv *ILabServer) killLocalServe(serve *LocalServe) error {
+	serve.stopped = true
+	if serve.cmd != nil && serve.cmd.Process != nil {
+		return serve.cmd.Process.Kill()
+	}
+	// Adopted after a restart: we only know the PID
+	process, err := os.FindProcess(serve.PID)
+	if err != nil {
+		return err
+	}
+	return process.Signal(syscall.SIGTERM)
+}
+
+// stopLocalServe stops the named deployment.
+func (srv *ILabServer) stopLocalServe(name string) (*LocalServe, error) {
+	srv.localServesMu.Lock()
+	defer srv.localServesMu.Unlock()
+
+	serve, ok := srv.localServes[name]
+	if !ok {
+		return nil, errLocalServeMissing
+	}
+	if err := srv.killLocalServe(serve); err != nil {
+		return nil, err
+	}
+	delete(srv.localServes, name)
+	return serve, nil
+}
+
+// listLocalServes returns the active deployments sorted by name.
+func (srv *ILabServer) listLocalServes() []LocalServe {
+	srv.localServesMu.Lock()
+	defer srv.localServesMu.Unlock()
+
+	serves := make([]LocalServe, 0, len(srv.localServes))
+	for _, s := range srv.localServes {
+		serves = append(serves, *s)
+	}
+	sort.Slice(serves, func(i, j int) bool { return serves[i].Name < serves[j].Name })
+	return serves
+}
+
+// reconcileLocalServes re-registers "ilab model serve" processes that survived a restart and
+// marks serve jobs whose process is gone as failed, mirroring reconcileContainers.
+func (srv *ILabServer) reconcileLocalServes() {
+	srv.log.Info("Reconciling local serve processes against the jobs table...")
+
+	rows, err := srv.db.Query(`
+        SELECT job_id
+        FROM jobs
+        WHERE job_id LIKE 'ml-%' AND served_model_name != '' AND status = 'running'
+    `)
+	if err != nil {
+		srv.log.Errorf("Error querying running local serve jobs: %v", err)
+		return
+	}
+	var jobIDs []string
+	for rows.Next() {
+		var jobID string
+		if err := rows.Scan(&jobID); err != nil {
+			srv.log.Errorf("Error scanning row: %v", err)
+			continue
+		}
+		jobIDs = append(jobIDs, jobID)
+	}
+	rows.Close()
+
+	for _, jobID := range jobIDs {
+		job, err := srv.getJob(jobID)
+		if err != nil || job == nil {
+			srv.log.Errorf("Unable to load job %s: %v", jobID, err)
+			continue
+		}
+		if !srv.isProcessRunning(job.PID) {
+			now := time.Now()
+			job.Status = "failed"
+			job.EndTime = &now
+			_ = srv.updateJob(job)
+			srv.log.Infof("Job %s marked as failed (serve process not running)", jobID)
+			continue
+		}
+
+		serve := &LocalServe{
+			Name:      job.ServedModelName,
+			JobID:     job.JobID,
+			PID:       job.PID,
+			StartTime: job.StartTime,
+			Adopted:   true,
+		}
+		for i := 0; i+1 < len(job.Args); i++ {
+			switch job.Args[i] {
+			case "--model-path":
+				serve.ModelPath = job.Args[i+1]
+			case "--port":
+				serve.Port, _ = strconv.Atoi(job.Args[i+1])
+			}
+		}
+
+		srv.localServesMu.Lock()
+		srv.localServes[serve.Name] = serve
+		srv.localServesMu.Unlock()
+		srv.log.Infof("Adopted local serve '%s' (job_id=%s, pid=%d, port=%d)", serve.Name, jobID, serve.PID, serve.Port)
+
+		go srv.watchAdoptedLocalServe(job, serve)
+	}
+}
+
+// watchAdoptedLocalServe polls an adopted serve process and finishes its job once it exits.
+func (srv *ILabServer) watchAdoptedLocalServe(job *Job, serve *LocalServe) {
+	for srv.isProcessRunning(serve.PID) {
+		time.Sleep(10 * time.Second)
+	}
+
+	job.Lock.Lock()
+	job.Status = "finished"
+	now := time.Now()
+	job.EndTime = &now
+	_ = srv.updateJob(job)
+	job.Lock.Unlock()
+
+	srv.localServesMu.Lock()
+	if srv.localServes[serve.Name] == serve {
+		delete(srv.localServes, serve.Name)
+	}
+	srv.localServesMu.Unlock()
+	srv.log.Infof("Adopted local serve '%s' (job_id=%s) exited", serve.Name, job.JobID)
+}
+
+// serveModelHandler starts serving a model under a deployment name (CPU-based approach) and
+// writes the HTTP response. A port of 0 allocates one automatically.
+func (srv *ILabServer) serveModelHandler(name, modelPath string, port int, w http.ResponseWriter) {
+	srv.log.Infof("serveModelHandler called with name=%s, modelPath=%s, port=%d", name, modelPath, port)
+
+	serve, err := srv.startLocalServe(name, modelPath, port)
+	if err != nil {
+		switch {
+		case os.IsNotExist(err):
+			srv.log.Errorf("Model path does not exist: %s", modelPath)
+			http.Error(w, fmt.Sprintf("Model path does not exist: %s", modelPath), http.StatusNotFound)
+		case errors.Is(err, errLocalServeStarted):
+			http.Error(w, fmt.Sprintf("Another local serve named '%s' was started meanwhile", name), http.StatusConflict)
+		case errors.Is(err, errLocalServeLimit):
+			http.Error(w, fmt.Sprintf("Maximum of %d concurrent local serves reached", srv.maxLocalServes), http.StatusConflict)
+		case errors.Is(err, errPortUnavailable):
+			// The error names the port tried, which may be the replaced serve's rather than port
+			http.Error(w, fmt.Sprintf("Cannot start local serve '%s': %v", name, err), http.StatusConflict)
+		default:
+			srv.log.Errorf("Error starting model process: %v", err)
+			http.Error(w, "Failed to start model process", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	srv.log.Infof("Model serve '%s' started successfully on port %d, returning job_id: %s", name, serve.Port, serve.JobID)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "model process started",
+		"job_id": serve.JobID,
+		"name":   serve.Name,
+		"port":   serve.Port,
+	})
+}
+
+// -----------------------------------------------------------------------------
+// Local Serve Handlers
+// -----------------------------------------------------------------------------
+
+// listLocalServesHandler handles GET /serves.
+func (srv *ILabServer) listLocalServesHandler(w http.ResponseWriter, r *http.Request) {
+	srv.log.Info("GET /serves called")
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(srv.listLocalServes())
+}
+
+// startLocalServeHandler handles POST /serves.
+func (srv *ILabServer) startLocalServeHandler(w http.ResponseWriter, r *http.Request) {
+	srv.log.Info("POST /serves called")
+
+	var req LocalServeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		srv.log.Errorf("Error decoding serve request: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || req.ModelPath == "" {
+		http.Error(w, "Missing required parameters: name or model_path", http.StatusBadRequest)
+		return
+	}
+	if req.Port < 0 || req.Port > 65535 {
+		http.Error(w, "'port' must be between 1 and 65535", http.StatusBadRequest)
+		return
+	}
+	if srv.useVllm {
+		http.Error(w, "Local serves are unavailable when --vllm is set", http.StatusBadRequest)
+		return
+	}
+	modelPath, err := resolveServeModelPath(req.ModelPath)
+	if err != nil {
+		srv.log.Infof("Rejected serve model path %q: %v", req.ModelPath, err)
+		http.Error(w, fmt.Sprintf("Invalid model_path '%s' (expected a model in the models directory)", req.ModelPath), http.StatusBadRequest)
+		return
+	}
+
+	srv.serveModelHandler(req.Name, modelPath, req.Port, w)
+}
+
+// stopLocalServeHandler handles DELETE /serves/{name}.
+func (srv *ILabServer) stopLocalServeHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	srv.log.Infof("DELETE /serves/%s called", name)
+
+	serve, err := srv.stopLocalServe(name)
+	if errors.Is(err, errLocalServeMissing) {
+		http.Error(w, fmt.Sprintf("No local serve named '%s'", name), http.StatusNotFound)
+		return
+	} else if err != nil {
+		srv.log.Errorf("Error stopping local serve '%s': %v", name, err)
+		http.Error(w, fmt.Sprintf("Failed to stop local serve '%s': %v", name, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "stopped",
+		"name":   serve.Name,
+		"job_id": serve.JobID,
+		"port":   serve.Port,
+	})
+	srv.log.Infof("DELETE /serves/%s stopped job %s", name, serve.JobID)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveServeModelPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	modelsDir := filepath.Join(home, ".cache", "instructlab", "models")
+
+	for _, tc := range []struct {
+		path string
+		want string // empty when the path is rejected
+	}{
+		{"granite-7b-lab-Q4_K_M.gguf", filepath.Join(modelsDir, "granite-7b-lab-Q4_K_M.gguf")},
+		{"instructlab/granite-7b-lab", filepath.Join(modelsDir, "instructlab/granite-7b-lab")},
+		{filepath.Join(modelsDir, "instructlab/granite-7b-lab"), filepath.Join(modelsDir, "instructlab/granite-7b-lab")},
+		{filepath.Join(modelsDir, "a/../b.gguf"), filepath.Join(modelsDir, "b.gguf")},
+		{modelsDir, ""},
+		{"../datasets/train.jsonl", ""},
+		{filepath.Join(modelsDir, "../checkpoints"), ""},
+		{"/etc/passwd", ""},
+		{"--help", ""},
+	} {
+		got, err := resolveServeModelPath(tc.path)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("resolveServeModelPath(%q) = %q, want an error", tc.path, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("resolveServeModelPath(%q) = %q, %v, want %q", tc.path, got, err, tc.want)
+		}
+	}
+}
+
+// newServeTestServer returns a test server whose ilab serves by sleeping, with a model to
+// serve and ports from a free range of four.
+func newServeTestServer(t *testing.T, maxServes int) (*ILabServer, string) {
+	t.Helper()
+	srv := newTestServer(t)
+	srv.ilabCmd = filepath.Join(t.TempDir(), "ilab")
+	if err := os.WriteFile(srv.ilabCmd, []byte("#!/bin/sh\nexec sleep 60\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	srv.maxLocalServes = maxServes
+	srv.servePortStart = freePortRange(t, 4)
+	t.Cleanup(func() {
+		for _, s := range srv.listLocalServes() {
+			_, _ = srv.stopLocalServe(s.Name)
+		}
+	})
+
+	modelsDir, err := getModelsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := filepath.Join(modelsDir, "granite.gguf")
+	if err := os.MkdirAll(modelsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(model, []byte("GGUF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return srv, model
+}
+
+// freePortRange returns the first of n consecutive free ports.
+func freePortRange(t *testing.T, n int) int {
+	t.Helper()
+	for start := 20000; start < 60000; start += n {
+		free := true
+		for port := start; port < start+n && free; port++ {
+			free = portAvailable(port)
+		}
+		if free {
+			return start
+		}
+	}
+	t.Fatal("no free port range")
+	return 0
+}
+
+// listenOn occupies port until the test ends.
+func listenOn(t *testing.T, port int) {
+	t.Helper()
+	l, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+}
+
+func TestAllocateServePort(t *testing.T) {
+	srv, _ := newServeTestServer(t, 4)
+	start := srv.servePortStart
+	listenOn(t, start)
+	srv.localServes["chat"] = &LocalServe{Name: "chat", Port: start + 1}
+
+	if port, err := srv.allocateServePort(0); err != nil || port != start+2 {
+		t.Errorf("allocateServePort(0) = %d, %v, want %d", port, err, start+2)
+	}
+	if port, err := srv.allocateServePort(start + 3); err != nil || port != start+3 {
+		t.Errorf("allocateServePort(%d) = %d, %v", start+3, port, err)
+	}
+	for _, taken := range []int{start, start + 1} {
+		_, err := srv.allocateServePort(taken)
+		if !errors.Is(err, errPortUnavailable) || !strings.Contains(err.Error(), strconv.Itoa(taken)) {
+			t.Errorf("allocateServePort(%d) error = %v, want the port unavailable", taken, err)
+		}
+	}
+}
+
+func TestLocalServeLimitAndStop(t *testing.T) {
+	srv, model := newServeTestServer(t, 1)
+
+	first, err := srv.startLocalServe("chat", model, 0)
+	if err != nil {
+		t.Fatalf("startLocalServe: %v", err)
+	}
+	if first.Port != srv.servePortStart {
+		t.Errorf("port = %d, want %d", first.Port, srv.servePortStart)
+	}
+	if _, err := srv.startLocalServe("other", model, 0); !errors.Is(err, errLocalServeLimit) {
+		t.Errorf("second serve error = %v, want the limit reached", err)
+	}
+
+	if _, err := srv.stopLocalServe("missing"); !errors.Is(err, errLocalServeMissing) {
+		t.Errorf("stopping a missing serve: %v", err)
+	}
+	if _, err := srv.stopLocalServe("chat"); err != nil {
+		t.Fatalf("stopLocalServe: %v", err)
+	}
+	if job := waitForJob(t, srv, first.JobID); job.Status != "finished" {
+		t.Errorf("stopped serve job status = %s, want finished", job.Status)
+	}
+	if _, err := srv.startLocalServe("other", model, 0); err != nil {
+		t.Errorf("serve after stop: %v", err)
+	}
+}
+
+func TestLocalServeReplace(t *testing.T) {
+	srv, model := newServeTestServer(t, 1)
+	first, err := srv.startLocalServe("chat", model, 0)
+	if err != nil {
+		t.Fatalf("startLocalServe: %v", err)
+	}
+	taken := srv.servePortStart + 1
+	listenOn(t, taken)
+
+	// Requests that cannot start leave the deployment running
+	if _, err := srv.startLocalServe("chat", model+".missing", 0); !os.IsNotExist(err) {
+		t.Errorf("replace with a missing model: %v", err)
+	}
+	if _, err := srv.startLocalServe("chat", model, taken); !errors.Is(err, errPortUnavailable) {
+		t.Errorf("replace on a taken port: %v", err)
+	}
+	if serve := srv.localServes["chat"]; serve != first || !srv.isProcessRunning(first.PID) {
+		t.Fatal("failed replacements stopped the running serve")
+	}
+
+	// The replacement reuses the port, within the limit the replaced serve counted towards
+	second, err := srv.startLocalServe("chat", model, 0)
+	if err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if second.JobID == first.JobID || second.Port != first.Port {
+		t.Errorf("replacement = %+v, want a new job on port %d", second, first.Port)
+	}
+	if job := waitForJob(t, srv, first.JobID); job.Status != "finished" {
+		t.Errorf("replaced serve job status = %s, want finished", job.Status)
+	}
+}
+
+func TestServeModelHandlerReportsPortTried(t *testing.T) {
+	srv, model := newServeTestServer(t, 2)
+	serve, err := srv.startLocalServe("chat", model, 0)
+	if err != nil {
+		t.Fatalf("startLocalServe: %v", err)
+	}
+	// The port of the replaced serve stays taken, so reusing it fails
+	listenOn(t, serve.Port)
+
+	w := httptest.NewRecorder()
+	srv.serveModelHandler("chat", model, 0, w)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), strconv.Itoa(serve.Port)) {
+		t.Errorf("response = %d %q, want 409 naming port %d", w.Code, w.Body, serve.Port)
+	}
+}
+
+func TestReconcileLocalServesAdopts(t *testing.T) {
+	srv, model := newServeTestServer(t, 4)
+	sleeper := exec.Command("sleep", "60")
+	if err := sleeper.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sleeper.Process.Kill(); _ = sleeper.Wait() })
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, job := range []*Job{
+		{JobID: "ml-1", PID: sleeper.Process.Pid, ServedModelName: "chat"},
+		{JobID: "ml-2", PID: exited.Process.Pid, ServedModelName: "gone"},
+	} {
+		job.Cmd = srv.ilabCmd
+		job.Args = []string{"serve", "--model-path", model, "--host", "0.0.0.0", "--port", "8123"}
+		job.Status = "running"
+		job.StartTime = time.Now()
+		if err := srv.createJob(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv.reconcileLocalServes()
+
+	serves := srv.listLocalServes()
+	if len(serves) != 1 {
+		t.Fatalf("serves = %+v, want the live one adopted", serves)
+	}
+	if s := serves[0]; s.Name != "chat" || s.JobID != "ml-1" || s.ModelPath != model || s.Port != 8123 || !s.Adopted {
+		t.Errorf("adopted serve = %+v", s)
+	}
+	if job, _ := srv.getJob("ml-2"); job.Status != "failed" {
+		t.Errorf("job of the exited serve = %s, want failed", job.Status)
+	}
+
+	// Stopping an adopted serve signals its process and finishes its job
+	if _, err := srv.stopLocalServe("chat"); err != nil {
+		t.Fatalf("stopLocalServe: %v", err)
+	}
+	if err := sleeper.Wait(); err == nil {
+		t.Error("the adopted process was not signalled")
+	}
+}
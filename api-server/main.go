@@ -23,44 +23,57 @@ import (
 // Structs
 // -----------------------------------------------------------------------------
 
-// Model represents a model record (from 'ilab model list').
+// Model represents a model record, read from the models directory or 'ilab model list'.
 type Model struct {
 	Name         string `json:"name"`
 	LastModified string `json:"last_modified"`
 	Size         string `json:"size"`
+	Bytes        int64  `json:"bytes,omitempty"`
+	Format       string `json:"format,omitempty"`       // "gguf" or "safetensors"
+	Architecture string `json:"architecture,omitempty"` // e.g. "llama", "granite"
+	Quantization string `json:"quantization,omitempty"` // e.g. "Q4_K_M" for GGUF, the dtype or quantization method otherwise
 }
 
-// Data represents a data record (from 'ilab data list').
+// Data represents a data record, read from the datasets directory or 'ilab data list'.
 type Data struct {
 	Dataset   string `json:"dataset"`
 	CreatedAt string `json:"created_at"`
 	FileSize  string `json:"file_size"`
+	Bytes     int64  `json:"bytes,omitempty"`
 }
 
 // Job represents a background job, including train/generate/pipeline/vllm-run jobs.
 type Job struct {
-	JobID           string     `json:"job_id"`
-	Cmd             string     `json:"cmd"`
-	Args            []string   `json:"args"`
-	Status          string     `json:"status"` // "running", "finished", "failed"
-	PID             int        `json:"pid"`
-	LogFile         string     `json:"log_file"`
-	StartTime       time.Time  `json:"start_time"`
-	EndTime         *time.Time `json:"end_time,omitempty"`
-	Branch          string     `json:"branch"`
-	ServedModelName string     `json:"served_model_name"`
+	JobID           string        `json:"job_id"`
+	Cmd             string        `json:"cmd"`
+	Args            []string      `json:"args"`
+	Status          string        `json:"status"` // "running", "finished", "failed"
+	PID             int           `json:"pid"`
+	LogFile         string        `json:"log_file"`
+	StartTime       time.Time     `json:"start_time"`
+	EndTime         *time.Time    `json:"end_time,omitempty"`
+	Branch          string        `json:"branch"`
+	ServedModelName string        `json:"served_model_name"`
+	Dataset         string        `json:"dataset,omitempty"` // dataset file a training job trains on
+	TrainSpec       *TrainingSpec `json:"train_spec,omitempty"`
+	// ParentJobID links a job to the job it continues, e.g. the training job whose
+	// checkpoint it resumed from.
+	ParentJobID      string `json:"parent_job_id,omitempty"`
+	ResumeCheckpoint string `json:"resume_checkpoint,omitempty"` // checkpoint a training job resumed from
+	Phase            string `json:"phase,omitempty"`             // phase of a phased training job, e.g. "knowledge"
+	// BaseModel is the model a training job started from; a resumed job keeps the base model
+	// of its checkpoint.
+	BaseModel string `json:"base_model,omitempty"`
+	// CommitSHA is the taxonomy commit the job's data came from. TaxonomyDirty is set when
+	// the job read a working copy with uncommitted changes, so CommitSHA alone doesn't
+	// reproduce it.
+	CommitSHA     string `json:"commit_sha,omitempty"`
+	TaxonomyDirty bool   `json:"taxonomy_dirty,omitempty"`
 
 	// Lock is not serialized; it protects updates to the Job in memory.
 	Lock sync.Mutex `json:"-"`
 }
 
-// ModelCache encapsulates the cached models and related metadata.
-type ModelCache struct {
-	Models []Model
-	Time   time.Time
-	Mutex  sync.Mutex
-}
-
 // QnaEvalRequest is used by the /qna-eval endpoint.
 type QnaEvalRequest struct {
 	ModelPath string `json:"model_path"`
@@ -74,6 +87,8 @@ type VllmContainerResponse struct {
 
 type ServeModelRequest struct {
 	Checkpoint string `json:"checkpoint,omitempty"` // Optional: Name of the checkpoint directory (e.g., "samples_12345")
+	LatestBy   string `json:"latest_by,omitempty"`  // Optional: "newest" (default) or "samples", used when no checkpoint is given
+	JobID      string `json:"job_id,omitempty"`     // Optional: only consider checkpoints produced by this training job
 }
 
 // UnloadModelRequest is used by the /vllm-unload endpoint.
@@ -97,6 +112,20 @@ type ILabServer struct {
 	debugEnabled bool
 	homeDir      string
 
+	// trainProfileName overrides the platform profile training runs with (see trainProfile)
+	trainProfileName string
+
+	// Container runtime used for vLLM serving and qna-eval
+	containerRuntimeName string
+	containerSocket      string
+	k8sNamespace         string
+	k8sPendingTimeout    time.Duration
+	containerRuntime     ContainerRuntime
+
+	// serverID identifies this server instance on the containers it launches
+	serverID    string
+	reapUnowned bool
+
 	// Logger
 	logger *zap.Logger
 	log    *zap.SugaredLogger
@@ -104,27 +133,45 @@ type ILabServer struct {
 	// Database handle
 	db *sql.DB
 
-	// Model processes for CPU-based or local serving (if not using VLLM)
-	modelLock          sync.Mutex
-	modelProcessBase   *exec.Cmd
-	modelProcessLatest *exec.Cmd
+	// Model processes for CPU-based or local serving (if not using VLLM), keyed by deployment name
+	localServesMu  sync.Mutex
+	localServes    map[string]*LocalServe
+	maxLocalServes int
+	servePortStart int
 
-	// Base model reference
+	// Default base model for serve-base and training (--base-model)
 	baseModel string
 
+	// Hugging Face endpoint override for model downloads (--hf-endpoint)
+	hfEndpoint string
+
 	// Map of "pre-train"/"post-train" => jobID for VLLM serving
 	servedModelJobIDs map[string]string
 	jobIDsMutex       sync.RWMutex
 
 	// Cache variables
-	modelCache ModelCache
+	modelCache         ModelCache
+	modelWatchInterval time.Duration
+
+	// Training metrics collectors, keyed by job ID
+	metricsMu         sync.Mutex
+	metricsCollectors map[string]*metricsCollector
+
+	// Log progress trackers of generate and train jobs, keyed by job ID
+	progressMu       sync.Mutex
+	progressTrackers map[string]*progressTracker
+
+	// taxonomyMu serializes git commands in the taxonomy repository
+	taxonomyMu sync.Mutex
 }
 
 func main() {
 	srv := &ILabServer{
-		baseModel:         "instructlab/granite-7b-lab",
 		servedModelJobIDs: make(map[string]string),
+		localServes:       make(map[string]*LocalServe),
 		modelCache:        ModelCache{},
+		metricsCollectors: make(map[string]*metricsCollector),
+		progressTrackers:  make(map[string]*progressTracker),
 	}
 
 	rootCmd := &cobra.Command{
@@ -143,7 +190,19 @@ func main() {
 	rootCmd.Flags().BoolVar(&srv.isCuda, "cuda", false, "Enable Cuda (default: false)")
 	rootCmd.Flags().BoolVar(&srv.useVllm, "vllm", false, "Enable VLLM model serving using podman containers")
 	rootCmd.Flags().StringVar(&srv.pipelineType, "pipeline", "", "Pipeline type (simple, accelerated, full)")
+	rootCmd.Flags().StringVar(&srv.trainProfileName, "train-profile", "", "Platform profile for training commands (rhelai, mps, cuda, cpu; default: derived from --rhelai, --osx and --cuda)")
 	rootCmd.Flags().BoolVar(&srv.debugEnabled, "debug", false, "Enable debug logging")
+	rootCmd.Flags().StringVar(&srv.baseModel, "base-model", "", "Default base model for serve-base and training (default: granite-8b-starter-v1 with vLLM/RHEL AI, granite-7b-lab-Q4_K_M.gguf otherwise)")
+	rootCmd.Flags().StringVar(&srv.hfEndpoint, "hf-endpoint", "", "Hugging Face endpoint used by model downloads (sets HF_ENDPOINT, e.g. a local mirror)")
+	rootCmd.Flags().IntVar(&srv.maxLocalServes, "max-local-serves", 4, "Maximum number of concurrent local (non-vLLM) model serves")
+	rootCmd.Flags().DurationVar(&srv.modelWatchInterval, "model-watch-interval", 5*time.Second, "How often the models directory is checked for changes that refresh the model cache (0 disables)")
+	rootCmd.Flags().IntVar(&srv.servePortStart, "serve-port-start", 8000, "First port tried when allocating ports for local model serves")
+	rootCmd.Flags().StringVar(&srv.containerRuntimeName, "container-runtime", "podman", "Container runtime for model serving (podman, podman-cli, docker, kubernetes, fake)")
+	rootCmd.Flags().StringVar(&srv.containerSocket, "container-socket", "", "Unix socket of the container engine API (default: podman system service socket, or /var/run/docker.sock for docker)")
+	rootCmd.Flags().StringVar(&srv.serverID, "server-id", "", "Server instance ID used to label containers (default: generated once and stored in jobs.db)")
+	rootCmd.Flags().BoolVar(&srv.reapUnowned, "reap-unowned-containers", false, "Stop containers of this server on startup whose job is no longer running")
+	rootCmd.Flags().StringVar(&srv.k8sNamespace, "k8s-namespace", "", "Namespace for model serving pods (kubernetes runtime; defaults to the server's namespace)")
+	rootCmd.Flags().DurationVar(&srv.k8sPendingTimeout, "k8s-pending-timeout", 10*time.Minute, "How long a model serving pod may stay Pending before its job fails (kubernetes runtime; 0 waits indefinitely)")
 
 	// PreRun to validate flags
 	rootCmd.PreRunE = func(cmd *cobra.Command, args []string) error {
@@ -153,6 +212,21 @@ func main() {
 		if srv.taxonomyPath == "" {
 			return fmt.Errorf("--taxonomy-path is required")
 		}
+		if srv.maxLocalServes < 1 {
+			return fmt.Errorf("--max-local-serves must be at least 1")
+		}
+		if srv.servePortStart < 1 || srv.servePortStart > 65535-localServePortSearch {
+			return fmt.Errorf("--serve-port-start must be between 1 and %d", 65535-localServePortSearch)
+		}
+		if _, ok := trainCommandBuilders[srv.trainProfileName]; srv.trainProfileName != "" && !ok {
+			return fmt.Errorf("--train-profile must be one of %s; got '%s'", strings.Join(trainProfileNames(), ", "), srv.trainProfileName)
+		}
+		switch srv.containerRuntimeName {
+		case "podman", "podman-cli", "docker", "kubernetes", "fake":
+			// Valid
+		default:
+			return fmt.Errorf("--container-runtime must be 'podman', 'podman-cli', 'docker', 'kubernetes' or 'fake'; got '%s'", srv.containerRuntimeName)
+		}
 
 		// Validate or set pipelineType based on --rhelai
 		if !srv.rhelai {
@@ -196,6 +270,16 @@ func (srv *ILabServer) runServer(cmd *cobra.Command, args []string) {
 	// Initialize the database
 	srv.initDB()
 
+	// Resolve the server instance ID used to label containers
+	if srv.serverID == "" {
+		id, err := srv.getOrCreateServerID()
+		if err != nil {
+			srv.log.Fatalf("Failed to determine server ID: %v", err)
+		}
+		srv.serverID = id
+	}
+	srv.log.Infof("Server instance ID: %s", srv.serverID)
+
 	// Determine the user's home directory / TODO: alternative approch here for expected path?
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -233,17 +317,31 @@ func (srv *ILabServer) runServer(cmd *cobra.Command, args []string) {
 		srv.log.Fatalf("Taxonomy path does not exist: %s", srv.taxonomyPath)
 	}
 
-	srv.log.Infof("Running with baseDir=%s, taxonomyPath=%s, isOSX=%v, isCuda=%v, useVllm=%v, pipeline=%s",
-		srv.baseDir, srv.taxonomyPath, srv.isOSX, srv.isCuda, srv.useVllm, srv.pipelineType)
+	// Initialize the container runtime
+	srv.containerRuntime, err = srv.newContainerRuntime()
+	if err != nil {
+		srv.log.Fatalf("Failed to initialize container runtime: %v", err)
+	}
+
+	srv.log.Infof("Running with baseDir=%s, taxonomyPath=%s, isOSX=%v, isCuda=%v, useVllm=%v, pipeline=%s, containerRuntime=%s",
+		srv.baseDir, srv.taxonomyPath, srv.isOSX, srv.isCuda, srv.useVllm, srv.pipelineType, srv.containerRuntime.Name())
 	srv.log.Infof("Current working directory: %s", srv.mustGetCwd())
 
 	// Check statuses of any jobs that might have been running before a restart
 	srv.checkRunningJobs()
+	srv.cleanupTaxonomyWorktrees()
 
 	// Initialize the model cache
 	srv.initializeModelCache()
 
-	srv.reconstructServedModelJobIDs()
+	// Adopt vLLM containers and local serve processes that survived a restart
+	srv.reconcileContainers()
+	srv.reconcileLocalServes()
+
+	// Push container lifecycle events into job status where the runtime supports it
+	if events, ok := srv.containerRuntime.(ContainerEventSource); ok {
+		go srv.watchContainerEvents(events)
+	}
 
 	// Create the logs directory if it doesn't exist
 	err = os.MkdirAll("logs", os.ModePerm)
@@ -254,22 +352,50 @@ func (srv *ILabServer) runServer(cmd *cobra.Command, args []string) {
 	// Setup HTTP routes
 	r := mux.NewRouter()
 	r.HandleFunc("/models", srv.getModelsHandler).Methods("GET")
+	r.HandleFunc("/models/refresh", srv.refreshModelsHandler).Methods("POST")
+	r.HandleFunc("/models/download", srv.downloadModelHandler).Methods("POST")
+	r.HandleFunc("/models/{name:.+}/serve", srv.serveNamedModelHandler).Methods("POST")
+	r.HandleFunc("/models/{name:.+}", srv.deleteModelHandler).Methods("DELETE")
 	r.HandleFunc("/data", srv.getDataHandler).Methods("GET")
 	r.HandleFunc("/data/generate", srv.generateDataHandler).Methods("POST")
+	r.HandleFunc("/data/curate", srv.curateDatasetHandler).Methods("POST")
+	r.HandleFunc("/data/{dataset:.+}/records", srv.getDatasetRecordsHandler).Methods("GET")
+	r.HandleFunc("/data/{dataset:.+}/stats", srv.getDatasetStatsHandler).Methods("GET")
+	r.HandleFunc("/data/{dataset:.+}/lineage", srv.getDatasetLineageHandler).Methods("GET")
 	r.HandleFunc("/model/train", srv.trainModelHandler).Methods("POST")
+	r.HandleFunc("/model/train/phased", srv.phasedTrainHandler).Methods("POST")
+	r.HandleFunc("/model/train/presets", srv.listTrainingPresetsHandler).Methods("GET")
+	r.HandleFunc("/model/train/presets/{name}", srv.getTrainingPresetHandler).Methods("GET")
+	r.HandleFunc("/model/train/presets/{name}", srv.putTrainingPresetHandler).Methods("PUT")
+	r.HandleFunc("/model/train/presets/{name}", srv.deleteTrainingPresetHandler).Methods("DELETE")
 	r.HandleFunc("/jobs/{job_id}/status", srv.getJobStatusHandler).Methods("GET")
 	r.HandleFunc("/jobs/{job_id}/logs", srv.getJobLogsHandler).Methods("GET")
+	r.HandleFunc("/jobs/{job_id}/metrics", srv.getJobMetricsHandler).Methods("GET")
 	r.HandleFunc("/jobs", srv.listJobsHandler).Methods("GET")
 	r.HandleFunc("/pipeline/generate-train", srv.generateTrainPipelineHandler).Methods("POST")
 	r.HandleFunc("/model/serve-latest", srv.serveLatestCheckpointHandler).Methods("POST")
 	r.HandleFunc("/model/serve-base", srv.serveBaseModelHandler).Methods("POST")
 	r.HandleFunc("/qna-eval", srv.runQnaEval).Methods("POST")
 	r.HandleFunc("/checkpoints", srv.listCheckpointsHandler).Methods("GET")
+	r.HandleFunc("/checkpoints/latest", srv.getLatestCheckpointHandler).Methods("GET")
+	r.HandleFunc("/checkpoints/{name}/promote", srv.promoteCheckpointHandler).Methods("POST")
+	r.HandleFunc("/checkpoints/{name}", srv.deleteCheckpointHandler).Methods("DELETE")
+	r.HandleFunc("/taxonomy/branches", srv.listTaxonomyBranchesHandler).Methods("GET")
+	r.HandleFunc("/taxonomy/branches", srv.createTaxonomyBranchHandler).Methods("POST")
+	r.HandleFunc("/taxonomy/branches/{name:.+}", srv.deleteTaxonomyBranchHandler).Methods("DELETE")
+	r.HandleFunc("/taxonomy/diff", srv.taxonomyDiffHandler).Methods("GET")
+	r.HandleFunc("/taxonomy/tree", srv.listTaxonomyTreeHandler).Methods("GET")
+	r.HandleFunc("/taxonomy/files/{path:.+}", srv.getTaxonomyFileHandler).Methods("GET")
+	r.HandleFunc("/taxonomy/files/{path:.+}", srv.putTaxonomyFileHandler).Methods("PUT")
+	r.HandleFunc("/storage", srv.getStorageHandler).Methods("GET")
 	r.HandleFunc("/vllm-containers", srv.listVllmContainersHandler).Methods("GET")
 	r.HandleFunc("/vllm-unload", srv.unloadVllmContainerHandler).Methods("POST")
 	r.HandleFunc("/vllm-status", srv.getVllmStatusHandler).Methods("GET")
 	r.HandleFunc("/gpu-free", srv.getGpuFreeHandler).Methods("GET")
 	r.HandleFunc("/served-model-jobids", srv.listServedModelJobIDsHandler).Methods("GET")
+	r.HandleFunc("/serves", srv.listLocalServesHandler).Methods("GET")
+	r.HandleFunc("/serves", srv.startLocalServeHandler).Methods("POST")
+	r.HandleFunc("/serves/{name}", srv.stopLocalServeHandler).Methods("DELETE")
 	r.HandleFunc("/model/convert", srv.convertModelHandler).Methods("POST")
 
 	srv.log.Info("Server starting on port 8080... (Taxonomy path: ", srv.taxonomyPath, ")")
@@ -305,114 +431,77 @@ func (srv *ILabServer) sanitizeModelName(modelName string) string {
 }
 
 // -----------------------------------------------------------------------------
-// Model Cache
+// Start Generate Data Job
 // -----------------------------------------------------------------------------
 
-// initializeModelCache refreshes the model cache once and then schedules a refresh every 20 minutes.
-func (srv *ILabServer) initializeModelCache() {
-	srv.refreshModelCache()
-	go func() {
-		for {
-			time.Sleep(20 * time.Minute)
-			srv.refreshModelCache()
-		}
-	}()
-}
-
-// refreshModelCache updates the model cache if it's older than 20 minutes or if empty.
-// TODO: this is really slow due to a caching issue upstream/downstream, should probably be async
-func (srv *ILabServer) refreshModelCache() {
-	srv.modelCache.Mutex.Lock()
-	defer srv.modelCache.Mutex.Unlock()
+// startGenerateJob launches a job to run "ilab data generate" with a validated request and
+// tracks it. When the request names a branch, the job generates from a worktree of it (see
+// addTaxonomyWorktree); a failed checkout is recorded as a failed job.
+func (srv *ILabServer) startGenerateJob(req *GenerateDataRequest) (string, error) {
+	ilabPath := srv.getIlabCommand()
 
-	if time.Since(srv.modelCache.Time) < 20*time.Minute && len(srv.modelCache.Models) > 0 {
-		srv.log.Info("Model cache is still valid; no refresh needed.")
-		return
-	}
+	jobID := fmt.Sprintf("g-%d", time.Now().UnixNano())
+	logFilePath := filepath.Join("logs", fmt.Sprintf("%s.log", jobID))
 
-	srv.log.Info("Refreshing model cache... Takes 10-20s")
-	output, err := srv.runIlabCommand("model", "list")
-	if err != nil {
-		srv.log.Errorf("Error refreshing model cache: %v", err)
-		return
+	// Each generate job writes to its own directory so its output can be handed to training exactly
+	var outputDir string
+	var err error
+	if req.OutputDir != "" {
+		outputDir, err = resolveGenerateOutputDir(req.OutputDir)
+	} else {
+		outputDir, err = generateOutputDir(jobID)
 	}
-	models, err := srv.parseModelList(output)
 	if err != nil {
-		srv.log.Errorf("Error parsing model list during cache refresh: %v", err)
-		return
+		return "", fmt.Errorf("failed to get dataset output directory: %v", err)
 	}
-	srv.modelCache.Models = models
-	srv.modelCache.Time = time.Now()
-	srv.log.Infof("Model cache refreshed at %v with %d models.", srv.modelCache.Time, len(models))
-}
 
-// reconstructServedModelJobIDs rebuilds the servedModelJobIDs map by querying the database
-func (srv *ILabServer) reconstructServedModelJobIDs() {
-	srv.log.Info("Reconstructing servedModelJobIDs from the database...")
+	cmdArgs := req.generateArgs(outputDir)
+	srv.log.Infof("Starting generateDataHandler job: %s, logs: %s", jobID, logFilePath)
 
-	rows, err := srv.db.Query(`
-        SELECT job_id, served_model_name
-        FROM jobs
-        WHERE cmd = 'podman' AND status = 'running'
-    `)
+	logFile, err := os.Create(logFilePath)
 	if err != nil {
-		srv.log.Errorf("Error querying running vLLM jobs: %v", err)
-		return
+		srv.log.Errorf("Error creating log file: %v", err)
+		return "", fmt.Errorf("Failed to create log file")
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var jobID, servedModelName string
-		if err := rows.Scan(&jobID, &servedModelName); err != nil {
-			srv.log.Errorf("Error scanning row: %v", err)
-			continue
-		}
 
-		// Validate servedModelName
-		if servedModelName != "pre-train" && servedModelName != "post-train" {
-			srv.log.Warnf("Invalid served_model_name '%s' for job_id '%s'", servedModelName, jobID)
-			continue
+	// Generate from a worktree of the branch, so concurrent jobs on other branches don't
+	// change the taxonomy under this one. Without a branch, ilab reads the shared working
+	// copy, uncommitted changes included.
+	var commitSHA string
+	var taxonomyDirty bool
+	if req.BranchName != "" {
+		worktree, sha, err := srv.addTaxonomyWorktree(jobID, req.BranchName, logFile)
+		if err != nil {
+			fmt.Fprintln(logFile, err)
+			logFile.Close()
+			srv.log.Errorf("Generate job %s failed: %v", jobID, err)
+			now := time.Now()
+			failedJob := &Job{
+				JobID:     jobID,
+				Cmd:       ilabPath,
+				Args:      cmdArgs,
+				Status:    "failed",
+				LogFile:   logFilePath,
+				Branch:    req.BranchName,
+				StartTime: now,
+				EndTime:   &now,
+			}
+			srv.removeTaxonomyWorktree(jobID)
+			if err := srv.createJob(failedJob); err != nil {
+				return "", err
+			}
+			return jobID, nil
 		}
-
-		// Update the servedModelJobIDs map
-		srv.jobIDsMutex.Lock()
-		srv.servedModelJobIDs[servedModelName] = jobID
-		srv.jobIDsMutex.Unlock()
-		srv.log.Infof("Mapped model '%s' to job_id '%s'", servedModelName, jobID)
-	}
-
-	if err := rows.Err(); err != nil {
-		srv.log.Errorf("Error iterating over rows: %v", err)
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--taxonomy-path=%s", worktree))
+		commitSHA = sha
+	} else if commitSHA, taxonomyDirty, err = srv.taxonomyWorkingCopyState(); err != nil {
+		srv.log.Warnf("Could not record the taxonomy commit of job %s: %v", jobID, err)
 	}
 
-	srv.log.Info("Reconstruction of servedModelJobIDs completed.")
-}
-
-// -----------------------------------------------------------------------------
-// Start Generate Data Job
-// -----------------------------------------------------------------------------
-
-// startGenerateJob launches a job to run "ilab data generate" and tracks it.
-func (srv *ILabServer) startGenerateJob() (string, error) {
-	ilabPath := srv.getIlabCommand()
-
-	// Hard-coded pipeline choice for data generate, or we could use srv.pipelineType
-	cmdArgs := []string{"data", "generate", "--pipeline", "full"}
-
 	cmd := exec.Command(ilabPath, cmdArgs...)
 	if !srv.rhelai {
 		cmd.Dir = srv.baseDir
 	}
-
-	jobID := fmt.Sprintf("g-%d", time.Now().UnixNano())
-	logFilePath := filepath.Join("logs", fmt.Sprintf("%s.log", jobID))
-	srv.log.Infof("Starting generateDataHandler job: %s, logs: %s", jobID, logFilePath)
-
-	logFile, err := os.Create(logFilePath)
-	if err != nil {
-		srv.log.Errorf("Error creating log file: %v", err)
-		return "", fmt.Errorf("Failed to create log file")
-	}
 	cmd.Stdout = logFile
 	cmd.Stderr = logFile
 
@@ -420,17 +509,21 @@ func (srv *ILabServer) startGenerateJob() (string, error) {
 	if err := cmd.Start(); err != nil {
 		srv.log.Errorf("Error starting data generation command: %v", err)
 		logFile.Close()
+		srv.removeTaxonomyWorktree(jobID)
 		return "", err
 	}
 
 	newJob := &Job{
-		JobID:     jobID,
-		Cmd:       ilabPath,
-		Args:      cmdArgs,
-		Status:    "running",
-		PID:       cmd.Process.Pid,
-		LogFile:   logFilePath,
-		StartTime: time.Now(),
+		JobID:         jobID,
+		Cmd:           ilabPath,
+		Args:          cmdArgs,
+		Status:        "running",
+		PID:           cmd.Process.Pid,
+		LogFile:       logFilePath,
+		Branch:        req.BranchName,
+		CommitSHA:     commitSHA,
+		TaxonomyDirty: taxonomyDirty,
+		StartTime:     time.Now(),
 	}
 	if err := srv.createJob(newJob); err != nil {
 		srv.log.Errorf("Error creating job in DB: %v", err)
@@ -440,6 +533,7 @@ func (srv *ILabServer) startGenerateJob() (string, error) {
 	go func() {
 		defer logFile.Close()
 		err := cmd.Wait()
+		srv.removeTaxonomyWorktree(newJob.JobID)
 
 		newJob.Lock.Lock()
 		defer newJob.Lock.Unlock()
@@ -468,105 +562,55 @@ func (srv *ILabServer) startGenerateJob() (string, error) {
 // Start Train Job
 // -----------------------------------------------------------------------------
 
-// startTrainJob starts a training job with the given parameters.
-func (srv *ILabServer) startTrainJob(modelName, branchName string, epochs *int) (string, error) {
-	srv.log.Infof("Starting training job for model: '%s', branch: '%s'", modelName, branchName)
+// startTrainJob starts a training job. req.DataPath selects the dataset file; when empty,
+// RHEL AI trains on the latest dataset and ilab picks its default otherwise.
+func (srv *ILabServer) startTrainJob(req *TrainJobRequest) (string, error) {
+	srv.log.Infof("Starting training job for model: '%s', branch: '%s', dataset: '%s'", req.ModelName, req.BranchName, req.DataPath)
 
 	jobID := fmt.Sprintf("t-%d", time.Now().UnixNano())
 	logFilePath := filepath.Join("logs", fmt.Sprintf("%s.log", jobID))
 
-	fullModelPath, err := srv.getFullModelPath(modelName)
-	if err != nil {
-		return "", fmt.Errorf("failed to get full model path: %v", err)
-	}
-	srv.log.Infof("Resolved fullModelPath: '%s'", fullModelPath)
-
-	modelDir := filepath.Dir(fullModelPath)
-	if err := os.MkdirAll(modelDir, os.ModePerm); err != nil {
-		return "", fmt.Errorf("failed to create model directory '%s': %v", modelDir, err)
-	}
-
-	ilabPath := srv.getIlabCommand()
-
-	var cmdArgs []string
-	cmdArgs = append(cmdArgs, "model", "train")
-
-	// If not rhelai, add pipeline if set
-	if !srv.rhelai && srv.pipelineType != "" {
-		cmdArgs = append(cmdArgs, "--pipeline", srv.pipelineType)
-	}
-	cmdArgs = append(cmdArgs, fmt.Sprintf("--model-path=%s", fullModelPath))
-
-	if srv.isOSX {
-		cmdArgs = append(cmdArgs, "--device=mps")
-	}
-	if srv.isCuda {
-		cmdArgs = append(cmdArgs, "--device=cuda")
-	}
-	if epochs != nil {
-		cmdArgs = append(cmdArgs, fmt.Sprintf("--num-epochs=%d", *epochs))
-		srv.log.Infof("Number of epochs specified: %d", *epochs)
+	if req.Spec != nil && req.Spec.NumEpochs != nil {
+		srv.log.Infof("Number of epochs specified: %d", *req.Spec.NumEpochs)
 	} else {
 		srv.log.Info("No epochs specified; using default number of epochs.")
 	}
 
-	if srv.pipelineType == "simple" && !srv.rhelai {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return "", fmt.Errorf("failed to get user home directory: %v", err)
-		}
-		modelDir := filepath.Join(homeDir, ".cache", "instructlab", "models")
-
-		cmdArgs = []string{
-			"model", "train",
-			"--pipeline", srv.pipelineType,
-			"--optimize-memory",
-			fmt.Sprintf("--gguf-model-path=%s/%s", modelDir, modelName),
-		}
-		if srv.isOSX {
-			cmdArgs = append(cmdArgs, "--device=mps")
-		}
-		if srv.isCuda {
-			cmdArgs = append(cmdArgs, "--device=cuda")
-		}
-		if epochs != nil {
-			cmdArgs = append(cmdArgs, fmt.Sprintf("--num-epochs=%d", *epochs))
-			srv.log.Infof("Number of epochs specified for simple pipeline: %d", *epochs)
-		} else {
-			srv.log.Info("No epochs specified for simple pipeline; using default number of epochs.")
+	plan, err := srv.buildTrainCommand(req, jobID)
+	if err != nil {
+		return "", err
+	}
+	srv.log.Infof("Training with profile '%s'", plan.Profile)
+	if plan.OutputDir != "" {
+		// Create it up front: its presence marks the checkpoints and metrics there as the job's own
+		if err := os.MkdirAll(plan.OutputDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create output directory '%s': %v", plan.OutputDir, err)
 		}
 	}
 
-	if srv.rhelai {
-		latestDataset, err := srv.getLatestDatasetFile()
+	var baseModel string
+	if req.ResumeFrom != nil {
+		srv.log.Infof("Resuming from checkpoint '%s' of job '%s'", plan.ResumeCheckpoint, plan.ParentJobID)
+		baseModel = req.ResumeFrom.BaseModel
+	} else {
+		fullModelPath, err := getFullModelPath(req.ModelName)
 		if err != nil {
-			return "", fmt.Errorf("failed to get latest dataset file: %v", err)
-		}
-		cmdArgs = []string{
-			"model", "train",
-			fmt.Sprintf("--data-path=%s", latestDataset),
-			"--max-batch-len=5000",
-			"--gpus=4",
-			"--device=cuda",
-			"--save-samples=1000",
-			fmt.Sprintf("--model-path=%s", fullModelPath),
-			"--pipeline", srv.pipelineType,
+			return "", fmt.Errorf("failed to get full model path: %v", err)
 		}
-		if epochs != nil {
-			cmdArgs = append(cmdArgs, fmt.Sprintf("--num-epochs=%d", *epochs))
-			srv.log.Infof("Number of epochs specified for rhelai pipeline: %d", *epochs)
-		} else {
-			srv.log.Info("No epochs specified for rhelai pipeline; using default number of epochs.")
+		baseModel = modelNameFromPath(fullModelPath)
+		modelDir := filepath.Dir(fullModelPath)
+		if err := os.MkdirAll(modelDir, os.ModePerm); err != nil {
+			return "", fmt.Errorf("failed to create model directory '%s': %v", modelDir, err)
 		}
 	}
 
+	ilabPath, cmdArgs := plan.Cmd, plan.Args
+
 	finalCmdString := fmt.Sprintf("[ILAB TRAIN COMMAND] %s %v", ilabPath, cmdArgs)
 	srv.log.Info(finalCmdString)
 
 	cmd := exec.Command(ilabPath, cmdArgs...)
-	if !srv.rhelai {
-		cmd.Dir = srv.baseDir
-	}
+	cmd.Dir = plan.Dir
 
 	logFile, err := os.Create(logFilePath)
 	if err != nil {
@@ -579,7 +623,6 @@ func (srv *ILabServer) startTrainJob(modelName, branchName string, epochs *int)
 
 	fmt.Fprintln(logFile, finalCmdString)
 
-	srv.log.Infof("[ILAB TRAIN COMMAND] %s %v", ilabPath, cmdArgs)
 	if err := cmd.Start(); err != nil {
 		return "", fmt.Errorf("error starting training command: %v", err)
 	}
@@ -587,23 +630,36 @@ func (srv *ILabServer) startTrainJob(modelName, branchName string, epochs *int)
 
 	// Create a DB record for this job
 	newJob := &Job{
-		JobID:     jobID,
-		Cmd:       ilabPath,
-		Args:      cmdArgs,
-		Status:    "running",
-		PID:       cmd.Process.Pid,
-		LogFile:   logFilePath,
-		Branch:    branchName,
-		StartTime: time.Now(),
+		JobID:            jobID,
+		Cmd:              ilabPath,
+		Args:             cmdArgs,
+		Status:           "running",
+		PID:              cmd.Process.Pid,
+		LogFile:          logFilePath,
+		Branch:           req.BranchName,
+		Dataset:          plan.Dataset,
+		TrainSpec:        plan.TrainSpec,
+		ParentJobID:      plan.ParentJobID,
+		ResumeCheckpoint: plan.ResumeCheckpoint,
+		BaseModel:        baseModel,
+		Phase:            req.Phase,
+		CommitSHA:        plan.CommitSHA,
+		TaxonomyDirty:    plan.TaxonomyDirty,
+		StartTime:        time.Now(),
 	}
 	if err := srv.createJob(newJob); err != nil {
 		return "", fmt.Errorf("failed to create job in DB: %v", err)
 	}
 
+	// Collect training metrics while the job runs
+	metricsDone := make(chan struct{})
+	go srv.runMetricsCollector(newJob, metricsDone)
+
 	// Wait in a goroutine for the job to complete
 	go func() {
 		defer logFile.Close()
 		err := cmd.Wait()
+		close(metricsDone)
 
 		newJob.Lock.Lock()
 		defer newJob.Lock.Unlock()
@@ -618,6 +674,9 @@ func (srv *ILabServer) startTrainJob(modelName, branchName string, epochs *int)
 			newJob.Status = "failed"
 			srv.log.Infof("Training job '%s' failed (unknown reason)", newJob.JobID)
 		}
+		// Attribute the checkpoints before the job is seen as done, so whoever waits on the
+		// job (e.g. phased training) can select among them
+		srv.recordCheckpoints(newJob)
 		now := time.Now()
 		newJob.EndTime = &now
 		_ = srv.updateJob(newJob)
@@ -643,13 +702,25 @@ func (srv *ILabServer) generateTrainPipelineHandler(w http.ResponseWriter, r *ht
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	if reqBody.ModelName == "" || reqBody.BranchName == "" {
-		srv.log.Info("Missing required parameters: modelName or branchName")
-		http.Error(w, "Missing required parameters: modelName or branchName", http.StatusBadRequest)
+	if reqBody.BranchName == "" {
+		srv.log.Info("Missing required parameter: branchName")
+		http.Error(w, "Missing required parameter: branchName", http.StatusBadRequest)
+		return
+	}
+	if err := srv.validateBranch(reqBody.BranchName); err != nil {
+		srv.writeBranchError(w, reqBody.BranchName, err)
 		return
 	}
+	if reqBody.ModelName == "" {
+		reqBody.ModelName = srv.defaultBaseModel()
+		srv.log.Infof("No modelName provided. Using the default base model: %s", reqBody.ModelName)
+	}
 
-	sanitizedModelName := srv.sanitizeModelName(reqBody.ModelName)
+	sanitizedModelName, _, err := srv.resolveModel(reqBody.ModelName)
+	if err != nil {
+		srv.writeModelError(w, reqBody.ModelName, err)
+		return
+	}
 	srv.log.Infof("Sanitized modelName for pipeline: '%s'", sanitizedModelName)
 
 	pipelineJobID := fmt.Sprintf("p-%d", time.Now().UnixNano())
@@ -699,21 +770,14 @@ func (srv *ILabServer) runPipelineJob(job *Job, modelName, branchName string, ep
 	stdLogger.Printf("Starting pipeline job: %s, model: %s, branch: %s, epochs: %v",
 		job.JobID, modelName, branchName, epochs)
 
-	// 1) Git checkout
-	gitCheckoutCmd := exec.Command("git", "checkout", branchName)
-	gitCheckoutCmd.Dir = srv.taxonomyPath
-	gitOutput, gitErr := gitCheckoutCmd.CombinedOutput()
-	stdLogger.Printf("Git checkout output: %s", string(gitOutput))
-	if gitErr != nil {
-		stdLogger.Printf("Failed to checkout branch '%s': %v", branchName, gitErr)
-		job.Status = "failed"
-		_ = srv.updateJob(job)
-		return
-	}
-
-	// 2) Generate data step
+	// 1) Generate data step, which checks out the branch as part of the generate job
 	stdLogger.Println("Starting data generation step...")
-	genJobID, genErr := srv.startGenerateJob()
+	genReq := &GenerateDataRequest{BranchName: branchName}
+	genErr := genReq.validate(srv.pipelineType)
+	var genJobID string
+	if genErr == nil {
+		genJobID, genErr = srv.startGenerateJob(genReq)
+	}
 	if genErr != nil {
 		stdLogger.Printf("Data generation step failed: %v", genErr)
 		job.Status = "failed"
@@ -739,13 +803,27 @@ func (srv *ILabServer) runPipelineJob(job *Job, modelName, branchName string, ep
 		}
 		if genJob.Status == "finished" {
 			stdLogger.Println("Data generation step completed successfully.")
+			job.CommitSHA, job.TaxonomyDirty = genJob.CommitSHA, genJob.TaxonomyDirty
+			_ = srv.updateJob(job)
 			break
 		}
 	}
 
-	// 3) Train step
-	stdLogger.Println("Starting training step...")
-	trainJobID, trainErr := srv.startTrainJob(modelName, branchName, epochs)
+	// 2) Train step, on exactly the dataset the generate step produced
+	dataPath, err := srv.generatedDataset(genJobID)
+	if err != nil {
+		stdLogger.Printf("Could not find the dataset generated by job %s: %v", genJobID, err)
+		job.Status = "failed"
+		_ = srv.updateJob(job)
+		return
+	}
+	stdLogger.Printf("Starting training step on dataset %s...", dataPath)
+	trainJobID, trainErr := srv.startTrainJob(&TrainJobRequest{
+		ModelName:  modelName,
+		BranchName: branchName,
+		Spec:       &TrainingSpec{NumEpochs: epochs},
+		DataPath:   dataPath,
+	})
 	if trainErr != nil {
 		stdLogger.Printf("Training step failed to start: %v", trainErr)
 		job.Status = "failed"
@@ -815,18 +893,6 @@ func (srv *ILabServer) overwriteCopy(src, dst string) error {
 	return nil
 }
 
-// getFullModelPath returns the directory or file path for a given model name.
-func (srv *ILabServer) getFullModelPath(modelName string) (string, error) {
-	// If the user passed something like "models/instructlab/my-model" we keep it
-	// but place it in ~/.cache/instructlab/models/...
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("cannot find home directory: %v", err)
-	}
-	base := filepath.Join(home, ".cache", "instructlab")
-	return filepath.Join(base, modelName), nil
-}
-
 // runIlabCommand executes the ilab command with the provided arguments and returns combined output.
 func (srv *ILabServer) runIlabCommand(args ...string) (string, error) {
 	cmdPath := srv.getIlabCommand()
@@ -838,7 +904,8 @@ func (srv *ILabServer) runIlabCommand(args ...string) (string, error) {
 	return string(out), err
 }
 
-// parseModelList parses the output of the "ilab model list" command into a slice of Model.
+// parseModelList parses the table printed by "ilab model list". It is only used when the
+// models directory cannot be scanned.
 func (srv *ILabServer) parseModelList(output string) ([]Model, error) {
 	var models []Model
 	lines := strings.Split(output, "\n")
@@ -864,7 +931,8 @@ func (srv *ILabServer) parseModelList(output string) ([]Model, error) {
 	return models, nil
 }
 
-// parseDataList parses the output of the "ilab data list" command into a slice of Data.
+// parseDataList parses the table printed by "ilab data list". It is only used when the
+// datasets directory cannot be scanned.
 func (srv *ILabServer) parseDataList(output string) ([]Data, error) {
 	var dataList []Data
 	lines := strings.Split(output, "\n")
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// newTestServer returns a RHEL AI server running in a temporary directory, with its own
+// home, logs directory and jobs database.
+func newTestServer(t *testing.T) *ILabServer {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := os.Mkdir("logs", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &ILabServer{
+		rhelai:            true,
+		log:               zap.NewNop().Sugar(),
+		servedModelJobIDs: make(map[string]string),
+		localServes:       make(map[string]*LocalServe),
+		metricsCollectors: make(map[string]*metricsCollector),
+		progressTrackers:  make(map[string]*progressTracker),
+	}
+	srv.initDB()
+	t.Cleanup(func() { srv.db.Close() })
+	return srv
+}
+
+// waitForJob polls the jobs table until the job has ended.
+func waitForJob(t *testing.T, srv *ILabServer, jobID string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := srv.getJob(jobID)
+		if err != nil {
+			t.Fatalf("getJob: %v", err)
+		}
+		if job.Status != "running" {
+			return job
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not end", jobID)
+	return nil
+}
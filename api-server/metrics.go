@@ -0,0 +1,380 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// metricsPollInterval is how often a running training job's output is collected.
+const metricsPollInterval = 10 * time.Second
+
+// metricsFilePattern matches the JSONL metrics files instructlab-training writes next to
+// its checkpoints.
+const metricsFilePattern = "training_params_and_metrics_global*.jsonl"
+
+// metricNames maps the keys found in training output to the series they are stored as.
+var metricNames = map[string]string{
+	"loss":                     "loss",
+	"total_loss":               "loss",
+	"lr":                       "lr",
+	"learning_rate":            "lr",
+	"overall_throughput":       "throughput",
+	"throughput":               "throughput",
+	"train_samples_per_second": "throughput",
+	"grad_norm":                "grad_norm",
+	"gradnorm":                 "grad_norm",
+	"samples_seen":             "samples_seen",
+}
+
+// epochMarkerPattern matches epoch markers such as "Epoch 2/10" or "Epoch: 2".
+var epochMarkerPattern = regexp.MustCompile(`(?i)^\s*(?:\S+\s+)*?epoch\s*:?\s*(\d+)(?:\s*/\s*\d+)?\s*$`)
+
+// MetricPoint is one sample of a training metrics series.
+type MetricPoint struct {
+	Step  int       `json:"step"`
+	Epoch *float64  `json:"epoch,omitempty"`
+	Value float64   `json:"value"`
+	Time  time.Time `json:"time"`
+}
+
+// JobMetricsResponse is returned by GET /jobs/{job_id}/metrics.
+type JobMetricsResponse struct {
+	JobID  string                   `json:"job_id"`
+	Status string                   `json:"status"`
+	Series map[string][]MetricPoint `json:"series"`
+}
+
+// metricSample is a parsed metric before it is stored.
+type metricSample struct {
+	name  string
+	step  int
+	epoch *float64
+	value float64
+}
+
+// metricsCollector incrementally parses a training job's log and metrics files.
+type metricsCollector struct {
+	mu sync.Mutex
+	// offsets is how far each source has been parsed, keyed by path
+	offsets map[string]int64
+	// lastStep is the step of the latest record; records without a step are numbered after it
+	lastStep   int
+	lastMarker *float64
+}
+
+// epochMarker returns an "epoch" sample when the epoch differs from the last one seen.
+func (c *metricsCollector) epochMarker(epoch float64, step int) []metricSample {
+	if c.lastMarker != nil && *c.lastMarker == epoch {
+		return nil
+	}
+	c.lastMarker = &epoch
+	return []metricSample{{name: "epoch", step: step, epoch: &epoch, value: epoch}}
+}
+
+// parseMetricsLine extracts metric samples from a line of training output: JSON metric
+// objects (instructlab-training), Python dict logs (Hugging Face Trainer) and epoch markers.
+func (c *metricsCollector) parseMetricsLine(line string) []metricSample {
+	line = strings.TrimSpace(line)
+	if m := epochMarkerPattern.FindStringSubmatch(line); m != nil {
+		var epoch float64
+		fmt.Sscan(m[1], &epoch)
+		return c.epochMarker(epoch, c.lastStep)
+	}
+	if !strings.HasPrefix(line, "{") || !strings.HasSuffix(line, "}") {
+		return nil
+	}
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(line), &fields) != nil {
+		// Python dict reprs use single quotes
+		if json.Unmarshal([]byte(strings.ReplaceAll(line, "'", `"`)), &fields) != nil {
+			return nil
+		}
+	}
+
+	var samples []metricSample
+	step := c.lastStep + 1
+	if s, ok := fields["step"].(float64); ok {
+		step = int(s)
+	}
+	var epoch *float64
+	if e, ok := fields["epoch"].(float64); ok {
+		epoch = &e
+	}
+	for key, value := range fields {
+		name, known := metricNames[key]
+		v, isNumber := value.(float64)
+		if known && isNumber {
+			samples = append(samples, metricSample{name: name, step: step, epoch: epoch, value: v})
+		}
+	}
+	if len(samples) > 0 {
+		c.lastStep = step
+		if epoch != nil {
+			samples = append(samples, c.epochMarker(float64(int(*epoch)), step)...)
+		}
+	}
+	return samples
+}
+
+// readNewLines returns the complete lines appended to path since the last read. tqdm redraws
+// bars with carriage returns, so those are treated as line breaks.
+func (c *metricsCollector) readNewLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(c.offsets[path], io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	end := strings.LastIndexAny(string(data), "\n\r")
+	if end < 0 {
+		return nil, nil
+	}
+	c.offsets[path] += int64(end + 1)
+	return strings.FieldsFunc(string(data[:end]), func(r rune) bool { return r == '\n' || r == '\r' }), nil
+}
+
+// trainingMetricsFiles returns the metrics JSONL files the training library wrote for job:
+// those in its own output directory, or the shared ones for a job without one.
+func trainingMetricsFiles(job *Job) []string {
+	dir, err := getTrainOutputDir(job.JobID)
+	if err != nil {
+		return nil
+	}
+	if _, err := os.Stat(dir); err != nil {
+		checkpointsDir, err := getCheckpointsDir()
+		if err != nil {
+			return nil
+		}
+		dir = filepath.Dir(checkpointsDir)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, metricsFilePattern))
+	return files
+}
+
+// metricsCollectorFor returns the collector of a job, creating it on first use.
+func (srv *ILabServer) metricsCollectorFor(jobID string) *metricsCollector {
+	srv.metricsMu.Lock()
+	defer srv.metricsMu.Unlock()
+	c, ok := srv.metricsCollectors[jobID]
+	if !ok {
+		c = &metricsCollector{offsets: make(map[string]int64)}
+		srv.metricsCollectors[jobID] = c
+	}
+	return c
+}
+
+// collectTrainMetrics parses new output of a training job and stores the samples. The
+// shared metrics files of jobs without their own output directory hold every run, so only
+// records stamped while the job ran are taken from them; samples found in both the log and
+// a metrics file are stored once.
+func (srv *ILabServer) collectTrainMetrics(job *Job) error {
+	c := srv.metricsCollectorFor(job.JobID)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var samples []metricSample
+	lines, err := c.readNewLines(job.LogFile)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		samples = append(samples, c.parseMetricsLine(line)...)
+	}
+	for _, path := range trainingMetricsFiles(job) {
+		lines, err := c.readNewLines(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range lines {
+			var record struct {
+				Timestamp string `json:"timestamp"`
+			}
+			if json.Unmarshal([]byte(line), &record) != nil {
+				continue
+			}
+			// Timestamps are Python isoformat() in local time
+			t, err := time.ParseInLocation("2006-01-02T15:04:05.999999", record.Timestamp, time.Local)
+			if err != nil || !withinJobRun(job, t) {
+				continue
+			}
+			samples = append(samples, c.parseMetricsLine(line)...)
+		}
+	}
+	if len(samples) == 0 {
+		return nil
+	}
+	return srv.storeMetricSamples(job.JobID, samples)
+}
+
+// withinJobRun reports whether t falls between the start and, once it has ended, the end of
+// job. Job times are stored to the second, so the end is extended by a second.
+func withinJobRun(job *Job, t time.Time) bool {
+	if t.Before(job.StartTime) {
+		return false
+	}
+	return job.EndTime == nil || !t.After(job.EndTime.Add(time.Second))
+}
+
+// storeMetricSamples inserts samples, ignoring any already stored for the same series and step.
+func (srv *ILabServer) storeMetricSamples(jobID string, samples []metricSample) error {
+	tx, err := srv.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`
+        INSERT OR IGNORE INTO training_metrics (job_id, name, step, epoch, value, recorded_at)
+        VALUES (?, ?, ?, ?, ?, ?)
+    `)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	now := time.Now().Format(time.RFC3339)
+	for _, s := range samples {
+		if _, err := stmt.Exec(jobID, s.name, s.step, s.epoch, s.value, now); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// runMetricsCollector collects a training job's metrics every metricsPollInterval until done
+// is closed, then once more for the final output.
+func (srv *ILabServer) runMetricsCollector(job *Job, done <-chan struct{}) {
+	ticker := time.NewTicker(metricsPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := srv.collectTrainMetrics(job); err != nil {
+				srv.log.Debugf("Error collecting metrics for job %s: %v", job.JobID, err)
+			}
+		case <-done:
+			if err := srv.collectTrainMetrics(job); err != nil {
+				srv.log.Warnf("Error collecting final metrics for job %s: %v", job.JobID, err)
+			}
+			srv.metricsMu.Lock()
+			delete(srv.metricsCollectors, job.JobID)
+			srv.metricsMu.Unlock()
+			return
+		}
+	}
+}
+
+// loadMetricSeries returns the stored series of a job, optionally restricted to names.
+func (srv *ILabServer) loadMetricSeries(jobID string, names []string, sinceStep int) (map[string][]MetricPoint, error) {
+	query := "SELECT name, step, epoch, value, recorded_at FROM training_metrics WHERE job_id = ? AND step > ?"
+	args := []interface{}{jobID, sinceStep}
+	if len(names) > 0 {
+		query += " AND name IN (?" + strings.Repeat(", ?", len(names)-1) + ")"
+		for _, name := range names {
+			args = append(args, name)
+		}
+	}
+	query += " ORDER BY name, step"
+
+	rows, err := srv.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := make(map[string][]MetricPoint)
+	for _, name := range names {
+		series[name] = []MetricPoint{}
+	}
+	for rows.Next() {
+		var name, recordedAt string
+		var p MetricPoint
+		if err := rows.Scan(&name, &p.Step, &p.Epoch, &p.Value, &recordedAt); err != nil {
+			return nil, err
+		}
+		p.Time, _ = time.Parse(time.RFC3339, recordedAt)
+		series[name] = append(series[name], p)
+	}
+	return series, rows.Err()
+}
+
+// getJobMetricsHandler handles GET /jobs/{job_id}/metrics?series=loss,lr&since_step=N.
+// Running jobs are collected before responding, so the series are current; jobs from before
+// metrics collection existed are parsed from their logs on first request.
+func (srv *ILabServer) getJobMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["job_id"]
+	srv.log.Infof("GET /jobs/%s/metrics called", jobID)
+
+	var names []string
+	if s := r.URL.Query().Get("series"); s != "" {
+		for _, name := range strings.Split(s, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	}
+	sinceStep := -1
+	if s := r.URL.Query().Get("since_step"); s != "" {
+		n, err := parseBoundedInt(s, 0, 0)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid 'since_step': %v", err), http.StatusBadRequest)
+			return
+		}
+		sinceStep = n
+	}
+
+	job, err := srv.getJob(jobID)
+	if err != nil {
+		srv.log.Errorf("Error retrieving job from DB: %v", err)
+		http.Error(w, "Failed to retrieve job", http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if !isTrainJob(job) {
+		http.Error(w, fmt.Sprintf("Job '%s' is not a training job", jobID), http.StatusBadRequest)
+		return
+	}
+
+	var stored int
+	_ = srv.db.QueryRow("SELECT COUNT(*) FROM training_metrics WHERE job_id = ?", jobID).Scan(&stored)
+	if job.Status == "running" || stored == 0 {
+		if err := srv.collectTrainMetrics(job); err != nil && !os.IsNotExist(err) {
+			srv.log.Warnf("Error collecting metrics for job %s: %v", jobID, err)
+		}
+		if job.Status != "running" {
+			srv.metricsMu.Lock()
+			delete(srv.metricsCollectors, jobID)
+			srv.metricsMu.Unlock()
+		}
+	}
+
+	series, err := srv.loadMetricSeries(jobID, names, sinceStep)
+	if err != nil {
+		srv.log.Errorf("Error loading metrics for job %s: %v", jobID, err)
+		http.Error(w, "Failed to load metrics", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(JobMetricsResponse{JobID: jobID, Status: job.Status, Series: series})
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// modelCacheTTL is how long the model cache is served without a background revalidation.
+const modelCacheTTL = 20 * time.Minute
+
+// ModelCache encapsulates the cached models and related metadata.
+// Mutex only guards the fields; listing models runs without holding it.
+type ModelCache struct {
+	Models []Model
+	Time   time.Time
+	ETag   string
+	Mutex  sync.Mutex
+
+	// refreshing is closed when the in-flight refresh completes; nil when no refresh is running.
+	refreshing chan struct{}
+	// rerun is set when a change is signalled while a refresh is in flight, since that
+	// refresh may have started before the change it is meant to pick up.
+	rerun bool
+}
+
+// modelCacheSnapshot returns a copy of the cached models with their timestamp and ETag.
+func (srv *ILabServer) modelCacheSnapshot() ([]Model, time.Time, string) {
+	srv.modelCache.Mutex.Lock()
+	defer srv.modelCache.Mutex.Unlock()
+	models := make([]Model, len(srv.modelCache.Models))
+	copy(models, srv.modelCache.Models)
+	return models, srv.modelCache.Time, srv.modelCache.ETag
+}
+
+// triggerModelCacheRefresh starts a background refresh unless one is already running
+// (single-flight) and returns a channel that is closed once the cache is up to date.
+// changed is set by callers that know the models changed, e.g. a download or the directory
+// watcher; they get another pass when a refresh is in flight, where a revalidation of a
+// stale cache just joins it.
+func (srv *ILabServer) triggerModelCacheRefresh(changed bool) <-chan struct{} {
+	c := &srv.modelCache
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	if c.refreshing != nil {
+		if changed {
+			c.rerun = true
+		}
+		return c.refreshing
+	}
+	done := make(chan struct{})
+	c.refreshing = done
+	go srv.runModelCacheRefresh(done)
+	return done
+}
+
+// runModelCacheRefresh lists models until no further refresh has been requested, then closes done.
+func (srv *ILabServer) runModelCacheRefresh(done chan struct{}) {
+	c := &srv.modelCache
+	for {
+		srv.log.Info("Refreshing model cache...")
+		start := time.Now()
+		models, err := srv.listModels()
+
+		c.Mutex.Lock()
+		if err != nil {
+			srv.log.Errorf("Error refreshing model cache: %v", err)
+		} else {
+			c.Models = models
+			c.Time = time.Now()
+			c.ETag = modelListETag(models)
+			srv.log.Infof("Model cache refreshed in %v with %d models.", time.Since(start).Round(time.Millisecond), len(models))
+		}
+		if !c.rerun {
+			c.refreshing = nil
+			c.Mutex.Unlock()
+			close(done)
+			return
+		}
+		c.rerun = false
+		c.Mutex.Unlock()
+	}
+}
+
+// listModels enumerates the models directory, falling back to parsing "ilab model list".
+func (srv *ILabServer) listModels() ([]Model, error) {
+	models, err := scanModels()
+	if err == nil {
+		return models, nil
+	}
+	srv.log.Warnf("Could not scan models directory (%v); falling back to 'ilab model list'", err)
+
+	output, err := srv.runIlabCommand("model", "list")
+	if err != nil {
+		return nil, err
+	}
+	models, err = srv.parseModelList(output)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing model list: %v", err)
+	}
+	return models, nil
+}
+
+// modelListETag derives a strong ETag from the model list contents.
+func modelListETag(models []Model) string {
+	data, _ := json.Marshal(models)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// initializeModelCache starts the first refresh in the background, revalidates the cache
+// every modelCacheTTL and, unless disabled, watches the models directory for changes.
+func (srv *ILabServer) initializeModelCache() {
+	srv.triggerModelCacheRefresh(false)
+	go func() {
+		ticker := time.NewTicker(modelCacheTTL)
+		defer ticker.Stop()
+		for range ticker.C {
+			srv.triggerModelCacheRefresh(false)
+		}
+	}()
+	if srv.modelWatchInterval > 0 {
+		go srv.watchModelsDir(srv.modelWatchInterval)
+	}
+}
+
+// forceRefreshModelCache refreshes the model cache regardless of its age and waits for it,
+// e.g. after a download.
+func (srv *ILabServer) forceRefreshModelCache() {
+	<-srv.triggerModelCacheRefresh(true)
+}
+
+// modelsDirFingerprint hashes the names, sizes and modification times of the entries in
+// the models directory, down to the files of each model.
+func modelsDirFingerprint(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			// Skip hidden entries such as promotion staging directories
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+		if d.IsDir() && strings.Count(strings.TrimPrefix(path, dir), string(filepath.Separator)) >= 3 {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// watchModelsDir polls the models directory and refreshes the cache once a change has
+// settled, so a download in progress triggers a single refresh when it completes.
+func (srv *ILabServer) watchModelsDir(interval time.Duration) {
+	dir, err := getModelsDir()
+	if err != nil {
+		srv.log.Errorf("Model directory watcher disabled: %v", err)
+		return
+	}
+	srv.log.Infof("Watching %s for model changes every %v", dir, interval)
+
+	last, _ := modelsDirFingerprint(dir)
+	changed := false
+	for {
+		time.Sleep(interval)
+		current, err := modelsDirFingerprint(dir)
+		if err != nil && !os.IsNotExist(err) {
+			srv.log.Debugf("Error scanning models directory: %v", err)
+			continue
+		}
+		if current != last {
+			last = current
+			changed = true
+			continue
+		}
+		if changed {
+			changed = false
+			srv.log.Info("Models directory changed; refreshing model cache")
+			srv.triggerModelCacheRefresh(true)
+		}
+	}
+}
+
+// getModelsHandler is the HTTP handler for GET /models. It serves the cached models
+// immediately and revalidates them in the background when they are stale; it only waits
+// for a refresh when nothing has been cached yet.
+func (srv *ILabServer) getModelsHandler(w http.ResponseWriter, r *http.Request) {
+	srv.log.Info("GET /models called")
+
+	models, cachedAt, etag := srv.modelCacheSnapshot()
+	if cachedAt.IsZero() {
+		srv.log.Info("Model cache is empty. Waiting for a refresh...")
+		select {
+		case <-srv.triggerModelCacheRefresh(false):
+		case <-r.Context().Done():
+			return
+		}
+		models, cachedAt, etag = srv.modelCacheSnapshot()
+		if cachedAt.IsZero() {
+			http.Error(w, "Failed to retrieve models", http.StatusInternalServerError)
+			srv.log.Info("GET /models failed to retrieve models.")
+			return
+		}
+	} else if time.Since(cachedAt) >= modelCacheTTL {
+		srv.log.Info("Model cache is stale; serving it while refreshing in the background.")
+		srv.triggerModelCacheRefresh(false)
+		w.Header().Set("X-Cache-Stale", "true")
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", cachedAt.UTC().Format(http.TimeFormat))
+	w.Header().Set("X-Cached-At", cachedAt.UTC().Format(time.RFC3339))
+	if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(models); err != nil {
+		srv.log.Errorf("Error encoding cached models: %v", err)
+		http.Error(w, "Failed to encode models", http.StatusInternalServerError)
+		return
+	}
+	srv.log.Infof("GET /models returned %d models cached at %v.", len(models), cachedAt)
+}
+
+// refreshModelsHandler handles POST /models/refresh. With ?wait=true it responds once the
+// refresh has completed, with the refreshed cache metadata.
+func (srv *ILabServer) refreshModelsHandler(w http.ResponseWriter, r *http.Request) {
+	wait := r.URL.Query().Get("wait") == "true"
+	srv.log.Infof("POST /models/refresh called, wait=%v", wait)
+
+	done := srv.triggerModelCacheRefresh(true)
+	if !wait {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "refreshing"})
+		return
+	}
+
+	select {
+	case <-done:
+	case <-r.Context().Done():
+		return
+	}
+	models, cachedAt, etag := srv.modelCacheSnapshot()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "refreshed",
+		"models":    len(models),
+		"cached_at": cachedAt,
+		"etag":      etag,
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestModelCacheRefreshRerun(t *testing.T) {
+	srv := newTestServer(t)
+	c := &srv.modelCache
+	// A stale cache with a refresh in flight
+	inFlight := make(chan struct{})
+	c.Models, c.Time, c.ETag = []Model{}, time.Now().Add(-2*modelCacheTTL), `"stale"`
+	c.refreshing = inFlight
+
+	w := httptest.NewRecorder()
+	srv.getModelsHandler(w, httptest.NewRequest(http.MethodGet, "/models", nil))
+	if w.Code != http.StatusOK || w.Header().Get("X-Cache-Stale") != "true" {
+		t.Errorf("GET /models = %d, stale %q", w.Code, w.Header().Get("X-Cache-Stale"))
+	}
+	if c.rerun {
+		t.Error("a stale GET /models requested another refresh instead of joining the one in flight")
+	}
+	if done := srv.triggerModelCacheRefresh(false); done != inFlight || c.rerun {
+		t.Error("a revalidation did not join the refresh in flight")
+	}
+
+	w = httptest.NewRecorder()
+	srv.refreshModelsHandler(w, httptest.NewRequest(http.MethodPost, "/models/refresh", nil))
+	if w.Code != http.StatusAccepted || !c.rerun {
+		t.Errorf("POST /models/refresh = %d, rerun %t, want another refresh requested", w.Code, c.rerun)
+	}
+
+	// Without a refresh in flight, one runs and fills the cache
+	modelsDir, err := getModelsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(modelsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	c.refreshing, c.rerun = nil, false
+	select {
+	case <-srv.triggerModelCacheRefresh(false):
+	case <-time.After(10 * time.Second):
+		t.Fatal("the refresh did not complete")
+	}
+	models, cachedAt, _ := srv.modelCacheSnapshot()
+	if time.Since(cachedAt) > time.Minute || len(models) != 0 {
+		t.Errorf("cache = %d models at %v, want an empty, fresh cache", len(models), cachedAt)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ModelDownloadRequest is used by the /models/download endpoint.
+type ModelDownloadRequest struct {
+	Repository string `json:"repository"`
+	Release    string `json:"release,omitempty"`
+	Filename   string `json:"filename,omitempty"`
+	HFToken    string `json:"hf_token,omitempty"`
+}
+
+// DownloadProgress is the download state parsed from a download job's log.
+type DownloadProgress struct {
+	File       string  `json:"file,omitempty"`
+	Percent    float64 `json:"percent"`
+	Downloaded string  `json:"downloaded,omitempty"`
+	Total      string  `json:"total,omitempty"`
+}
+
+// validDownloadArg matches repository, release and filename values we pass to ilab.
+var validDownloadArg = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:/@+-]*$`)
+
+// downloadModelHandler is the HTTP handler for the /models/download endpoint.
+func (srv *ILabServer) downloadModelHandler(w http.ResponseWriter, r *http.Request) {
+	srv.log.Info("POST /models/download called")
+
+	var req ModelDownloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		srv.log.Errorf("Error parsing download request body: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Repository == "" {
+		srv.log.Info("Missing required parameter: repository")
+		http.Error(w, "Missing required parameter: repository", http.StatusBadRequest)
+		return
+	}
+	for field, value := range map[string]string{"repository": req.Repository, "release": req.Release, "filename": req.Filename} {
+		if value != "" && (!validDownloadArg.MatchString(value) || strings.Contains(value, "..")) {
+			srv.log.Infof("Invalid download parameter %s: %q", field, value)
+			http.Error(w, fmt.Sprintf("Invalid value for '%s'", field), http.StatusBadRequest)
+			return
+		}
+	}
+
+	jobID, err := srv.startDownloadJob(req)
+	if err != nil {
+		srv.log.Errorf("Error starting download job: %v", err)
+		http.Error(w, fmt.Sprintf("Failed to start download job: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"job_id": jobID})
+	srv.log.Infof("POST /models/download started successfully, job_id: %s", jobID)
+}
+
+// startDownloadJob launches "ilab model download" and refreshes the model cache when it succeeds.
+func (srv *ILabServer) startDownloadJob(req ModelDownloadRequest) (string, error) {
+	ilabPath := srv.getIlabCommand()
+
+	cmdArgs := []string{
+		"model", "download",
+		fmt.Sprintf("--repository=%s", req.Repository),
+	}
+	if req.Release != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--release=%s", req.Release))
+	}
+	if req.Filename != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--filename=%s", req.Filename))
+	}
+
+	// Unique job ID & log file
+	jobID := fmt.Sprintf("d-%d", time.Now().UnixNano())
+	logFilePath := filepath.Join("logs", fmt.Sprintf("%s.log", jobID))
+
+	finalCmdString := fmt.Sprintf("[ILAB DOWNLOAD COMMAND] %s %v", ilabPath, cmdArgs)
+	srv.log.Info(finalCmdString)
+
+	cmd := exec.Command(ilabPath, cmdArgs...)
+	if !srv.rhelai {
+		cmd.Dir = srv.baseDir
+	}
+	// The token goes through the environment so it never lands in the jobs table or the log
+	cmd.Env = os.Environ()
+	if req.HFToken != "" {
+		cmd.Env = append(cmd.Env, "HF_TOKEN="+req.HFToken)
+	}
+	if srv.hfEndpoint != "" {
+		cmd.Env = append(cmd.Env, "HF_ENDPOINT="+srv.hfEndpoint)
+	}
+
+	logFile, err := os.Create(logFilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create log file for download job: %v", err)
+	}
+	fmt.Fprintln(logFile, finalCmdString)
+
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	srv.log.Infof("Starting ilab download process with job ID '%s'", jobID)
+	if err := cmd.Start(); err != nil {
+		logFile.Close()
+		return "", err
+	}
+
+	newJob := &Job{
+		JobID:     jobID,
+		Cmd:       ilabPath,
+		Args:      cmdArgs,
+		Status:    "running",
+		PID:       cmd.Process.Pid,
+		LogFile:   logFilePath,
+		StartTime: time.Now(),
+	}
+	if err := srv.createJob(newJob); err != nil {
+		srv.log.Errorf("Error creating download job in DB: %v", err)
+	}
+
+	go func() {
+		defer logFile.Close()
+		err := cmd.Wait()
+
+		newJob.Lock.Lock()
+		defer newJob.Lock.Unlock()
+
+		if err != nil {
+			newJob.Status = "failed"
+			srv.log.Infof("Download job %s failed: %v", newJob.JobID, err)
+		} else {
+			newJob.Status = "finished"
+			srv.log.Infof("Download job %s finished successfully", newJob.JobID)
+			go srv.forceRefreshModelCache()
+		}
+		now := time.Now()
+		newJob.EndTime = &now
+		_ = srv.updateJob(newJob)
+	}()
+
+	return jobID, nil
+}
+
+// downloadProgressPattern matches huggingface_hub/tqdm progress bars, e.g.
+// "model.safetensors:  45%|████▌     | 2.25G/5.00G [00:30<00:36, 75.0MB/s]".
+var downloadProgressPattern = regexp.MustCompile(`(?:([^\s:][^:]*):\s+)?(\d{1,3})%\|[^|]*\|\s*([\d.]+[kMGTP]?B?)/([\d.]+[kMGTP]?B?)`)
+
+// parseDownloadProgress returns the most recent progress bar state found in a download log.
+func parseDownloadProgress(logFile string) (*DownloadProgress, error) {
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// tqdm redraws bars with carriage returns, so treat them as line breaks
+	lines := strings.FieldsFunc(string(content), func(r rune) bool { return r == '\n' || r == '\r' })
+	for i := len(lines) - 1; i >= 0; i-- {
+		m := downloadProgressPattern.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		percent, _ := strconv.ParseFloat(m[2], 64)
+		return &DownloadProgress{
+			File:       strings.TrimSpace(m[1]),
+			Percent:    percent,
+			Downloaded: m[3],
+			Total:      m[4],
+		}, nil
+	}
+	return &DownloadProgress{}, nil
+}
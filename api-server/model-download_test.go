@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDownloadProgress(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		log  string
+		want DownloadProgress
+	}{
+		{
+			name: "latest bar",
+			log: "[ILAB DOWNLOAD COMMAND] ilab [model download]\n" +
+				"Downloading model from Hugging Face:\n" +
+				"model.safetensors:   0%|          | 0.00/5.00G [00:00<?, ?B/s]\r" +
+				"model.safetensors:  45%|████▌     | 2.25G/5.00G [00:30<00:36, 75.0MB/s]\r",
+			want: DownloadProgress{File: "model.safetensors", Percent: 45, Downloaded: "2.25G", Total: "5.00G"},
+		},
+		{
+			name: "bar without file name",
+			log:  " 80%|████████  | 400k/500k [00:04<00:01, 100kB/s]\n",
+			want: DownloadProgress{Percent: 80, Downloaded: "400k", Total: "500k"},
+		},
+		{
+			name: "no bar yet",
+			log:  "Downloading model from Hugging Face:\n",
+			want: DownloadProgress{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			logFile := filepath.Join(t.TempDir(), "d-1.log")
+			if err := os.WriteFile(logFile, []byte(tc.log), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseDownloadProgress(logFile)
+			if err != nil {
+				t.Fatalf("parseDownloadProgress: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tc.want) {
+				t.Errorf("got %+v, want %+v", *got, tc.want)
+			}
+		})
+	}
+
+	if _, err := parseDownloadProgress(filepath.Join(t.TempDir(), "missing.log")); err == nil {
+		t.Error("parseDownloadProgress succeeded on a missing log")
+	}
+}
+
+// stubIlabScript stands in for "ilab model download": it prints a progress bar, then
+// writes the file to the models directory, or fails for the repository "missing/model".
+const stubIlabScript = `#!/bin/sh
+for arg in "$@"; do
+	case "$arg" in
+	--repository=missing/model) echo "Repository not found" >&2; exit 1 ;;
+	--filename=*) file="${arg#--filename=}" ;;
+	esac
+done
+printf 'token=%s\n' "${HF_TOKEN:+set}"
+printf '%s:  50%%|#####     | 1.00k/2.00k [00:01<00:01, 1.0kB/s]\r' "$file"
+mkdir -p "$HOME/.cache/instructlab/models"
+# A GGUF v3 header without tensors or metadata
+printf 'GGUF\003\000\000\000\000\000\000\000\000\000\000\000\000\000\000\000\000\000\000\000' > "$HOME/.cache/instructlab/models/$file"
+`
+
+// newDownloadTestServer returns a test server whose ilab is stubIlabScript.
+func newDownloadTestServer(t *testing.T) *ILabServer {
+	t.Helper()
+	srv := newTestServer(t)
+	srv.ilabCmd = filepath.Join(t.TempDir(), "ilab")
+	if err := os.WriteFile(srv.ilabCmd, []byte(stubIlabScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func TestDownloadJob(t *testing.T) {
+	srv := newDownloadTestServer(t)
+
+	jobID, err := srv.startDownloadJob(ModelDownloadRequest{
+		Repository: "instructlab/granite-7b-lab-GGUF",
+		Filename:   "granite-7b-lab-Q4_K_M.gguf",
+		HFToken:    "hf_secret",
+	})
+	if err != nil {
+		t.Fatalf("startDownloadJob: %v", err)
+	}
+	if !strings.HasPrefix(jobID, "d-") {
+		t.Errorf("job ID = %q, want a d- prefix", jobID)
+	}
+	job := waitForJob(t, srv, jobID)
+	if job.Status != "finished" || job.EndTime == nil {
+		t.Errorf("job = %+v, want finished", job)
+	}
+	if strings.Contains(strings.Join(job.Args, " "), "hf_secret") {
+		t.Errorf("args %v hold the token", job.Args)
+	}
+
+	log, err := os.ReadFile(job.LogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(log), "token=set") || strings.Contains(string(log), "hf_secret") {
+		t.Errorf("log = %q, want the token passed through the environment only", log)
+	}
+	progress, err := parseDownloadProgress(job.LogFile)
+	if err != nil {
+		t.Fatalf("parseDownloadProgress: %v", err)
+	}
+	if progress.File != "granite-7b-lab-Q4_K_M.gguf" || progress.Percent != 50 {
+		t.Errorf("progress = %+v", progress)
+	}
+
+	// The model cache is refreshed once the download has finished
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		srv.modelCache.Mutex.Lock()
+		models := srv.modelCache.Models
+		srv.modelCache.Mutex.Unlock()
+		if len(models) == 1 && models[0].Name == "granite-7b-lab-Q4_K_M.gguf" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("model cache = %+v, want the downloaded model", models)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestDownloadJobFailure(t *testing.T) {
+	srv := newDownloadTestServer(t)
+
+	jobID, err := srv.startDownloadJob(ModelDownloadRequest{Repository: "missing/model"})
+	if err != nil {
+		t.Fatalf("startDownloadJob: %v", err)
+	}
+	job := waitForJob(t, srv, jobID)
+	if job.Status != "failed" {
+		t.Errorf("status = %q, want failed", job.Status)
+	}
+	log, _ := os.ReadFile(job.LogFile)
+	if !strings.Contains(string(log), "Repository not found") {
+		t.Errorf("log = %q, want the ilab error", log)
+	}
+}
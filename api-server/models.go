@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	errInvalidModelName = errors.New("invalid model name")
+	errModelNotFound    = errors.New("model not found")
+)
+
+// ServeBaseModelRequest is used by the /model/serve-base and /models/{name}/serve endpoints.
+type ServeBaseModelRequest struct {
+	ModelName  string `json:"model_name,omitempty"` // Optional for /model/serve-base: defaults to the server's base model
+	Deployment string `json:"deployment,omitempty"` // Optional: deployment / served model name, defaults to "pre-train"
+	Port       int    `json:"port,omitempty"`       // Optional: local serves only
+}
+
+// defaultBaseModel returns the --base-model setting, or the stock model for the serving mode.
+func (srv *ILabServer) defaultBaseModel() string {
+	if srv.baseModel != "" {
+		return srv.baseModel
+	}
+	if srv.useVllm || srv.rhelai {
+		return "granite-8b-starter-v1"
+	}
+	return "granite-7b-lab-Q4_K_M.gguf"
+}
+
+// normalizeModelName strips the optional "model/" or "models/" prefix clients may send.
+func (srv *ILabServer) normalizeModelName(name string) string {
+	return strings.TrimPrefix(srv.sanitizeModelName(strings.TrimSpace(name)), "models/")
+}
+
+// resolveModel validates a model name against the model cache and returns its normalized
+// name and path under ~/.cache/instructlab/models. Models missing from the cache are
+// accepted if they exist on disk, since the cache may be stale.
+func (srv *ILabServer) resolveModel(name string) (string, string, error) {
+	name = srv.normalizeModelName(name)
+	if name == "" || filepath.IsAbs(name) || strings.HasPrefix(name, "-") {
+		return "", "", errInvalidModelName
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." || part == "." || part == "" {
+			return "", "", errInvalidModelName
+		}
+	}
+
+	modelPath, err := getFullModelPath(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	srv.modelCache.Mutex.Lock()
+	cached := false
+	for _, m := range srv.modelCache.Models {
+		if srv.normalizeModelName(m.Name) == name {
+			cached = true
+			break
+		}
+	}
+	srv.modelCache.Mutex.Unlock()
+
+	if !cached {
+		if _, err := os.Stat(modelPath); err != nil {
+			return "", "", errModelNotFound
+		}
+	}
+	return name, modelPath, nil
+}
+
+// writeModelError maps resolveModel errors to HTTP responses.
+func (srv *ILabServer) writeModelError(w http.ResponseWriter, name string, err error) {
+	switch {
+	case errors.Is(err, errInvalidModelName):
+		http.Error(w, fmt.Sprintf("Invalid model name '%s'", name), http.StatusBadRequest)
+	case errors.Is(err, errModelNotFound):
+		http.Error(w, fmt.Sprintf("Model '%s' not found in the model cache", name), http.StatusNotFound)
+	default:
+		srv.log.Errorf("Error resolving model '%s': %v", name, err)
+		http.Error(w, "Failed to resolve model", http.StatusInternalServerError)
+	}
+}
+
+// serveBaseModel serves modelName under the given deployment, using vLLM or a local process.
+func (srv *ILabServer) serveBaseModel(modelName string, req ServeBaseModelRequest, w http.ResponseWriter) {
+	name, modelPath, err := srv.resolveModel(modelName)
+	if err != nil {
+		srv.writeModelError(w, modelName, err)
+		return
+	}
+
+	deployment := strings.TrimSpace(req.Deployment)
+	if deployment == "" {
+		deployment = "pre-train"
+	}
+
+	if srv.useVllm {
+		// vLLM deployments have fixed GPU and port assignments
+		var port string
+		var gpuIndex int
+		switch deployment {
+		case "pre-train":
+			port, gpuIndex = "8000", 0
+		case "post-train":
+			port, gpuIndex = "8001", 1
+		default:
+			http.Error(w, "Invalid deployment. Must be 'pre-train' or 'post-train' when serving with vLLM", http.StatusBadRequest)
+			return
+		}
+		srv.log.Infof("Serving model '%s' using vllm at %s as '%s' on port %s", name, modelPath, deployment, port)
+		srv.runVllmContainerHandler(modelPath, port, deployment, gpuIndex, srv.homeDir, srv.homeDir, w)
+		return
+	}
+
+	port := req.Port
+	if port == 0 {
+		port = defaultDeploymentPort(deployment)
+	}
+	srv.log.Infof("Serving model '%s' at %s as '%s'", name, modelPath, deployment)
+	srv.serveModelHandler(deployment, modelPath, port, w)
+}
+
+// defaultDeploymentPort keeps the historical ports for the "pre-train" and "post-train"
+// deployments; other deployments get an allocated port.
+func defaultDeploymentPort(deployment string) int {
+	switch deployment {
+	case "pre-train":
+		return 8000
+	case "post-train":
+		return 8001
+	}
+	return 0
+}
+
+// decodeOptionalBody decodes a JSON request body into v, treating an empty body as no parameters.
+func decodeOptionalBody(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// serveNamedModelHandler handles POST /models/{name}/serve.
+func (srv *ILabServer) serveNamedModelHandler(w http.ResponseWriter, r *http.Request) {
+	modelName := mux.Vars(r)["name"]
+	srv.log.Infof("POST /models/%s/serve called", modelName)
+
+	var req ServeBaseModelRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		srv.log.Errorf("Error decoding request body: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	srv.serveBaseModel(modelName, req, w)
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Phases of a phased training job.
+const (
+	phaseKnowledge = "knowledge"
+	phaseSkills    = "skills"
+)
+
+const (
+	// phasedDatasetsDir holds the phase 2 data mixed by phased training jobs, relative to
+	// the datasets directory.
+	phasedDatasetsDir = "phased"
+	// jobPollInterval is how often an orchestrating job checks on the jobs it started.
+	jobPollInterval = 5 * time.Second
+)
+
+// TrainingPhaseRequest configures one phase of POST /model/train/phased.
+type TrainingPhaseRequest struct {
+	Dataset         string          `json:"dataset,omitempty"`
+	Epochs          *int            `json:"epochs,omitempty"`
+	Preset          string          `json:"preset,omitempty"`
+	Hyperparameters json.RawMessage `json:"hyperparameters,omitempty"` // a TrainingSpec, decoded strictly
+}
+
+// ReplayRequest mixes a sample of earlier training data into the skills phase, so the
+// model doesn't forget what the knowledge phase taught it.
+type ReplayRequest struct {
+	Dataset string  `json:"dataset,omitempty"` // defaults to the knowledge phase's dataset
+	Ratio   float64 `json:"ratio"`             // fraction of the replay dataset's records to mix in
+	Seed    int64   `json:"seed"`
+}
+
+// PhasedTrainRequest is the body of POST /model/train/phased.
+type PhasedTrainRequest struct {
+	ModelName      string               `json:"modelName"`
+	BranchName     string               `json:"branchName"`
+	Knowledge      TrainingPhaseRequest `json:"knowledge"`
+	Skills         TrainingPhaseRequest `json:"skills"`
+	CheckpointRule string               `json:"checkpoint_rule,omitempty"`
+	Replay         *ReplayRequest       `json:"replay,omitempty"`
+}
+
+// phasedTrainPlan is a validated PhasedTrainRequest with its datasets and specs resolved.
+type phasedTrainPlan struct {
+	modelName      string
+	branchName     string
+	knowledgeData  string
+	knowledgeSpec  *TrainingSpec
+	skillsData     string
+	skillsSpec     *TrainingSpec
+	checkpointRule string
+	replayData     string
+	replay         *ReplayRequest
+}
+
+// TrainingPhaseStatus is one phase of a phased training job, as reported by its status.
+type TrainingPhaseStatus struct {
+	Phase            string `json:"phase"`
+	JobID            string `json:"job_id"`
+	Status           string `json:"status"`
+	ParentJobID      string `json:"parent_job_id,omitempty"`
+	Dataset          string `json:"dataset,omitempty"`
+	ResumeCheckpoint string `json:"resume_checkpoint,omitempty"`
+}
+
+// resolvePhaseDataset resolves the dataset of a phase. A generate job ID selects that
+// job's "<prefix>*.jsonl" output, and no reference at all the newest such file.
+func (srv *ILabServer) resolvePhaseDataset(ref, prefix string) (string, error) {
+	switch {
+	case ref == "":
+		datasetsDir, err := getDatasetsDir()
+		if err != nil {
+			return "", err
+		}
+		path, err := latestDatasetWithPrefix(datasetsDir, prefix)
+		if err != nil {
+			return "", errDatasetNotFound
+		}
+		return path, nil
+	case strings.HasPrefix(ref, "g-"):
+		return srv.generatedDatasetWithPrefix(ref, prefix)
+	default:
+		return srv.resolveTrainingDataset(ref)
+	}
+}
+
+// mixReplayData writes the skills records followed by a seeded sample of the replay records
+// to path. Lines that are not valid JSON are dropped. It returns the record counts.
+func mixReplayData(skillsPath, replayPath string, replay *ReplayRequest, path string) (skills, replayed int, err error) {
+	readLines := func(p string) ([][]byte, error) {
+		var lines [][]byte
+		err := scanDatasetLines(p, func(_ int, data []byte) bool {
+			if json.Valid(data) {
+				lines = append(lines, append([]byte(nil), data...))
+			}
+			return true
+		})
+		return lines, err
+	}
+	lines, err := readLines(skillsPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read skills dataset: %v", err)
+	}
+	replayLines, err := readLines(replayPath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read replay dataset: %v", err)
+	}
+	skills = len(lines)
+
+	order := rand.New(rand.NewSource(replay.Seed)).Perm(len(replayLines))
+	picked := order[:int(float64(len(replayLines))*replay.Ratio+0.5)]
+	sort.Ints(picked)
+	for _, i := range picked {
+		lines = append(lines, replayLines[i])
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return 0, 0, err
+	}
+	if err := writeJSONLines(path, lines); err != nil {
+		return 0, 0, err
+	}
+	return skills, len(picked), nil
+}
+
+// waitForJob polls a job until it is no longer running.
+func (srv *ILabServer) waitForJob(jobID string) (*Job, error) {
+	for {
+		job, err := srv.getJob(jobID)
+		if err != nil {
+			return nil, err
+		}
+		if job == nil {
+			return nil, fmt.Errorf("job %s not found", jobID)
+		}
+		if job.Status != "running" {
+			return job, nil
+		}
+		time.Sleep(jobPollInterval)
+	}
+}
+
+// waitForPhase waits for a phase job and reports an error unless it finished.
+func (srv *ILabServer) waitForPhase(jobID string) error {
+	job, err := srv.waitForJob(jobID)
+	if err != nil {
+		return err
+	}
+	if job.Status != "finished" {
+		return fmt.Errorf("job %s %s", jobID, job.Status)
+	}
+	return nil
+}
+
+// trainingPhases returns the phase jobs descending from a phased training job, in the order
+// they started.
+func (srv *ILabServer) trainingPhases(jobID string) ([]TrainingPhaseStatus, error) {
+	jobs, err := srv.listAllJobs()
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[string][]*Job)
+	for _, j := range jobs {
+		if j.ParentJobID != "" {
+			children[j.ParentJobID] = append(children[j.ParentJobID], j)
+		}
+	}
+
+	var descendants []*Job
+	queue := []string{jobID}
+	for len(queue) > 0 {
+		for _, child := range children[queue[0]] {
+			if child.Phase != "" {
+				descendants = append(descendants, child)
+			}
+			queue = append(queue, child.JobID)
+		}
+		queue = queue[1:]
+	}
+	sort.Slice(descendants, func(i, k int) bool {
+		return descendants[i].StartTime.Before(descendants[k].StartTime)
+	})
+
+	phases := []TrainingPhaseStatus{}
+	for _, j := range descendants {
+		phases = append(phases, TrainingPhaseStatus{
+			Phase:            j.Phase,
+			JobID:            j.JobID,
+			Status:           j.Status,
+			ParentJobID:      j.ParentJobID,
+			Dataset:          j.Dataset,
+			ResumeCheckpoint: j.ResumeCheckpoint,
+		})
+	}
+	return phases, nil
+}
+
+// phasedTrainHandler handles POST /model/train/phased. It starts a "pt-" job that trains
+// the knowledge phase, selects one of its checkpoints, and trains the skills phase from it.
+func (srv *ILabServer) phasedTrainHandler(w http.ResponseWriter, r *http.Request) {
+	srv.log.Info("POST /model/train/phased called")
+
+	var req PhasedTrainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		srv.log.Errorf("Error parsing request body: %v", err)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.BranchName == "" {
+		http.Error(w, "Missing required parameter: branchName", http.StatusBadRequest)
+		return
+	}
+	if !validLatestRule(req.CheckpointRule) && req.CheckpointRule != "best" {
+		http.Error(w, fmt.Sprintf("Invalid 'checkpoint_rule' '%s' (expected 'newest', 'samples' or 'best')", req.CheckpointRule), http.StatusBadRequest)
+		return
+	}
+	if !srv.writesHFCheckpoints() {
+		http.Error(w, "Phased training is not supported by the simple pipeline", http.StatusBadRequest)
+		return
+	}
+	if req.Replay != nil && (req.Replay.Ratio <= 0 || req.Replay.Ratio > 1) {
+		http.Error(w, "'replay.ratio' must be greater than 0 and at most 1", http.StatusBadRequest)
+		return
+	}
+
+	plan := &phasedTrainPlan{branchName: req.BranchName, checkpointRule: req.CheckpointRule, replay: req.Replay}
+	for _, phase := range []struct {
+		name   string
+		req    *TrainingPhaseRequest
+		spec   **TrainingSpec
+		prefix string
+		data   *string
+	}{
+		{phaseKnowledge, &req.Knowledge, &plan.knowledgeSpec, trainingDatasetPrefix, &plan.knowledgeData},
+		{phaseSkills, &req.Skills, &plan.skillsSpec, skillsDatasetPrefix, &plan.skillsData},
+	} {
+		if phase.req.Epochs != nil && *phase.req.Epochs <= 0 {
+			http.Error(w, fmt.Sprintf("'%s.epochs' must be a positive integer", phase.name), http.StatusBadRequest)
+			return
+		}
+		spec, err := srv.resolveTrainingSpec(phase.req.Preset, phase.req.Hyperparameters, phase.req.Epochs)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errTrainingPresetNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, fmt.Sprintf("Invalid %s training spec: %v", phase.name, err), status)
+			return
+		}
+		*phase.spec = spec
+
+		data, err := srv.resolvePhaseDataset(phase.req.Dataset, phase.prefix)
+		if err != nil {
+			name := phase.req.Dataset
+			if name == "" {
+				name = phase.prefix + "*.jsonl"
+			}
+			srv.writeDatasetError(w, name, err)
+			return
+		}
+		*phase.data = data
+	}
+	if req.Replay != nil {
+		plan.replayData = plan.knowledgeData
+		if req.Replay.Dataset != "" {
+			data, err := srv.resolveTrainingDataset(req.Replay.Dataset)
+			if err != nil {
+				srv.writeDatasetError(w, req.Replay.Dataset, err)
+				return
+			}
+			plan.replayData = data
+		}
+	}
+
+	if req.ModelName == "" {
+		req.ModelName = srv.defaultBaseModel()
+		srv.log.Infof("No modelName provided. Using the default base model: %s", req.ModelName)
+	}
+	modelName, _, err := srv.resolveModel(req.ModelName)
+	if err != nil {
+		srv.writeModelError(w, req.ModelName, err)
+		return
+	}
+	plan.modelName = modelName
+
+	if err := srv.validateBranch(req.BranchName); err != nil {
+		srv.writeBranchError(w, req.BranchName, err)
+		return
+	}
+
+	jobID := fmt.Sprintf("pt-%d", time.Now().UnixNano())
+	job := &Job{
+		JobID:     jobID,
+		Cmd:       "phased-train",
+		Args:      []string{modelName, req.BranchName, fmt.Sprintf("--checkpoint-rule=%s", req.CheckpointRule)},
+		Status:    "running",
+		LogFile:   fmt.Sprintf("logs/%s.log", jobID),
+		Branch:    req.BranchName,
+		StartTime: time.Now(),
+	}
+	if err := srv.createJob(job); err != nil {
+		srv.log.Errorf("Error creating phased training job: %v", err)
+		http.Error(w, "Failed to create phased training job", http.StatusInternalServerError)
+		return
+	}
+
+	go srv.runPhasedTrainJob(job, plan)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"job_id": jobID})
+	srv.log.Infof("POST /model/train/phased => job_id=%s", jobID)
+}
+
+// runPhasedTrainJob runs the phases of a phased training job in sequence. The knowledge
+// phase is a child of job; the skills phase resumes from, and is a child of, the knowledge
+// phase.
+func (srv *ILabServer) runPhasedTrainJob(job *Job, plan *phasedTrainPlan) {
+	logFile, err := os.Create(job.LogFile)
+	if err != nil {
+		srv.log.Errorf("Error creating phased training log file for job %s: %v", job.JobID, err)
+		job.Status = "failed"
+		_ = srv.updateJob(job)
+		return
+	}
+	defer logFile.Close()
+
+	stdLogger := zap.NewStdLog(srv.logger)
+	stdLogger.SetOutput(logFile)
+	finish := func(status string) {
+		now := time.Now()
+		job.Status, job.EndTime = status, &now
+		_ = srv.updateJob(job)
+	}
+
+	stdLogger.Printf("Starting phased training job: %s, model: %s, branch: %s, checkpoint rule: %q",
+		job.JobID, plan.modelName, plan.branchName, plan.checkpointRule)
+
+	skillsData := plan.skillsData
+	if plan.replay != nil {
+		datasetsDir, err := getDatasetsDir()
+		if err != nil {
+			stdLogger.Printf("Could not find the datasets directory: %v", err)
+			finish("failed")
+			return
+		}
+		skillsData = filepath.Join(datasetsDir, phasedDatasetsDir, job.JobID, "skills_replay.jsonl")
+		skills, replayed, err := mixReplayData(plan.skillsData, plan.replayData, plan.replay, skillsData)
+		if err != nil {
+			stdLogger.Printf("Mixing the skills phase data failed: %v", err)
+			finish("failed")
+			return
+		}
+		stdLogger.Printf("Mixed %d skills records from %s with %d replay records from %s into %s",
+			skills, plan.skillsData, replayed, plan.replayData, skillsData)
+	}
+
+	// 1) Knowledge phase
+	stdLogger.Printf("Starting %s phase on dataset %s...", phaseKnowledge, plan.knowledgeData)
+	knowledgeJobID, err := srv.startTrainJob(&TrainJobRequest{
+		ModelName:   plan.modelName,
+		BranchName:  plan.branchName,
+		Spec:        plan.knowledgeSpec,
+		DataPath:    plan.knowledgeData,
+		ParentJobID: job.JobID,
+		Phase:       phaseKnowledge,
+	})
+	if err != nil {
+		stdLogger.Printf("The %s phase failed to start: %v", phaseKnowledge, err)
+		finish("failed")
+		return
+	}
+	stdLogger.Printf("The %s phase started with job_id=%s", phaseKnowledge, knowledgeJobID)
+	if err := srv.waitForPhase(knowledgeJobID); err != nil {
+		stdLogger.Printf("The %s phase failed: %v", phaseKnowledge, err)
+		finish("failed")
+		return
+	}
+
+	// 2) Checkpoint selection
+	checkpoints, err := srv.listCheckpoints()
+	if err != nil {
+		stdLogger.Printf("Listing checkpoints failed: %v", err)
+		finish("failed")
+		return
+	}
+	var selected *CheckpointInfo
+	if plan.checkpointRule == "best" {
+		selected, err = srv.selectBestCheckpoint(checkpoints, knowledgeJobID)
+	} else {
+		selected, err = selectLatestCheckpoint(checkpoints, plan.checkpointRule, knowledgeJobID)
+	}
+	if err == nil {
+		selected, err = srv.resolveResumeCheckpoint(selected.Name)
+	}
+	if err != nil {
+		stdLogger.Printf("No usable checkpoint from the %s phase: %v", phaseKnowledge, err)
+		finish("failed")
+		return
+	}
+	stdLogger.Printf("Selected checkpoint %s of job %s", selected.Name, knowledgeJobID)
+
+	// 3) Skills phase, continuing from the selected checkpoint
+	stdLogger.Printf("Starting %s phase on dataset %s...", phaseSkills, skillsData)
+	skillsJobID, err := srv.startTrainJob(&TrainJobRequest{
+		ModelName:  plan.modelName,
+		BranchName: plan.branchName,
+		Spec:       plan.skillsSpec,
+		DataPath:   skillsData,
+		ResumeFrom: selected,
+		Phase:      phaseSkills,
+	})
+	if err != nil {
+		stdLogger.Printf("The %s phase failed to start: %v", phaseSkills, err)
+		finish("failed")
+		return
+	}
+	stdLogger.Printf("The %s phase started with job_id=%s", phaseSkills, skillsJobID)
+	if err := srv.waitForPhase(skillsJobID); err != nil {
+		stdLogger.Printf("The %s phase failed: %v", phaseSkills, err)
+		finish("failed")
+		return
+	}
+
+	finish("finished")
+	stdLogger.Println("Phased training job completed successfully.")
+}
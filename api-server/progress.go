@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Progress phases reported for generate and train jobs.
+const (
+	phaseStarting     = "starting"
+	phaseTaxonomyDiff = "taxonomy_diff"
+	phaseGenerating   = "generating"
+	phaseMixing       = "mixing"
+	phaseTraining     = "training"
+	phaseSaving       = "saving"
+	phaseComplete     = "complete"
+)
+
+// JobProgress is the progress of a generate or train job, parsed from its log.
+type JobProgress struct {
+	Phase      string   `json:"phase"`
+	Detail     string   `json:"detail,omitempty"`
+	Done       int      `json:"done"`
+	Total      int      `json:"total"`
+	Percent    float64  `json:"percent"`
+	ETASeconds *int     `json:"eta_seconds,omitempty"`
+	Epoch      *int     `json:"epoch,omitempty"`
+	Epochs     *int     `json:"epochs,omitempty"`
+	Step       *int     `json:"step,omitempty"`
+	Loss       *float64 `json:"loss,omitempty"`
+}
+
+// progressState is the parser state carried across log lines.
+type progressState struct {
+	JobProgress
+	// taxonomyFiles counts the files listed by the taxonomy diff; it is the leaf node total
+	// when ilab doesn't number the nodes.
+	taxonomyFiles  int
+	inTaxonomyList bool
+	nodes          int
+}
+
+// progressRule updates the state from a log line matching pattern.
+type progressRule struct {
+	pattern *regexp.Regexp
+	apply   func(s *progressState, m []string)
+}
+
+// progressRules are the log parsers per job ID prefix. Rules are tried in order and the
+// first match handles a line; add rules here to track more job types or log formats.
+var progressRules = map[string][]progressRule{
+	"g-": sdgProgressRules,
+	"t-": trainProgressRules,
+}
+
+// tqdmPattern matches tqdm progress bars such as
+// "Map: 45%|████▌     | 45/100 [00:30<00:36, 1.5 examples/s]".
+var tqdmPattern = regexp.MustCompile(`(?:^|\s)(?:([^|:]*?):\s*)?(\d{1,3})%\|[^|]*\|\s*(\d+)/(\d+)\s*\[([\d:]+)<([\d:?]+)`)
+
+// tqdmRule records a tqdm bar as the done/total and ETA of the current phase. While leaf
+// nodes are being generated the node count is kept instead, and the bar only labels it.
+var tqdmRule = progressRule{tqdmPattern, func(s *progressState, m []string) {
+	if s.Phase == phaseGenerating && s.nodes > 0 {
+		if label := strings.TrimSpace(m[1]); label != "" {
+			s.Detail = label
+		}
+		return
+	}
+	s.Done, _ = strconv.Atoi(m[3])
+	s.Total, _ = strconv.Atoi(m[4])
+	s.ETASeconds = parseClockDuration(m[6])
+	if label := strings.TrimSpace(m[1]); label != "" && s.Phase != phaseTraining {
+		s.Detail = label
+	}
+}}
+
+var sdgProgressRules = []progressRule{
+	// "Found new taxonomy files:" followed by "* knowledge/.../qna.yaml" lines
+	{regexp.MustCompile(`(?i)found (?:new|updated|\d+) taxonomy files`), func(s *progressState, _ []string) {
+		s.Phase = phaseTaxonomyDiff
+		s.inTaxonomyList = true
+	}},
+	{regexp.MustCompile(`^\s*[*-]\s+(\S+\.ya?ml)\s*$`), func(s *progressState, m []string) {
+		if s.inTaxonomyList {
+			s.taxonomyFiles++
+			s.Detail = m[1]
+		}
+	}},
+	// "Generating synthetic data for leaf node 2/5: knowledge/..." or "... leaf node: knowledge/..."
+	{regexp.MustCompile(`(?i)generat\w* (?:synthetic )?data for (?:leaf )?node\s*(?:(\d+)\s*/\s*(\d+))?[:\s]*(.*)$`), func(s *progressState, m []string) {
+		s.Phase = phaseGenerating
+		s.inTaxonomyList = false
+		s.nodes++
+		s.Done, s.Total = s.nodes-1, s.taxonomyFiles
+		if m[1] != "" {
+			n, _ := strconv.Atoi(m[1])
+			s.Done = n - 1
+			s.Total, _ = strconv.Atoi(m[2])
+		}
+		s.Detail = strings.TrimSpace(m[3])
+		s.ETASeconds = nil
+	}},
+	{regexp.MustCompile(`(?i)synthesizing new instructions|generating synthetic data using`), func(s *progressState, _ []string) {
+		s.Phase = phaseGenerating
+		s.inTaxonomyList = false
+	}},
+	{regexp.MustCompile(`(?i)\bmixing\b|data ?mixing|mixed dataset`), func(s *progressState, _ []string) {
+		s.Phase = phaseMixing
+		s.Detail = ""
+		s.Done, s.Total, s.ETASeconds = 0, 0, nil
+	}},
+	{regexp.MustCompile(`(?i)generation took|generation completed`), func(s *progressState, _ []string) {
+		s.Phase = phaseComplete
+	}},
+	tqdmRule,
+}
+
+var trainProgressRules = []progressRule{
+	// instructlab-training metrics: {"epoch": 0, "step": 15, "loss": 1.23, ...}
+	{regexp.MustCompile(`^\s*(\{.*"loss".*\})\s*$`), func(s *progressState, m []string) {
+		var metrics map[string]interface{}
+		if json.Unmarshal([]byte(m[1]), &metrics) != nil {
+			return
+		}
+		s.Phase = phaseTraining
+		setIntMetric(&s.Epoch, metrics["epoch"])
+		setIntMetric(&s.Step, metrics["step"])
+		if loss, ok := metrics["loss"].(float64); ok {
+			s.Loss = &loss
+		}
+	}},
+	// Hugging Face Trainer logs: {'loss': 1.23, 'learning_rate': 2e-05, 'epoch': 0.5}
+	{regexp.MustCompile(`'loss':\s*([\d.eE+-]+).*'epoch':\s*([\d.]+)`), func(s *progressState, m []string) {
+		s.Phase = phaseTraining
+		if loss, err := strconv.ParseFloat(m[1], 64); err == nil {
+			s.Loss = &loss
+		}
+		if epoch, err := strconv.ParseFloat(m[2], 64); err == nil {
+			e := int(epoch)
+			s.Epoch = &e
+		}
+	}},
+	// tqdm epoch bars: "Epoch 1: 45%|████▌ | 15/33 [01:00<01:12, ...]"
+	{regexp.MustCompile(`Epoch\s+(\d+):\s*\d{1,3}%\|[^|]*\|\s*(\d+)/(\d+)\s*\[[\d:]+<([\d:?]+)`), func(s *progressState, m []string) {
+		s.Phase = phaseTraining
+		epoch, _ := strconv.Atoi(m[1])
+		s.Epoch = &epoch
+		s.Done, _ = strconv.Atoi(m[2])
+		s.Total, _ = strconv.Atoi(m[3])
+		s.ETASeconds = parseClockDuration(m[4])
+	}},
+	// "Epoch 2/10", as printed by the simple and legacy pipelines
+	{regexp.MustCompile(`(?i)\bepoch\s*:?\s*(\d+)\s*/\s*(\d+)`), func(s *progressState, m []string) {
+		s.Phase = phaseTraining
+		epoch, _ := strconv.Atoi(m[1])
+		epochs, _ := strconv.Atoi(m[2])
+		s.Epoch, s.Epochs = &epoch, &epochs
+	}},
+	{regexp.MustCompile(`(?i)saving (?:model|checkpoint)|saved (?:model|checkpoint)`), func(s *progressState, _ []string) {
+		s.Phase = phaseSaving
+	}},
+	tqdmRule,
+}
+
+// setIntMetric stores a JSON number as an int.
+func setIntMetric(dst **int, v interface{}) {
+	if f, ok := v.(float64); ok {
+		n := int(f)
+		*dst = &n
+	}
+}
+
+// parseClockDuration parses tqdm's "[HH:]MM:SS" durations; "?" (unknown) yields nil.
+func parseClockDuration(s string) *int {
+	if s == "" || strings.Contains(s, "?") {
+		return nil
+	}
+	seconds := 0
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil
+		}
+		seconds = seconds*60 + n
+	}
+	return &seconds
+}
+
+// progressPollChunk is how much of a log is read at a time while parsing new output.
+const progressPollChunk = 64 * 1024
+
+// maxProgressLine caps the partial line kept between reads; longer lines are dropped.
+const maxProgressLine = 1024 * 1024
+
+// progressTracker incrementally parses a job's log, so each status request only reads the
+// output appended since the last one.
+type progressTracker struct {
+	mu    sync.Mutex
+	rules []progressRule
+	state *progressState
+	// offset is how far the log has been read; partial holds an unterminated last line
+	offset  int64
+	partial []byte
+}
+
+func newProgressTracker(rules []progressRule) *progressTracker {
+	return &progressTracker{rules: rules, state: newProgressState()}
+}
+
+func newProgressState() *progressState {
+	return &progressState{JobProgress: JobProgress{Phase: phaseStarting}}
+}
+
+// applyLine runs rules over a log line; the first matching rule handles it.
+func (s *progressState) applyLine(line string, rules []progressRule) {
+	for _, rule := range rules {
+		if m := rule.pattern.FindStringSubmatch(line); m != nil {
+			rule.apply(s, m)
+			return
+		}
+	}
+}
+
+// progress returns a copy of the progress parsed so far.
+func (s *progressState) progress() *JobProgress {
+	p := s.JobProgress
+	if p.Phase == phaseGenerating && p.Total == 0 && s.taxonomyFiles > 0 {
+		p.Total = s.taxonomyFiles
+	}
+	if p.Total > 0 {
+		p.Percent = float64(p.Done) * 100 / float64(p.Total)
+	}
+	return &p
+}
+
+// update parses the complete lines appended to path since the last call. tqdm redraws bars
+// with carriage returns, so those are treated as line breaks. A log that shrank was
+// rewritten and is parsed again from the start.
+func (t *progressTracker) update(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < t.offset {
+		t.state, t.offset, t.partial = newProgressState(), 0, nil
+	}
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		return err
+	}
+	buf := make([]byte, progressPollChunk)
+	for {
+		n, err := f.Read(buf)
+		t.offset += int64(n)
+		t.consume(buf[:n])
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// consume feeds data to the parser line by line, keeping an unterminated last line for the
+// next read.
+func (t *progressTracker) consume(data []byte) {
+	for len(data) > 0 {
+		end := bytes.IndexAny(data, "\n\r")
+		if end < 0 {
+			if len(t.partial)+len(data) > maxProgressLine {
+				t.partial = nil
+				return
+			}
+			t.partial = append(t.partial, data...)
+			return
+		}
+		line := data[:end]
+		if len(t.partial) > 0 {
+			line = append(t.partial, line...)
+			t.partial = nil
+		}
+		if len(line) > 0 {
+			t.state.applyLine(string(line), t.rules)
+		}
+		data = data[end+1:]
+	}
+}
+
+// parseProgress runs rules over log content line by line.
+func parseProgress(content string, rules []progressRule) *JobProgress {
+	t := newProgressTracker(rules)
+	t.consume([]byte(content + "\n"))
+	return t.state.progress()
+}
+
+// progressTrackerFor returns the progress tracker of a job, creating it on first use. Jobs
+// that have ended keep theirs, as their logs no longer grow and rereading them is wasted work.
+func (srv *ILabServer) progressTrackerFor(jobID string, rules []progressRule) *progressTracker {
+	srv.progressMu.Lock()
+	defer srv.progressMu.Unlock()
+	t, ok := srv.progressTrackers[jobID]
+	if !ok {
+		t = newProgressTracker(rules)
+		srv.progressTrackers[jobID] = t
+	}
+	return t
+}
+
+// jobProgress parses the progress of a generate or train job from its log. It reports
+// false for job types without progress rules.
+func (srv *ILabServer) jobProgress(job *Job) (*JobProgress, bool, error) {
+	prefix, _, _ := strings.Cut(job.JobID, "-")
+	rules, ok := progressRules[prefix+"-"]
+	if !ok {
+		return nil, false, nil
+	}
+	t := srv.progressTrackerFor(job.JobID, rules)
+	t.mu.Lock()
+	err := t.update(job.LogFile)
+	p := t.state.progress()
+	t.mu.Unlock()
+	if err != nil {
+		return nil, true, err
+	}
+	if prefix == "t" && p.Epochs == nil {
+		for _, arg := range job.Args {
+			if value, ok := strings.CutPrefix(arg, "--num-epochs="); ok {
+				if n, err := strconv.Atoi(value); err == nil {
+					p.Epochs = &n
+				}
+			}
+		}
+	}
+
+	switch job.Status {
+	case "finished":
+		p.Phase, p.Percent, p.ETASeconds = phaseComplete, 100, nil
+		p.Done = p.Total
+	case "running":
+		// Without a progress bar, extrapolate from the time spent on the items done so far
+		if p.ETASeconds == nil && p.Done > 0 && p.Total > p.Done {
+			elapsed := time.Since(job.StartTime).Seconds()
+			eta := int(elapsed / float64(p.Done) * float64(p.Total-p.Done))
+			p.ETASeconds = &eta
+		}
+	default:
+		p.ETASeconds = nil
+	}
+	return p, true, nil
+}
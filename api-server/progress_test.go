@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestParseProgressFixtures(t *testing.T) {
+	for _, tc := range []struct {
+		log   string
+		rules []progressRule
+		want  JobProgress
+	}{
+		{
+			log:   "testdata/sdg.log",
+			rules: sdgProgressRules,
+			want: JobProgress{
+				Phase:   phaseGenerating,
+				Detail:  "Filter",
+				Done:    1,
+				Total:   2,
+				Percent: 50,
+			},
+		},
+		{
+			log:   "testdata/train.log",
+			rules: trainProgressRules,
+			want: JobProgress{
+				Phase:      phaseTraining,
+				Done:       4,
+				Total:      33,
+				Percent:    float64(4) * 100 / 33,
+				ETASeconds: intPtr(116),
+				Epoch:      intPtr(1),
+				Step:       intPtr(15),
+				Loss:       func() *float64 { f := 1.2301; return &f }(),
+			},
+		},
+	} {
+		t.Run(filepath.Base(tc.log), func(t *testing.T) {
+			content, err := os.ReadFile(tc.log)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := parseProgress(string(content), tc.rules)
+			if !reflect.DeepEqual(*got, tc.want) {
+				t.Errorf("got %+v, want %+v", *got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJobProgressIncremental(t *testing.T) {
+	content, err := os.ReadFile("testdata/train.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile := filepath.Join(t.TempDir(), "train.log")
+	srv := &ILabServer{progressTrackers: make(map[string]*progressTracker)}
+	job := &Job{
+		JobID:     "t-1",
+		Status:    "running",
+		LogFile:   logFile,
+		Args:      []string{"model", "train", "--num-epochs=3"},
+		StartTime: time.Now(),
+	}
+
+	// Write the log in pieces, splitting a line, as a running job would
+	half := len(content) / 2
+	if err := os.WriteFile(logFile, content[:half], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := srv.jobProgress(job); err != nil {
+		t.Fatalf("jobProgress: %v", err)
+	}
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(content[half:]); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	got, ok, err := srv.jobProgress(job)
+	if !ok || err != nil {
+		t.Fatalf("jobProgress: %t, %v", ok, err)
+	}
+	want := parseProgress(string(content), trainProgressRules)
+	if got.Done != want.Done || got.Total != want.Total || *got.Step != *want.Step || *got.Epoch != *want.Epoch {
+		t.Errorf("got %+v, want %+v", *got, *want)
+	}
+	if got.Epochs == nil || *got.Epochs != 3 {
+		t.Errorf("Epochs = %v, want 3 from --num-epochs", got.Epochs)
+	}
+	if tracker := srv.progressTrackers[job.JobID]; tracker.offset != int64(len(content)) {
+		t.Errorf("offset = %d, want %d", tracker.offset, len(content))
+	}
+
+	// A rewritten, shorter log is parsed from the start
+	if err := os.WriteFile(logFile, []byte("LoRA is disabled\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err = srv.jobProgress(job)
+	if err != nil {
+		t.Fatalf("jobProgress: %v", err)
+	}
+	if got.Phase != phaseStarting || got.Step != nil {
+		t.Errorf("after rewrite got %+v, want the starting phase", *got)
+	}
+
+	job.Status = "finished"
+	got, _, _ = srv.jobProgress(job)
+	if got.Phase != phaseComplete || got.Percent != 100 || got.ETASeconds != nil {
+		t.Errorf("finished job got %+v", *got)
+	}
+}
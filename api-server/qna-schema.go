@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of taxonomy contributions, decided by the top-level directory of a qna.yaml file.
+const (
+	qnaKindKnowledge = "knowledge"
+	qnaKindSkill     = "skill"
+)
+
+// qna.yaml rules, matching what "ilab taxonomy diff" enforces.
+const (
+	qnaMaxLineLength             = 120
+	qnaMinSeedExamples           = 5
+	knowledgeQuestionsPerExample = 3
+)
+
+// qnaSchemaVersions are the supported schema versions per kind.
+var qnaSchemaVersions = map[string][]int{
+	qnaKindKnowledge: {3},
+	qnaKindSkill:     {2, 3},
+}
+
+// yamlErrorLinePattern finds the line number in a yaml.v3 syntax error.
+var yamlErrorLinePattern = regexp.MustCompile(`line (\d+): `)
+
+// QnaValidationError is one problem found in a qna.yaml file. Line is 1-based and omitted
+// when the problem has no line, e.g. a required field missing from an empty file. Field
+// is the path to the offending value, e.g. "seed_examples[2].questions_and_answers".
+type QnaValidationError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Rule    string `json:"rule"` // "syntax", "line-length", "required", "type", "version", "count" or "unknown-field"
+	Message string `json:"message"`
+}
+
+// qnaField describes a field of a qna.yaml mapping.
+type qnaField struct {
+	name     string
+	kind     yaml.Kind
+	required bool
+}
+
+// Fields per mapping and kind; fields not listed are rejected.
+var (
+	knowledgeFields = []qnaField{
+		{"version", yaml.ScalarNode, true},
+		{"created_by", yaml.ScalarNode, true},
+		{"domain", yaml.ScalarNode, true},
+		{"document_outline", yaml.ScalarNode, true},
+		{"seed_examples", yaml.SequenceNode, true},
+		{"document", yaml.MappingNode, true},
+	}
+	knowledgeSeedFields = []qnaField{
+		{"context", yaml.ScalarNode, true},
+		{"questions_and_answers", yaml.SequenceNode, true},
+	}
+	knowledgeQAFields = []qnaField{
+		{"question", yaml.ScalarNode, true},
+		{"answer", yaml.ScalarNode, true},
+	}
+	knowledgeDocumentFields = []qnaField{
+		{"repo", yaml.ScalarNode, true},
+		{"commit", yaml.ScalarNode, true},
+		{"patterns", yaml.SequenceNode, true},
+	}
+	skillFields = []qnaField{
+		{"version", yaml.ScalarNode, true},
+		{"created_by", yaml.ScalarNode, true},
+		{"task_description", yaml.ScalarNode, true},
+		{"seed_examples", yaml.SequenceNode, true},
+	}
+	skillSeedFields = []qnaField{
+		{"context", yaml.ScalarNode, false},
+		{"question", yaml.ScalarNode, true},
+		{"answer", yaml.ScalarNode, true},
+	}
+)
+
+// qnaValidator collects the problems found in one file.
+type qnaValidator struct {
+	file   string
+	errors []QnaValidationError
+}
+
+func (v *qnaValidator) add(line int, field, rule, format string, args ...interface{}) {
+	v.errors = append(v.errors, QnaValidationError{
+		File:    v.file,
+		Line:    line,
+		Field:   field,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// validateQnaYAML checks the content of the qna.yaml file at file, a contribution of the
+// given kind, and returns the problems found, ordered by line; none means the file is valid.
+func validateQnaYAML(file, kind string, content []byte) []QnaValidationError {
+	v := &qnaValidator{file: file}
+	v.checkLineLengths(content)
+	v.checkSchema(kind, content)
+	sort.SliceStable(v.errors, func(i, j int) bool { return v.errors[i].Line < v.errors[j].Line })
+	return v.errors
+}
+
+// checkSchema parses content and checks it against the schema of kind.
+func (v *qnaValidator) checkSchema(kind string, content []byte) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		line, message := 0, strings.TrimPrefix(err.Error(), "yaml: ")
+		if m := yamlErrorLinePattern.FindStringSubmatch(message); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			message = strings.Replace(message, m[0], "", 1)
+		}
+		v.add(line, "", "syntax", "%s", message)
+		return
+	}
+	if len(doc.Content) == 0 {
+		v.add(0, "", "required", "the file is empty")
+		return
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		v.add(root.Line, "", "type", "the file must be a mapping")
+		return
+	}
+
+	if kind == qnaKindKnowledge {
+		fields := v.checkMapping(root, "", knowledgeFields)
+		v.checkVersion(fields["version"], kind)
+		if seeds := fields["seed_examples"]; seeds != nil {
+			v.checkSeedExamples(seeds, func(seed *yaml.Node, field string) {
+				seedFields := v.checkMapping(seed, field, knowledgeSeedFields)
+				qas := seedFields["questions_and_answers"]
+				if qas == nil {
+					return
+				}
+				qaField := field + ".questions_and_answers"
+				if len(qas.Content) != knowledgeQuestionsPerExample {
+					v.add(qas.Line, qaField, "count", "each seed example must have exactly %d questions and answers; found %d",
+						knowledgeQuestionsPerExample, len(qas.Content))
+				}
+				for i, qa := range qas.Content {
+					v.checkMapping(qa, fmt.Sprintf("%s[%d]", qaField, i), knowledgeQAFields)
+				}
+			})
+		}
+		if document := fields["document"]; document != nil {
+			documentFields := v.checkMapping(document, "document", knowledgeDocumentFields)
+			if patterns := documentFields["patterns"]; patterns != nil {
+				if len(patterns.Content) == 0 {
+					v.add(patterns.Line, "document.patterns", "count", "at least one document pattern is required")
+				}
+				for i, p := range patterns.Content {
+					v.checkString(p, fmt.Sprintf("document.patterns[%d]", i))
+				}
+			}
+		}
+	} else {
+		fields := v.checkMapping(root, "", skillFields)
+		v.checkVersion(fields["version"], kind)
+		if seeds := fields["seed_examples"]; seeds != nil {
+			v.checkSeedExamples(seeds, func(seed *yaml.Node, field string) {
+				v.checkMapping(seed, field, skillSeedFields)
+			})
+		}
+	}
+}
+
+// checkLineLengths applies yamllint's line-length rule as ilab configures it: lines may
+// not exceed qnaMaxLineLength characters unless they hold a single word, e.g. a long URL.
+func (v *qnaValidator) checkLineLengths(content []byte) {
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		n := utf8.RuneCountInString(line)
+		if n <= qnaMaxLineLength || nonBreakableLine(line) {
+			continue
+		}
+		v.add(i+1, "", "line-length", "line too long (%d > %d characters)", n, qnaMaxLineLength)
+	}
+}
+
+// nonBreakableLine reports whether line, past its indentation and any comment or list
+// marker, is a single word.
+func nonBreakableLine(line string) bool {
+	rest := strings.TrimLeft(line, " ")
+	if strings.HasPrefix(rest, "#") {
+		rest = strings.TrimLeft(rest, "#")
+		rest = strings.TrimPrefix(rest, " ")
+	} else if strings.HasPrefix(rest, "- ") {
+		rest = rest[2:]
+	}
+	return !strings.Contains(rest, " ")
+}
+
+// checkMapping checks that node is a mapping with the given fields, and returns the value
+// nodes of the known fields that have the expected kind.
+func (v *qnaValidator) checkMapping(node *yaml.Node, field string, fields []qnaField) map[string]*yaml.Node {
+	values := map[string]*yaml.Node{}
+	if node.Kind != yaml.MappingNode {
+		v.add(node.Line, field, "type", "expected a mapping")
+		return values
+	}
+	known := map[string]qnaField{}
+	for _, f := range fields {
+		known[f.name] = f
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		name := qnaFieldPath(field, key.Value)
+		f, ok := known[key.Value]
+		if !ok {
+			v.add(key.Line, name, "unknown-field", "unknown field '%s'", key.Value)
+			continue
+		}
+		if f.kind == yaml.ScalarNode {
+			if v.checkString(value, name) {
+				values[f.name] = value
+			}
+			continue
+		}
+		if value.Kind != f.kind {
+			v.add(value.Line, name, "type", "expected %s", yamlKindName(f.kind))
+			continue
+		}
+		values[f.name] = value
+	}
+	for _, f := range fields {
+		if _, ok := values[f.name]; !ok && f.required && !mappingHasKey(node, f.name) {
+			v.add(node.Line, qnaFieldPath(field, f.name), "required", "missing required field '%s'", f.name)
+		}
+	}
+	return values
+}
+
+// checkString checks that node is a non-empty scalar.
+func (v *qnaValidator) checkString(node *yaml.Node, field string) bool {
+	if node.Kind != yaml.ScalarNode {
+		v.add(node.Line, field, "type", "expected a string")
+		return false
+	}
+	if node.Tag == "!!null" || strings.TrimSpace(node.Value) == "" {
+		v.add(node.Line, field, "required", "'%s' must not be empty", field)
+		return false
+	}
+	return true
+}
+
+// checkVersion checks the schema version against the versions supported for kind.
+func (v *qnaValidator) checkVersion(node *yaml.Node, kind string) {
+	if node == nil {
+		return
+	}
+	supported := qnaSchemaVersions[kind]
+	version, err := strconv.Atoi(node.Value)
+	if err == nil && node.Tag == "!!int" {
+		for _, s := range supported {
+			if version == s {
+				return
+			}
+		}
+	}
+	names := make([]string, len(supported))
+	for i, s := range supported {
+		names[i] = strconv.Itoa(s)
+	}
+	v.add(node.Line, "version", "version", "unsupported %s schema version '%s' (expected %s)", kind, node.Value, strings.Join(names, " or "))
+}
+
+// checkSeedExamples checks the number of seed examples and each example with check.
+func (v *qnaValidator) checkSeedExamples(seeds *yaml.Node, check func(seed *yaml.Node, field string)) {
+	if len(seeds.Content) < qnaMinSeedExamples {
+		v.add(seeds.Line, "seed_examples", "count", "at least %d seed examples are required; found %d", qnaMinSeedExamples, len(seeds.Content))
+	}
+	for i, seed := range seeds.Content {
+		check(seed, fmt.Sprintf("seed_examples[%d]", i))
+	}
+}
+
+func mappingHasKey(node *yaml.Node, name string) bool {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return true
+		}
+	}
+	return false
+}
+
+func qnaFieldPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func yamlKindName(kind yaml.Kind) string {
+	switch kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return "a string"
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// knowledgeQna returns a knowledge qna.yaml of the given version with seeds seed examples
+// of qas questions and answers each. Seed example i starts on line 6+i*(2+2*qas).
+func knowledgeQna(version string, seeds, qas int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "version: %s\ncreated_by: tester\ndomain: science\ndocument_outline: Facts about science\nseed_examples:\n", version)
+	for i := 1; i <= seeds; i++ {
+		fmt.Fprintf(&b, "  - context: Context %d\n    questions_and_answers:\n", i)
+		for j := 1; j <= qas; j++ {
+			fmt.Fprintf(&b, "      - question: Question %d.%d\n        answer: Answer %d.%d\n", i, j, i, j)
+		}
+	}
+	b.WriteString("document:\n  repo: https://github.com/example/docs\n  commit: abc123\n  patterns:\n    - \"*.md\"\n")
+	return b.String()
+}
+
+// skillQna returns a skill qna.yaml of the given version with seeds seed examples. Seed
+// example i starts on line 4+i*2.
+func skillQna(version string, seeds int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "version: %s\ncreated_by: tester\ntask_description: Rhyming\nseed_examples:\n", version)
+	for i := 1; i <= seeds; i++ {
+		fmt.Fprintf(&b, "  - question: Question %d\n    answer: Answer %d\n", i, i)
+	}
+	return b.String()
+}
+
+// qnaProblem is the part of a QnaValidationError the tests compare.
+type qnaProblem struct {
+	Line  int
+	Field string
+	Rule  string
+}
+
+func qnaProblems(errs []QnaValidationError) []qnaProblem {
+	var problems []qnaProblem
+	for _, e := range errs {
+		problems = append(problems, qnaProblem{e.Line, e.Field, e.Rule})
+	}
+	return problems
+}
+
+func TestValidateQnaYAML(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		kind    string
+		content string
+		want    []qnaProblem
+	}{
+		{name: "valid knowledge", kind: qnaKindKnowledge, content: knowledgeQna("3", 5, 3)},
+		{name: "valid skill v2", kind: qnaKindSkill, content: skillQna("2", 5)},
+		{name: "valid skill v3", kind: qnaKindSkill, content: skillQna("3", 5)},
+		{
+			name:    "knowledge v2",
+			kind:    qnaKindKnowledge,
+			content: knowledgeQna("2", 5, 3),
+			want:    []qnaProblem{{1, "version", "version"}},
+		},
+		{
+			name:    "skill v1",
+			kind:    qnaKindSkill,
+			content: skillQna("1", 5),
+			want:    []qnaProblem{{1, "version", "version"}},
+		},
+		{
+			name:    "quoted version",
+			kind:    qnaKindSkill,
+			content: skillQna(`"3"`, 5),
+			want:    []qnaProblem{{1, "version", "version"}},
+		},
+		{
+			name:    "too few knowledge seed examples",
+			kind:    qnaKindKnowledge,
+			content: knowledgeQna("3", 4, 3),
+			want:    []qnaProblem{{6, "seed_examples", "count"}},
+		},
+		{
+			name:    "too few skill seed examples",
+			kind:    qnaKindSkill,
+			content: skillQna("3", 1),
+			want:    []qnaProblem{{5, "seed_examples", "count"}},
+		},
+		{
+			name:    "two questions and answers",
+			kind:    qnaKindKnowledge,
+			content: knowledgeQna("3", 5, 2),
+			want: []qnaProblem{
+				{8, "seed_examples[0].questions_and_answers", "count"},
+				{14, "seed_examples[1].questions_and_answers", "count"},
+				{20, "seed_examples[2].questions_and_answers", "count"},
+				{26, "seed_examples[3].questions_and_answers", "count"},
+				{32, "seed_examples[4].questions_and_answers", "count"},
+			},
+		},
+		{
+			name:    "four questions and answers in one seed example",
+			kind:    qnaKindKnowledge,
+			content: strings.Replace(knowledgeQna("3", 5, 3), "  - context: Context 2\n", "      - question: Extra\n        answer: Extra\n  - context: Context 2\n", 1),
+			want:    []qnaProblem{{8, "seed_examples[0].questions_and_answers", "count"}},
+		},
+		{
+			name:    "unknown top-level field",
+			kind:    qnaKindSkill,
+			content: skillQna("3", 5) + "tags: poetry\n",
+			want:    []qnaProblem{{15, "tags", "unknown-field"}},
+		},
+		{
+			name:    "unknown nested field",
+			kind:    qnaKindKnowledge,
+			content: strings.Replace(knowledgeQna("3", 5, 3), "        answer: Answer 1.2\n", "        answer: Answer 1.2\n        hint: none\n", 1),
+			want:    []qnaProblem{{12, "seed_examples[0].questions_and_answers[1].hint", "unknown-field"}},
+		},
+		{
+			name:    "missing top-level field",
+			kind:    qnaKindKnowledge,
+			content: strings.Replace(knowledgeQna("3", 5, 3), "domain: science\n", "", 1),
+			want:    []qnaProblem{{1, "domain", "required"}},
+		},
+		{
+			name:    "missing nested field",
+			kind:    qnaKindKnowledge,
+			content: strings.Replace(knowledgeQna("3", 5, 3), "      - question: Question 1.1\n        answer: Answer 1.1\n", "      - answer: Answer 1.1\n", 1),
+			want:    []qnaProblem{{8, "seed_examples[0].questions_and_answers[0].question", "required"}},
+		},
+		{
+			name:    "empty value",
+			kind:    qnaKindSkill,
+			content: strings.Replace(skillQna("3", 5), "answer: Answer 2", "answer:", 1),
+			want:    []qnaProblem{{8, "seed_examples[1].answer", "required"}},
+		},
+		{
+			name:    "wrong type",
+			kind:    qnaKindSkill,
+			content: strings.Replace(skillQna("3", 5), "task_description: Rhyming", "task_description: [a, b]", 1),
+			want:    []qnaProblem{{3, "task_description", "type"}},
+		},
+		{
+			name:    "empty file",
+			kind:    qnaKindSkill,
+			content: "",
+			want:    []qnaProblem{{0, "", "required"}},
+		},
+		{
+			name:    "unclosed quote",
+			kind:    qnaKindSkill,
+			content: "version: 3\ncreated_by: \"tester\ntask_description: x\n",
+			want:    []qnaProblem{{2, "", "syntax"}},
+		},
+		{
+			name:    "bad indentation",
+			kind:    qnaKindSkill,
+			content: "version: 3\n created_by: tester\n",
+			want:    []qnaProblem{{2, "", "syntax"}},
+		},
+		{
+			name:    "tab indentation",
+			kind:    qnaKindSkill,
+			content: "version: 3\nseed_examples:\n\t- question: q\n",
+			want:    []qnaProblem{{3, "", "syntax"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := validateQnaYAML("qna.yaml", tc.kind, []byte(tc.content))
+			if got := qnaProblems(errs); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("problems = %+v, want %+v\n%+v", got, tc.want, errs)
+			}
+			for _, e := range errs {
+				if e.Rule == "syntax" && yamlErrorLinePattern.MatchString(e.Message) {
+					t.Errorf("syntax message %q still holds the line", e.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestQnaLineLength(t *testing.T) {
+	long := strings.Repeat("x", qnaMaxLineLength+1)
+	url := "https://example.com/" + strings.Repeat("a", qnaMaxLineLength)
+	for _, tc := range []struct {
+		name string
+		line string
+		want bool
+	}{
+		{name: "at the limit", line: "answer: " + strings.Repeat("x", qnaMaxLineLength-8)},
+		{name: "prose", line: "answer: " + long, want: true},
+		{name: "multibyte at the limit", line: strings.Repeat("é", qnaMaxLineLength)},
+		{name: "url", line: "  " + url},
+		{name: "list item url", line: "    - " + url},
+		{name: "comment url", line: "# " + url},
+		{name: "indented comment url", line: "  #" + url},
+		{name: "key and url", line: "repo: " + url, want: true},
+		{name: "list item of two words", line: "- see " + url, want: true},
+		{name: "comment of two words", line: "# see " + url, want: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			content := "version: 3\n" + tc.line + "\r\n"
+			var got []QnaValidationError
+			for _, e := range validateQnaYAML("qna.yaml", qnaKindSkill, []byte(content)) {
+				if e.Rule == "line-length" {
+					got = append(got, e)
+				}
+			}
+			if (len(got) > 0) != tc.want {
+				t.Fatalf("line-length errors = %+v, want one %t", got, tc.want)
+			}
+			if tc.want && got[0].Line != 2 {
+				t.Errorf("line = %d, want 2", got[0].Line)
+			}
+		})
+	}
+}
+
+func TestPutTaxonomyFileRejectsInvalidContent(t *testing.T) {
+	srv := newTaxonomyTestRepo(t)
+	srv.log = zap.NewNop().Sugar()
+	const path = "knowledge/science/qna.yaml"
+
+	for _, tc := range []struct {
+		name       string
+		query      string
+		content    string
+		wantStatus int
+		wantValid  bool
+	}{
+		{name: "invalid", content: knowledgeQna("3", 4, 3), wantStatus: http.StatusUnprocessableEntity},
+		{name: "invalid dry run", query: "?dry_run=true", content: knowledgeQna("3", 4, 3), wantStatus: http.StatusOK},
+		{name: "valid dry run", query: "?dry_run=true", content: knowledgeQna("3", 5, 3), wantStatus: http.StatusOK, wantValid: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			body, _ := json.Marshal(TaxonomyFileRequest{Branch: "main", Content: tc.content})
+			r := httptest.NewRequest(http.MethodPut, "/taxonomy/files/"+path+tc.query, bytes.NewReader(body))
+			r = mux.SetURLVars(r, map[string]string{"path": path})
+			w := httptest.NewRecorder()
+			srv.putTaxonomyFileHandler(w, r)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.wantStatus, w.Body)
+			}
+			var resp TaxonomyFileResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Valid != tc.wantValid || resp.CommitSHA != "" {
+				t.Errorf("valid = %t, commit_sha = %q, want valid %t and nothing committed", resp.Valid, resp.CommitSHA, tc.wantValid)
+			}
+			want := []qnaProblem{{6, "seed_examples", "count"}}
+			if tc.wantValid {
+				want = nil
+			}
+			if got := qnaProblems(resp.Errors); !reflect.DeepEqual(got, want) {
+				t.Errorf("errors = %+v, want %+v", got, want)
+			}
+			if len(resp.Errors) > 0 && resp.Errors[0].File != path {
+				t.Errorf("file = %q, want %q", resp.Errors[0].File, path)
+			}
+		})
+	}
+	if out, err := srv.runTaxonomyGit("ls-tree", "-r", "--name-only", "main"); err != nil || out != "" {
+		t.Errorf("main holds %q (%v), want nothing committed", out, err)
+	}
+}
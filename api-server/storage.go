@@ -0,0 +1,354 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// StorageEntry is the disk usage of a single model, checkpoint, dataset or directory.
+type StorageEntry struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+}
+
+// StorageReport is returned by the /storage endpoint.
+type StorageReport struct {
+	Models      []StorageEntry   `json:"models"`
+	Checkpoints []StorageEntry   `json:"checkpoints"`
+	Datasets    []StorageEntry   `json:"datasets"`
+	Logs        StorageEntry     `json:"logs"`
+	Totals      map[string]int64 `json:"totals"`
+}
+
+// dirSize returns the total size of the regular files under path (or of path itself if it is a file).
+func dirSize(path string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// isModelDir reports whether dir holds a model itself rather than a namespace of models
+// (e.g. "instructlab/" containing "granite-7b-lab/").
+func isModelDir(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	visible := 0
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if !e.IsDir() {
+			return true
+		}
+		visible++
+	}
+	return visible == 0
+}
+
+// listModelEntries returns the models under modelsDir, named relative to it.
+func listModelEntries(modelsDir string) ([]string, error) {
+	entries, err := os.ReadDir(modelsDir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		full := filepath.Join(modelsDir, e.Name())
+		if !e.IsDir() || isModelDir(full) {
+			names = append(names, e.Name())
+			continue
+		}
+		nested, err := os.ReadDir(full)
+		if err != nil {
+			continue
+		}
+		for _, n := range nested {
+			if !strings.HasPrefix(n.Name(), ".") {
+				names = append(names, e.Name()+"/"+n.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// sizeEntries measures each named entry under dir. A missing dir yields no entries.
+func sizeEntries(dir string, names []string) ([]StorageEntry, int64) {
+	entries := []StorageEntry{}
+	var total int64
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		size, err := dirSize(path)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, StorageEntry{Name: name, Path: path, Bytes: size})
+		total += size
+	}
+	return entries, total
+}
+
+// sizeCheckpoints measures every checkpoint, in the shared directory or a job's output directory.
+func sizeCheckpoints() ([]StorageEntry, int64) {
+	entries := []StorageEntry{}
+	var total int64
+	dirs, _ := listCheckpointDirs()
+	for _, d := range dirs {
+		size, err := dirSize(d.path)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, StorageEntry{Name: d.name, Path: d.path, Bytes: size})
+		total += size
+	}
+	return entries, total
+}
+
+// dirEntryNames lists the non-hidden entries of dir, or nothing if it does not exist.
+func dirEntryNames(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+// referencesPath reports whether arg mentions path as a whole path (or a parent of it).
+func referencesPath(arg, path string) bool {
+	for idx := strings.Index(arg, path); idx >= 0; {
+		end := idx + len(path)
+		if end == len(arg) || arg[end] == '/' {
+			return true
+		}
+		next := strings.Index(arg[end:], path)
+		if next < 0 {
+			break
+		}
+		idx = end + next
+	}
+	return false
+}
+
+// jobOutputDirFlags are the arguments naming the directory a job writes its output to.
+var jobOutputDirFlags = []string{"--ckpt-output-dir=", "--output-dir="}
+
+// writesPath reports whether arg names an output directory that path is inside of, such as
+// the checkpoints directory of a training job.
+func writesPath(arg, path string) bool {
+	for _, flag := range jobOutputDirFlags {
+		if !strings.HasPrefix(arg, flag) {
+			continue
+		}
+		dir := filepath.Clean(strings.TrimPrefix(arg, flag))
+		return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+	}
+	return false
+}
+
+// pathInUse returns a description of what is using path (a served model or a running job),
+// or an empty string if nothing is. Callers must hold localServesMu.
+func (srv *ILabServer) pathInUse(path string) (string, error) {
+	for _, s := range srv.localServes {
+		if referencesPath(s.ModelPath, path) {
+			return fmt.Sprintf("served by local serve '%s'", s.Name), nil
+		}
+	}
+
+	if srv.useVllm {
+		containers, err := srv.ListVllmContainers()
+		if err != nil {
+			return "", fmt.Errorf("failed to list vllm containers: %v", err)
+		}
+		for _, c := range containers {
+			if referencesPath(c.ModelPath, path) {
+				return fmt.Sprintf("served by vllm container '%s' (%s)", c.ContainerID, c.ServedModelName), nil
+			}
+		}
+	}
+
+	jobs, err := srv.listAllJobs()
+	if err != nil {
+		return "", fmt.Errorf("failed to list jobs: %v", err)
+	}
+	for _, j := range jobs {
+		if j.Status != "running" {
+			continue
+		}
+		for i, arg := range j.Args {
+			if referencesPath(arg, path) {
+				return fmt.Sprintf("used by running job '%s'", j.JobID), nil
+			}
+			if writesPath(arg, path) {
+				return fmt.Sprintf("being written by running job '%s'", j.JobID), nil
+			}
+			// Pipeline jobs record the model name rather than its path
+			if j.Cmd == "pipeline-generate-train" && i == 0 {
+				if modelPath, err := getFullModelPath(arg); err == nil && referencesPath(modelPath, path) {
+					return fmt.Sprintf("used by running job '%s'", j.JobID), nil
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+// deletePath removes path unless it is in use, writing the HTTP response for the outcome.
+func (srv *ILabServer) deletePath(w http.ResponseWriter, kind, name, path string) bool {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		http.Error(w, fmt.Sprintf("%s '%s' does not exist", kind, name), http.StatusNotFound)
+		return false
+	}
+
+	// Sizing a large model takes a while, so it is done before the in-use check
+	size, _ := dirSize(path)
+
+	// The serve lock is held from the check until the path is moved to a hidden name, so a
+	// local serve cannot start on it in between; the slow removal then runs without the lock
+	srv.localServesMu.Lock()
+	reason, err := srv.pathInUse(path)
+	if err != nil {
+		srv.localServesMu.Unlock()
+		srv.log.Errorf("Error checking whether %s '%s' is in use: %v", kind, name, err)
+		http.Error(w, fmt.Sprintf("Failed to check whether %s '%s' is in use", kind, name), http.StatusInternalServerError)
+		return false
+	}
+	if reason != "" {
+		srv.localServesMu.Unlock()
+		srv.log.Infof("Refusing to delete %s '%s': %s", kind, name, reason)
+		http.Error(w, fmt.Sprintf("Cannot delete %s '%s': %s", kind, name, reason), http.StatusConflict)
+		return false
+	}
+	staging := filepath.Join(filepath.Dir(path), fmt.Sprintf(".delete-%s-%d", filepath.Base(path), time.Now().UnixNano()))
+	err = os.Rename(path, staging)
+	srv.localServesMu.Unlock()
+	if err != nil {
+		srv.log.Errorf("Error deleting %s '%s': %v", kind, path, err)
+		http.Error(w, fmt.Sprintf("Failed to delete %s '%s'", kind, name), http.StatusInternalServerError)
+		return false
+	}
+	if err := os.RemoveAll(staging); err != nil {
+		srv.log.Errorf("Error deleting %s '%s' (moved to %s): %v", kind, path, staging, err)
+		http.Error(w, fmt.Sprintf("Failed to delete %s '%s'", kind, name), http.StatusInternalServerError)
+		return false
+	}
+	srv.log.Infof("Deleted %s '%s' (%d bytes freed)", kind, path, size)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      "deleted",
+		"name":        name,
+		"path":        path,
+		"bytes_freed": size,
+	})
+	return true
+}
+
+// deleteModelHandler handles DELETE /models/{name}.
+func (srv *ILabServer) deleteModelHandler(w http.ResponseWriter, r *http.Request) {
+	modelName := mux.Vars(r)["name"]
+	srv.log.Infof("DELETE /models/%s called", modelName)
+
+	_, modelPath, err := srv.resolveModel(modelName)
+	if err != nil {
+		srv.writeModelError(w, modelName, err)
+		return
+	}
+	if srv.deletePath(w, "model", modelName, modelPath) {
+		go srv.forceRefreshModelCache()
+	}
+}
+
+// deleteCheckpointHandler handles DELETE /checkpoints/{name}.
+func (srv *ILabServer) deleteCheckpointHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	srv.log.Infof("DELETE /checkpoints/%s called", name)
+
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		http.Error(w, fmt.Sprintf("Invalid checkpoint name '%s'", name), http.StatusBadRequest)
+		return
+	}
+	checkpointsDir, err := getCheckpointsDir()
+	if err != nil {
+		srv.log.Errorf("Error getting checkpoints directory: %v", err)
+		http.Error(w, "Failed to get checkpoints directory", http.StatusInternalServerError)
+		return
+	}
+	path := filepath.Join(checkpointsDir, name)
+	dirs, _ := listCheckpointDirs()
+	for _, d := range dirs {
+		if d.name == name {
+			path = d.path
+			break
+		}
+	}
+	if srv.deletePath(w, "checkpoint", name, path) {
+		// Forget its provenance so a later checkpoint reusing the name starts clean
+		_, _ = srv.db.Exec("DELETE FROM checkpoints WHERE name = ?", name)
+		_, _ = srv.db.Exec("DELETE FROM checkpoint_evaluations WHERE checkpoint = ?", name)
+	}
+}
+
+// getStorageHandler handles GET /storage.
+func (srv *ILabServer) getStorageHandler(w http.ResponseWriter, r *http.Request) {
+	srv.log.Info("GET /storage called")
+
+	modelsDir, err := getModelsDir()
+	if err != nil {
+		http.Error(w, "Failed to get models directory", http.StatusInternalServerError)
+		return
+	}
+	datasetsDir, err := getDatasetsDir()
+	if err != nil {
+		http.Error(w, "Failed to get datasets directory", http.StatusInternalServerError)
+		return
+	}
+
+	report := StorageReport{Totals: make(map[string]int64)}
+	modelNames, _ := listModelEntries(modelsDir)
+	report.Models, report.Totals["models"] = sizeEntries(modelsDir, modelNames)
+	report.Checkpoints, report.Totals["checkpoints"] = sizeCheckpoints()
+	report.Datasets, report.Totals["datasets"] = sizeEntries(datasetsDir, dirEntryNames(datasetsDir))
+
+	logsDir, _ := filepath.Abs("logs")
+	logsSize, _ := dirSize(logsDir)
+	report.Logs = StorageEntry{Name: "logs", Path: logsDir, Bytes: logsSize}
+	report.Totals["logs"] = logsSize
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+	srv.log.Infof("GET /storage => models=%d, checkpoints=%d, datasets=%d, logs=%d bytes",
+		report.Totals["models"], report.Totals["checkpoints"], report.Totals["datasets"], report.Totals["logs"])
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPathInUse(t *testing.T) {
+	srv := newTestServer(t)
+	srv.localServes["chat"] = &LocalServe{Name: "chat", ModelPath: "/models/served.gguf"}
+	for _, job := range []*Job{
+		{JobID: "t-1", Cmd: "ilab", Status: "running", Args: []string{"model", "train", "--model-path=/models/granite", "--ckpt-output-dir=/ckpt/jobs/t-1"}},
+		{JobID: "t-2", Cmd: "ilab", Status: "finished", Args: []string{"model", "train", "--model-path=/models/old", "--ckpt-output-dir=/ckpt/jobs/t-2"}},
+		{JobID: "g-1", Cmd: "ilab", Status: "running", Args: []string{"data", "generate", "--output-dir=/datasets/g-1/"}},
+	} {
+		job.StartTime = time.Now()
+		if err := srv.createJob(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"/models/served.gguf", "served by local serve 'chat'"},
+		{"/models/granite", "used by running job 't-1'"},
+		{"/models/granite-2", ""},
+		{"/models/old", ""},
+		{"/ckpt/jobs/t-1/hf_format/samples_1000", "being written by running job 't-1'"},
+		{"/ckpt/jobs/t-1", "used by running job 't-1'"},
+		{"/ckpt/jobs", "used by running job 't-1'"},
+		{"/ckpt/jobs/t-10/hf_format/samples_1000", ""},
+		{"/ckpt/jobs/t-2/hf_format/samples_1000", ""},
+		{"/datasets/g-1/train.jsonl", "being written by running job 'g-1'"},
+		{"/datasets/g-10/train.jsonl", ""},
+	} {
+		got, err := srv.pathInUse(tc.path)
+		if err != nil {
+			t.Fatalf("pathInUse(%q): %v", tc.path, err)
+		}
+		if got != tc.want {
+			t.Errorf("pathInUse(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	errInvalidTaxonomyPath  = errors.New("invalid taxonomy path")
+	errTaxonomyFileNotFound = errors.New("taxonomy file not found")
+	errBranchMoved          = errors.New("branch moved")
+	errNoGitIdentity        = errors.New("no git identity")
+)
+
+const (
+	// maxTaxonomyPathLength bounds the taxonomy file paths the server accepts.
+	maxTaxonomyPathLength = 1024
+	// maxQnaFileBytes bounds the content of a qna.yaml written through the API.
+	maxQnaFileBytes = 1 << 20
+)
+
+// taxonomyRoots maps the top-level taxonomy directories to the kind of their contributions.
+var taxonomyRoots = map[string]string{
+	"knowledge":            qnaKindKnowledge,
+	"compositional_skills": qnaKindSkill,
+	"foundational_skills":  qnaKindSkill,
+}
+
+// taxonomyPathSegmentPattern is a directory name in a taxonomy path. Like branch names,
+// segments never start with "-" or ".", so they can't be taken for options or escape the tree.
+var taxonomyPathSegmentPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// TaxonomyTreeEntry is a qna.yaml file of the taxonomy.
+type TaxonomyTreeEntry struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"` // "knowledge" or "skill"
+}
+
+// TaxonomyTreeResponse is returned by GET /taxonomy/tree.
+type TaxonomyTreeResponse struct {
+	Branch    string              `json:"branch"`
+	CommitSHA string              `json:"commit_sha"`
+	Files     []TaxonomyTreeEntry `json:"files"`
+}
+
+// TaxonomyFileRequest is the body of PUT /taxonomy/files/{path}.
+type TaxonomyFileRequest struct {
+	Branch      string `json:"branch"`
+	Content     string `json:"content"`
+	Message     string `json:"message,omitempty"`
+	AuthorName  string `json:"author_name,omitempty"`
+	AuthorEmail string `json:"author_email,omitempty"`
+	// CommitSHA is the branch commit the edit is based on. When set, the write fails if the
+	// branch has moved since.
+	CommitSHA string `json:"commit_sha,omitempty"`
+}
+
+// TaxonomyFileResponse is returned by GET and PUT /taxonomy/files/{path}.
+type TaxonomyFileResponse struct {
+	Path      string               `json:"path"`
+	Kind      string               `json:"kind"`
+	Branch    string               `json:"branch"`
+	CommitSHA string               `json:"commit_sha,omitempty"`
+	Content   string               `json:"content,omitempty"`
+	Created   bool                 `json:"created,omitempty"`
+	Unchanged bool                 `json:"unchanged,omitempty"`
+	Valid     bool                 `json:"valid"`
+	Errors    []QnaValidationError `json:"errors"`
+}
+
+// taxonomyFileKind validates the path of a qna.yaml file relative to the taxonomy root and
+// returns the kind of contribution it holds.
+func taxonomyFileKind(path string) (string, error) {
+	if len(path) > maxTaxonomyPathLength {
+		return "", errInvalidTaxonomyPath
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) < 3 || parts[len(parts)-1] != "qna.yaml" {
+		return "", errInvalidTaxonomyPath
+	}
+	kind, ok := taxonomyRoots[parts[0]]
+	if !ok {
+		return "", errInvalidTaxonomyPath
+	}
+	for _, part := range parts[1 : len(parts)-1] {
+		if !taxonomyPathSegmentPattern.MatchString(part) {
+			return "", errInvalidTaxonomyPath
+		}
+	}
+	return kind, nil
+}
+
+// writeTaxonomyFileError maps taxonomy file errors to HTTP responses.
+func (srv *ILabServer) writeTaxonomyFileError(w http.ResponseWriter, path string, err error) {
+	switch {
+	case errors.Is(err, errInvalidTaxonomyPath):
+		http.Error(w, fmt.Sprintf("Invalid taxonomy path '%s' (expected <knowledge|compositional_skills|foundational_skills>/.../qna.yaml)", path), http.StatusBadRequest)
+	case errors.Is(err, errTaxonomyFileNotFound):
+		http.Error(w, fmt.Sprintf("Taxonomy file '%s' not found", path), http.StatusNotFound)
+	case errors.Is(err, errBranchMoved):
+		http.Error(w, "The branch has moved since the given commit; reload the file and try again", http.StatusConflict)
+	case errors.Is(err, errNoGitIdentity):
+		http.Error(w, "No git identity is configured for the taxonomy repository; pass author_name and author_email", http.StatusBadRequest)
+	default:
+		srv.log.Errorf("Error accessing taxonomy file '%s': %v", path, err)
+		http.Error(w, "Failed to access the taxonomy file", http.StatusInternalServerError)
+	}
+}
+
+// resolveTaxonomyRef returns the branch a taxonomy read uses and its commit: the given
+// branch, or else the base branch, or else HEAD.
+func (srv *ILabServer) resolveTaxonomyRef(branch string) (string, string, error) {
+	if branch != "" {
+		if err := srv.validateBranch(branch); err != nil {
+			return "", "", err
+		}
+	} else if branch = srv.taxonomyBaseBranch(); branch == "" {
+		branch = "HEAD"
+	}
+	sha, err := srv.resolveBranchCommit(branch)
+	if err != nil {
+		return "", "", err
+	}
+	return branch, sha, nil
+}
+
+// readTaxonomyFile returns the content of path at commit sha.
+func (srv *ILabServer) readTaxonomyFile(sha, path string) ([]byte, error) {
+	if _, err := srv.runTaxonomyGit("cat-file", "-e", sha+":"+path); err != nil {
+		return nil, errTaxonomyFileNotFound
+	}
+	return srv.runTaxonomyGitInput(nil, nil, "cat-file", "blob", sha+":"+path)
+}
+
+// commitTaxonomyFile commits content as path to branch and returns the new commit, or the
+// branch's commit when the file is unchanged. The commit is built in a temporary index, so
+// neither the shared working copy nor its index is touched, and the branch is only moved
+// if it still points at its parent. base, when set, is the commit the edit is based on. An
+// empty message defaults to "Add <path>" or "Update <path>".
+func (srv *ILabServer) commitTaxonomyFile(branch, path string, content []byte, base, message string, identity []string) (sha string, created, unchanged bool, err error) {
+	parent, err := srv.resolveBranchCommit(branch)
+	if err != nil {
+		return "", false, false, err
+	}
+	if base != "" {
+		// base may be abbreviated, so compare the full commit it names
+		resolved, err := srv.runTaxonomyGit("rev-parse", "--verify", "--quiet", base+"^{commit}")
+		if err != nil || resolved != parent {
+			return "", false, false, errBranchMoved
+		}
+	}
+	_, err = srv.runTaxonomyGit("cat-file", "-e", parent+":"+path)
+	created = err != nil
+	if message == "" {
+		if created {
+			message = fmt.Sprintf("Add %s", path)
+		} else {
+			message = fmt.Sprintf("Update %s", path)
+		}
+	}
+
+	out, err := srv.runTaxonomyGitInput(content, nil, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return "", false, false, err
+	}
+	blob := strings.TrimSpace(string(out))
+
+	indexDir, err := os.MkdirTemp("", "taxonomy-index-")
+	if err != nil {
+		return "", false, false, fmt.Errorf("failed to create a temporary index: %v", err)
+	}
+	defer os.RemoveAll(indexDir)
+	indexEnv := []string{"GIT_INDEX_FILE=" + filepath.Join(indexDir, "index")}
+	if _, err := srv.runTaxonomyGitInput(nil, indexEnv, "read-tree", parent); err != nil {
+		return "", false, false, err
+	}
+	if _, err := srv.runTaxonomyGitInput(nil, indexEnv, "update-index", "--add", "--cacheinfo", "100644,"+blob+","+path); err != nil {
+		return "", false, false, err
+	}
+	out, err = srv.runTaxonomyGitInput(nil, indexEnv, "write-tree")
+	if err != nil {
+		return "", false, false, err
+	}
+	tree := strings.TrimSpace(string(out))
+	if parentTree, err := srv.runTaxonomyGit("rev-parse", parent+"^{tree}"); err == nil && parentTree == tree {
+		return parent, false, true, nil
+	}
+
+	out, err = srv.runTaxonomyGitInput([]byte(message), identity, "commit-tree", tree, "-p", parent)
+	if err != nil {
+		if strings.Contains(err.Error(), "tell me who you are") || strings.Contains(err.Error(), "empty ident") {
+			return "", false, false, errNoGitIdentity
+		}
+		return "", false, false, err
+	}
+	sha = strings.TrimSpace(string(out))
+	if _, err := srv.runTaxonomyGitInput(nil, nil, "update-ref", "-m", "taxonomy: "+message, "refs/heads/"+branch, sha, parent); err != nil {
+		return "", false, false, errBranchMoved
+	}
+	return sha, created, false, nil
+}
+
+// listTaxonomyTreeHandler handles GET /taxonomy/tree?branch={branch}.
+func (srv *ILabServer) listTaxonomyTreeHandler(w http.ResponseWriter, r *http.Request) {
+	branch := r.URL.Query().Get("branch")
+	srv.log.Infof("GET /taxonomy/tree called, branch=%q", branch)
+
+	ref, sha, err := srv.resolveTaxonomyRef(branch)
+	if err != nil {
+		srv.writeBranchError(w, branch, err)
+		return
+	}
+	roots := make([]string, 0, len(taxonomyRoots))
+	for root := range taxonomyRoots {
+		roots = append(roots, root)
+	}
+	out, err := srv.runTaxonomyGitInput(nil, nil, append([]string{"ls-tree", "-r", "-z", "--name-only", sha, "--"}, roots...)...)
+	if err != nil {
+		srv.log.Errorf("Error listing the taxonomy tree at %s: %v", sha, err)
+		http.Error(w, "Failed to list the taxonomy tree", http.StatusInternalServerError)
+		return
+	}
+
+	resp := TaxonomyTreeResponse{Branch: ref, CommitSHA: sha, Files: []TaxonomyTreeEntry{}}
+	for _, path := range strings.Split(string(out), "\x00") {
+		if kind, err := taxonomyFileKind(path); err == nil {
+			resp.Files = append(resp.Files, TaxonomyTreeEntry{Path: path, Kind: kind})
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+	srv.log.Infof("GET /taxonomy/tree returned %d files", len(resp.Files))
+}
+
+// getTaxonomyFileHandler handles GET /taxonomy/files/{path}?branch={branch}. The file is
+// returned with its validation result.
+func (srv *ILabServer) getTaxonomyFileHandler(w http.ResponseWriter, r *http.Request) {
+	path := mux.Vars(r)["path"]
+	branch := r.URL.Query().Get("branch")
+	srv.log.Infof("GET /taxonomy/files/%s called, branch=%q", path, branch)
+
+	kind, err := taxonomyFileKind(path)
+	if err != nil {
+		srv.writeTaxonomyFileError(w, path, err)
+		return
+	}
+	ref, sha, err := srv.resolveTaxonomyRef(branch)
+	if err != nil {
+		srv.writeBranchError(w, branch, err)
+		return
+	}
+	content, err := srv.readTaxonomyFile(sha, path)
+	if err != nil {
+		srv.writeTaxonomyFileError(w, path, err)
+		return
+	}
+
+	resp := TaxonomyFileResponse{
+		Path:      path,
+		Kind:      kind,
+		Branch:    ref,
+		CommitSHA: sha,
+		Content:   string(content),
+		Errors:    validateQnaYAML(path, kind, content),
+	}
+	if resp.Errors == nil {
+		resp.Errors = []QnaValidationError{}
+	}
+	resp.Valid = len(resp.Errors) == 0
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// putTaxonomyFileHandler handles PUT /taxonomy/files/{path}?dry_run=true. It validates the
+// content and commits it to the branch, creating or updating the file. Invalid content is
+// rejected with 422 and the validation errors; a dry run only validates.
+func (srv *ILabServer) putTaxonomyFileHandler(w http.ResponseWriter, r *http.Request) {
+	path := mux.Vars(r)["path"]
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	srv.log.Infof("PUT /taxonomy/files/%s called, dry_run=%v", path, dryRun)
+
+	kind, err := taxonomyFileKind(path)
+	if err != nil {
+		srv.writeTaxonomyFileError(w, path, err)
+		return
+	}
+	var req TaxonomyFileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := srv.validateBranch(req.Branch); err != nil {
+		srv.writeBranchError(w, req.Branch, err)
+		return
+	}
+	if len(req.Content) > maxQnaFileBytes {
+		http.Error(w, fmt.Sprintf("'content' exceeds %d bytes", maxQnaFileBytes), http.StatusBadRequest)
+		return
+	}
+	if req.CommitSHA != "" && !commitSHAPattern.MatchString(req.CommitSHA) {
+		http.Error(w, fmt.Sprintf("Invalid 'commit_sha' '%s'", req.CommitSHA), http.StatusBadRequest)
+		return
+	}
+	var identity []string
+	if req.AuthorName != "" || req.AuthorEmail != "" {
+		if req.AuthorName == "" || req.AuthorEmail == "" || strings.ContainsAny(req.AuthorName+req.AuthorEmail, "<>\n") || !strings.Contains(req.AuthorEmail, "@") {
+			http.Error(w, "'author_name' and 'author_email' must both be given, and the email must be an address", http.StatusBadRequest)
+			return
+		}
+		for _, role := range []string{"AUTHOR", "COMMITTER"} {
+			identity = append(identity, "GIT_"+role+"_NAME="+req.AuthorName, "GIT_"+role+"_EMAIL="+req.AuthorEmail)
+		}
+	}
+
+	resp := TaxonomyFileResponse{
+		Path:   path,
+		Kind:   kind,
+		Branch: req.Branch,
+		Errors: validateQnaYAML(path, kind, []byte(req.Content)),
+	}
+	if resp.Errors == nil {
+		resp.Errors = []QnaValidationError{}
+	}
+	resp.Valid = len(resp.Errors) == 0
+	if dryRun || !resp.Valid {
+		w.Header().Set("Content-Type", "application/json")
+		if !dryRun {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	// Committing moves the branch under the shared working copy if it is checked out there,
+	// leaving its files stale
+	if req.Branch == srv.currentTaxonomyBranch() {
+		http.Error(w, fmt.Sprintf("Branch '%s' is checked out in the taxonomy working copy; commit to another branch", req.Branch), http.StatusConflict)
+		return
+	}
+
+	sha, created, unchanged, err := srv.commitTaxonomyFile(req.Branch, path, []byte(req.Content), req.CommitSHA, req.Message, identity)
+	if err != nil {
+		srv.writeTaxonomyFileError(w, path, err)
+		return
+	}
+	resp.CommitSHA, resp.Created, resp.Unchanged = sha, created, unchanged
+	if unchanged {
+		srv.log.Infof("Taxonomy file '%s' on branch '%s' is unchanged", path, req.Branch)
+	} else {
+		srv.log.Infof("Committed taxonomy file '%s' to branch '%s' as %s", path, req.Branch, sha)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+// newTaxonomyTestRepo returns a server whose taxonomy is a new repository with one commit
+// on main.
+func newTaxonomyTestRepo(t *testing.T) *ILabServer {
+	t.Helper()
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "Initial commit"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	return &ILabServer{taxonomyPath: dir}
+}
+
+func TestCommitTaxonomyFileBase(t *testing.T) {
+	srv := newTaxonomyTestRepo(t)
+	identity := []string{
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+	}
+	const path = "knowledge/science/qna.yaml"
+	initial, err := srv.resolveBranchCommit("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An abbreviated commit_sha names the branch tip just like the full one
+	first, created, _, err := srv.commitTaxonomyFile("main", path, []byte("version: 3\n"), initial[:7], "", identity)
+	if err != nil {
+		t.Fatalf("commit based on %s: %v", initial[:7], err)
+	}
+	if !created {
+		t.Error("created = false for a new file")
+	}
+	if _, _, _, err := srv.commitTaxonomyFile("main", path, []byte("version: 3\ndomain: x\n"), first, "", identity); err != nil {
+		t.Fatalf("commit based on %s: %v", first, err)
+	}
+
+	for _, base := range []string{initial[:7], initial, first[:10], "0000000"} {
+		if _, _, _, err := srv.commitTaxonomyFile("main", path, []byte("version: 2\n"), base, "", identity); !errors.Is(err, errBranchMoved) {
+			t.Errorf("commit based on stale %s: got %v, want errBranchMoved", base, err)
+		}
+	}
+}
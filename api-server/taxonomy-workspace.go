@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// taxonomyWorktreesDir holds the per-job worktrees of the taxonomy repository, relative to
+// the instructlab data directory.
+const taxonomyWorktreesDir = "taxonomy-worktrees"
+
+// taxonomyWorktreePath returns the directory of a job's taxonomy worktree.
+func taxonomyWorktreePath(jobID string) (string, error) {
+	baseDataDir, err := getBaseDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(baseDataDir, taxonomyWorktreesDir, jobID), nil
+}
+
+// addTaxonomyWorktree checks out the current commit of branch into a worktree of its own for
+// jobID, writing the git output to log, and returns the worktree directory and the commit.
+// The worktree is detached, so concurrent jobs may use the same branch, and later commits to
+// the branch don't change the files under a running job. The shared working copy at
+// --taxonomy-path is left alone.
+func (srv *ILabServer) addTaxonomyWorktree(jobID, branch string, log io.Writer) (string, string, error) {
+	sha, err := srv.resolveBranchCommit(branch)
+	if err != nil {
+		return "", "", err
+	}
+	dir, err := taxonomyWorktreePath(jobID)
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), os.ModePerm); err != nil {
+		return "", "", fmt.Errorf("failed to create the taxonomy worktrees directory: %v", err)
+	}
+	out, err := srv.runTaxonomyGit("worktree", "add", "--detach", dir, sha)
+	fmt.Fprintf(log, "[GIT WORKTREE] %s: %s\n", branch, out)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create a worktree of branch '%s': %v", branch, err)
+	}
+	fmt.Fprintf(log, "[GIT WORKTREE] %s is at commit %s in %s\n", branch, sha, dir)
+	return dir, sha, nil
+}
+
+// removeTaxonomyWorktree removes a job's taxonomy worktree, if it has one.
+func (srv *ILabServer) removeTaxonomyWorktree(jobID string) {
+	dir, err := taxonomyWorktreePath(jobID)
+	if err != nil {
+		return
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return
+	}
+	if out, err := srv.runTaxonomyGit("worktree", "remove", "--force", dir); err != nil {
+		srv.log.Errorf("Error removing taxonomy worktree of job %s: %v: %s", jobID, err, out)
+		// Remove the files anyway; prune drops git's record of the worktree
+		_ = os.RemoveAll(dir)
+		_, _ = srv.runTaxonomyGit("worktree", "prune")
+		return
+	}
+	srv.log.Infof("Removed taxonomy worktree of job %s", jobID)
+}
+
+// cleanupTaxonomyWorktrees removes the worktrees left behind by jobs that are no longer
+// running, e.g. because the server stopped while they ran.
+func (srv *ILabServer) cleanupTaxonomyWorktrees() {
+	baseDataDir, err := getBaseDataDir()
+	if err != nil {
+		return
+	}
+	entries, err := os.ReadDir(filepath.Join(baseDataDir, taxonomyWorktreesDir))
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		job, err := srv.getJob(e.Name())
+		if err != nil {
+			srv.log.Errorf("Error looking up job %s: %v", e.Name(), err)
+			continue
+		}
+		if job == nil || job.Status != "running" {
+			srv.removeTaxonomyWorktree(e.Name())
+		}
+	}
+	_, _ = srv.runTaxonomyGit("worktree", "prune")
+}
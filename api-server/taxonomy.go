@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	errInvalidBranchName = errors.New("invalid branch name")
+	errBranchNotFound    = errors.New("branch not found")
+)
+
+// maxBranchNameLength bounds the branch names the server accepts.
+const maxBranchNameLength = 200
+
+var (
+	// branchNamePattern is deliberately stricter than git: names start with a letter or digit,
+	// so they can never be taken for a git option, and otherwise use only letters, digits,
+	// '_', '.', '/' and '-', none of which has meaning to git revision syntax or the shell.
+	branchNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_./-]*$`)
+	commitSHAPattern  = regexp.MustCompile(`^[0-9a-f]{7,64}$`)
+)
+
+// TaxonomyCommit is the last commit of a taxonomy branch.
+type TaxonomyCommit struct {
+	SHA     string    `json:"sha"`
+	Subject string    `json:"subject"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+}
+
+// TaxonomyBranch is a local branch of the taxonomy repository. Ahead and Behind count the
+// commits relative to the base branch, when there is one.
+type TaxonomyBranch struct {
+	Name    string         `json:"name"`
+	Current bool           `json:"current"`
+	Commit  TaxonomyCommit `json:"commit"`
+	Ahead   *int           `json:"ahead,omitempty"`
+	Behind  *int           `json:"behind,omitempty"`
+}
+
+// TaxonomyBranchesResponse is returned by GET /taxonomy/branches.
+type TaxonomyBranchesResponse struct {
+	Current  string           `json:"current,omitempty"`
+	Base     string           `json:"base,omitempty"`
+	Branches []TaxonomyBranch `json:"branches"`
+}
+
+// TaxonomyDiffSide is one of the jobs compared by GET /taxonomy/diff.
+type TaxonomyDiffSide struct {
+	JobID         string `json:"job_id"`
+	Branch        string `json:"branch,omitempty"`
+	CommitSHA     string `json:"commit_sha"`
+	TaxonomyDirty bool   `json:"taxonomy_dirty,omitempty"`
+}
+
+// TaxonomyFileChange is a qna.yaml file that differs between two commits.
+type TaxonomyFileChange struct {
+	Path   string `json:"path"`
+	Status string `json:"status"` // "added", "modified" or "deleted"
+}
+
+// TaxonomyDiffResponse is returned by GET /taxonomy/diff.
+type TaxonomyDiffResponse struct {
+	From  TaxonomyDiffSide     `json:"from"`
+	To    TaxonomyDiffSide     `json:"to"`
+	Files []TaxonomyFileChange `json:"files"`
+}
+
+// CreateBranchRequest is the body of POST /taxonomy/branches.
+type CreateBranchRequest struct {
+	Name string `json:"name"`
+	From string `json:"from,omitempty"` // branch or commit SHA; defaults to the base branch
+}
+
+// validBranchName reports whether name is acceptable as a taxonomy branch name.
+func validBranchName(name string) bool {
+	if len(name) > maxBranchNameLength || name == "HEAD" || !branchNamePattern.MatchString(name) {
+		return false
+	}
+	if strings.Contains(name, "..") || strings.Contains(name, "//") || strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || strings.HasSuffix(part, ".lock") {
+			return false
+		}
+	}
+	return true
+}
+
+// runTaxonomyGit runs a git command in the taxonomy repository and returns its combined
+// output. Commands run one at a time, so they don't fail on each other's git locks.
+func (srv *ILabServer) runTaxonomyGit(args ...string) (string, error) {
+	srv.taxonomyMu.Lock()
+	defer srv.taxonomyMu.Unlock()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = srv.taxonomyPath
+	out, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// runTaxonomyGitInput runs a git command in the taxonomy repository like runTaxonomyGit, with
+// stdin as its input and env added to its environment. It returns the untrimmed standard
+// output; the error includes the standard error.
+func (srv *ILabServer) runTaxonomyGitInput(stdin []byte, env []string, args ...string) ([]byte, error) {
+	srv.taxonomyMu.Lock()
+	defer srv.taxonomyMu.Unlock()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = srv.taxonomyPath
+	cmd.Env = append(os.Environ(), env...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return out, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// validateBranchName checks name against validBranchName and git's own ref name rules.
+func (srv *ILabServer) validateBranchName(name string) error {
+	if !validBranchName(name) {
+		return errInvalidBranchName
+	}
+	if _, err := srv.runTaxonomyGit("check-ref-format", "--branch", name); err != nil {
+		return errInvalidBranchName
+	}
+	return nil
+}
+
+// branchExists reports whether name is a local branch of the taxonomy repository.
+func (srv *ILabServer) branchExists(name string) bool {
+	_, err := srv.runTaxonomyGit("rev-parse", "--verify", "--quiet", "refs/heads/"+name)
+	return err == nil
+}
+
+// validateBranch checks that name is a valid name of an existing local branch of the
+// taxonomy repository.
+func (srv *ILabServer) validateBranch(name string) error {
+	if err := srv.validateBranchName(name); err != nil {
+		return err
+	}
+	if !srv.branchExists(name) {
+		return errBranchNotFound
+	}
+	return nil
+}
+
+// writeBranchError maps validateBranch errors to HTTP responses.
+func (srv *ILabServer) writeBranchError(w http.ResponseWriter, name string, err error) {
+	switch {
+	case errors.Is(err, errInvalidBranchName):
+		http.Error(w, fmt.Sprintf("Invalid branch name '%s'", name), http.StatusBadRequest)
+	case errors.Is(err, errBranchNotFound):
+		http.Error(w, fmt.Sprintf("Branch '%s' not found in the taxonomy repository", name), http.StatusNotFound)
+	default:
+		srv.log.Errorf("Error resolving branch '%s': %v", name, err)
+		http.Error(w, "Failed to resolve branch", http.StatusInternalServerError)
+	}
+}
+
+// currentTaxonomyBranch returns the checked out branch, or "" when HEAD is detached.
+func (srv *ILabServer) currentTaxonomyBranch() string {
+	out, err := srv.runTaxonomyGit("symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return out
+}
+
+// taxonomyBaseBranch returns the branch others are compared against: the remote's default
+// branch when known, otherwise a local "main" or "master".
+func (srv *ILabServer) taxonomyBaseBranch() string {
+	if out, err := srv.runTaxonomyGit("symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil && out != "" {
+		return out
+	}
+	for _, name := range []string{"main", "master"} {
+		if srv.branchExists(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// aheadBehind counts the commits of branch that base lacks and vice versa.
+func (srv *ILabServer) aheadBehind(base, branch string) (ahead, behind int, err error) {
+	out, err := srv.runTaxonomyGit("rev-list", "--left-right", "--count", base+"..."+branch, "--")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to compare '%s' with '%s': %v", branch, base, err)
+	}
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected rev-list output %q", out)
+	}
+	behind, _ = strconv.Atoi(fields[0])
+	ahead, _ = strconv.Atoi(fields[1])
+	return ahead, behind, nil
+}
+
+// listTaxonomyBranches returns the local branches of the taxonomy repository, with their
+// last commit and their distance from the base branch.
+func (srv *ILabServer) listTaxonomyBranches() (*TaxonomyBranchesResponse, error) {
+	out, err := srv.runTaxonomyGit("for-each-ref", "--sort=refname",
+		"--format=%(refname:short)%00%(objectname)%00%(subject)%00%(authorname)%00%(committerdate:iso-strict)",
+		"refs/heads")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %v: %s", err, out)
+	}
+	resp := &TaxonomyBranchesResponse{
+		Current:  srv.currentTaxonomyBranch(),
+		Base:     srv.taxonomyBaseBranch(),
+		Branches: []TaxonomyBranch{},
+	}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 5 {
+			continue
+		}
+		b := TaxonomyBranch{
+			Name:    fields[0],
+			Current: fields[0] == resp.Current,
+			Commit:  TaxonomyCommit{SHA: fields[1], Subject: fields[2], Author: fields[3]},
+		}
+		b.Commit.Date, _ = time.Parse(time.RFC3339, fields[4])
+		if resp.Base != "" {
+			if ahead, behind, err := srv.aheadBehind(resp.Base, b.Name); err == nil {
+				b.Ahead, b.Behind = &ahead, &behind
+			} else {
+				srv.log.Errorf("Error comparing branch '%s': %v", b.Name, err)
+			}
+		}
+		resp.Branches = append(resp.Branches, b)
+	}
+	return resp, nil
+}
+
+// branchInUse returns a running job that works on branch, if any.
+func (srv *ILabServer) branchInUse(branch string) (*Job, error) {
+	jobs, err := srv.listAllJobs()
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		if job.Status == "running" && job.Branch == branch {
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+// taxonomyWorkingCopyState returns the commit checked out in the shared taxonomy working
+// copy and whether the copy has uncommitted changes, counting untracked files.
+func (srv *ILabServer) taxonomyWorkingCopyState() (string, bool, error) {
+	sha, err := srv.runTaxonomyGit("rev-parse", "--verify", "HEAD^{commit}")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to resolve the taxonomy HEAD: %v: %s", err, sha)
+	}
+	status, err := srv.runTaxonomyGit("status", "--porcelain")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read the taxonomy status: %v: %s", err, status)
+	}
+	return sha, status != "", nil
+}
+
+// datasetTaxonomyCommit returns the taxonomy commit a training dataset came from, as recorded
+// by the generate job that wrote it. It returns "" when the dataset came from anywhere else:
+// where a branch points now says nothing about the data.
+func (srv *ILabServer) datasetTaxonomyCommit(dataPath string) (string, bool) {
+	if job := srv.datasetGenerateJob(dataPath); job != nil {
+		return job.CommitSHA, job.TaxonomyDirty
+	}
+	return "", false
+}
+
+// taxonomyDiffSide looks up a job compared by GET /taxonomy/diff.
+func (srv *ILabServer) taxonomyDiffSide(w http.ResponseWriter, param, jobID string) (*TaxonomyDiffSide, bool) {
+	if jobID == "" {
+		http.Error(w, fmt.Sprintf("Missing required parameter: %s", param), http.StatusBadRequest)
+		return nil, false
+	}
+	job, err := srv.getJob(jobID)
+	if err != nil {
+		srv.log.Errorf("Error retrieving job from DB: %v", err)
+		http.Error(w, "Failed to retrieve job", http.StatusInternalServerError)
+		return nil, false
+	}
+	if job == nil {
+		http.Error(w, fmt.Sprintf("Job '%s' not found", jobID), http.StatusNotFound)
+		return nil, false
+	}
+	if job.CommitSHA == "" {
+		http.Error(w, fmt.Sprintf("Job '%s' has no recorded taxonomy commit", jobID), http.StatusBadRequest)
+		return nil, false
+	}
+	if _, err := srv.runTaxonomyGit("cat-file", "-e", job.CommitSHA+"^{commit}"); err != nil {
+		http.Error(w, fmt.Sprintf("Commit %s of job '%s' is no longer in the taxonomy repository", job.CommitSHA, jobID), http.StatusNotFound)
+		return nil, false
+	}
+	return &TaxonomyDiffSide{JobID: job.JobID, Branch: job.Branch, CommitSHA: job.CommitSHA, TaxonomyDirty: job.TaxonomyDirty}, true
+}
+
+// listTaxonomyBranchesHandler handles GET /taxonomy/branches.
+func (srv *ILabServer) listTaxonomyBranchesHandler(w http.ResponseWriter, r *http.Request) {
+	srv.log.Info("GET /taxonomy/branches called")
+
+	resp, err := srv.listTaxonomyBranches()
+	if err != nil {
+		srv.log.Errorf("Error listing taxonomy branches: %v", err)
+		http.Error(w, "Failed to list taxonomy branches", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+	srv.log.Infof("GET /taxonomy/branches returned %d branches", len(resp.Branches))
+}
+
+// createTaxonomyBranchHandler handles POST /taxonomy/branches.
+func (srv *ILabServer) createTaxonomyBranchHandler(w http.ResponseWriter, r *http.Request) {
+	srv.log.Info("POST /taxonomy/branches called")
+
+	var req CreateBranchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := srv.validateBranchName(req.Name); err != nil {
+		srv.writeBranchError(w, req.Name, err)
+		return
+	}
+	if srv.branchExists(req.Name) {
+		http.Error(w, fmt.Sprintf("Branch '%s' already exists", req.Name), http.StatusConflict)
+		return
+	}
+
+	from := req.From
+	if from == "" {
+		from = srv.taxonomyBaseBranch()
+	}
+	if from == "" {
+		from = "HEAD"
+	} else if !validBranchName(from) && !commitSHAPattern.MatchString(from) {
+		http.Error(w, fmt.Sprintf("Invalid 'from' '%s' (expected a branch or commit SHA)", from), http.StatusBadRequest)
+		return
+	}
+	sha, err := srv.resolveBranchCommit(from)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("'%s' not found in the taxonomy repository", from), http.StatusNotFound)
+		return
+	}
+
+	if out, err := srv.runTaxonomyGit("branch", "--no-track", req.Name, sha); err != nil {
+		srv.log.Errorf("Error creating branch '%s': %v: %s", req.Name, err, out)
+		http.Error(w, fmt.Sprintf("Failed to create branch '%s'", req.Name), http.StatusInternalServerError)
+		return
+	}
+	srv.log.Infof("Created taxonomy branch '%s' at %s (from '%s')", req.Name, sha, from)
+
+	resp, err := srv.listTaxonomyBranches()
+	if err != nil {
+		srv.log.Errorf("Error listing taxonomy branches: %v", err)
+		http.Error(w, "Failed to list taxonomy branches", http.StatusInternalServerError)
+		return
+	}
+	for _, b := range resp.Branches {
+		if b.Name == req.Name {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(b)
+			return
+		}
+	}
+	http.Error(w, "Failed to read the created branch", http.StatusInternalServerError)
+}
+
+// deleteTaxonomyBranchHandler handles DELETE /taxonomy/branches/{name}?force=true. The base
+// branch and branches that are checked out or used by a running job cannot be deleted, and
+// unmerged branches only with force.
+func (srv *ILabServer) deleteTaxonomyBranchHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	force := r.URL.Query().Get("force") == "true"
+	srv.log.Infof("DELETE /taxonomy/branches/%s called, force=%v", name, force)
+
+	if err := srv.validateBranch(name); err != nil {
+		srv.writeBranchError(w, name, err)
+		return
+	}
+	if name == srv.currentTaxonomyBranch() {
+		http.Error(w, fmt.Sprintf("Branch '%s' is checked out", name), http.StatusConflict)
+		return
+	}
+	if name == srv.taxonomyBaseBranch() {
+		http.Error(w, fmt.Sprintf("Branch '%s' is the base branch", name), http.StatusConflict)
+		return
+	}
+	job, err := srv.branchInUse(name)
+	if err != nil {
+		srv.log.Errorf("Error listing jobs: %v", err)
+		http.Error(w, "Failed to list jobs", http.StatusInternalServerError)
+		return
+	}
+	if job != nil {
+		http.Error(w, fmt.Sprintf("Branch '%s' is in use by running job %s", name, job.JobID), http.StatusConflict)
+		return
+	}
+
+	flag := "-d"
+	if force {
+		flag = "-D"
+	}
+	if out, err := srv.runTaxonomyGit("branch", flag, name); err != nil {
+		if strings.Contains(out, "not fully merged") {
+			http.Error(w, fmt.Sprintf("Branch '%s' is not fully merged; pass force=true to delete it anyway", name), http.StatusConflict)
+			return
+		}
+		srv.log.Errorf("Error deleting branch '%s': %v: %s", name, err, out)
+		http.Error(w, fmt.Sprintf("Failed to delete branch '%s'", name), http.StatusInternalServerError)
+		return
+	}
+	srv.log.Infof("Deleted taxonomy branch '%s'", name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// taxonomyDiffHandler handles GET /taxonomy/diff?from={job_id}&to={job_id}. It lists the
+// qna.yaml files that changed between the taxonomy commits two jobs recorded. Uncommitted
+// changes a job read are not part of the diff; taxonomy_dirty flags them.
+func (srv *ILabServer) taxonomyDiffHandler(w http.ResponseWriter, r *http.Request) {
+	fromID, toID := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	srv.log.Infof("GET /taxonomy/diff called, from=%q, to=%q", fromID, toID)
+
+	from, ok := srv.taxonomyDiffSide(w, "from", fromID)
+	if !ok {
+		return
+	}
+	to, ok := srv.taxonomyDiffSide(w, "to", toID)
+	if !ok {
+		return
+	}
+
+	out, err := srv.runTaxonomyGit("diff", "--name-status", "--no-renames", "-z", from.CommitSHA, to.CommitSHA, "--", "qna.yaml", "*/qna.yaml")
+	if err != nil {
+		srv.log.Errorf("Error comparing %s with %s: %v: %s", from.CommitSHA, to.CommitSHA, err, out)
+		http.Error(w, "Failed to compare taxonomy commits", http.StatusInternalServerError)
+		return
+	}
+	resp := TaxonomyDiffResponse{From: *from, To: *to, Files: []TaxonomyFileChange{}}
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		change := TaxonomyFileChange{Path: fields[i+1]}
+		switch fields[i] {
+		case "A":
+			change.Status = "added"
+		case "D":
+			change.Status = "deleted"
+		default:
+			change.Status = "modified"
+		}
+		resp.Files = append(resp.Files, change)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+	srv.log.Infof("GET /taxonomy/diff returned %d changed files", len(resp.Files))
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidBranchName(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		valid bool
+	}{
+		{"main", true},
+		{"my_branch", true},
+		{"feature/my-knowledge_v2", true},
+		{"release-1.0", true},
+		{"_leading_underscore", false},
+		{"-n", false},
+		{"--output=x", false},
+		{".hidden", false},
+		{"HEAD", false},
+		{"a..b", false},
+		{"a//b", false},
+		{"trailing/", false},
+		{"trailing.", false},
+		{"topic/.dot", false},
+		{"name.lock", false},
+		{"has space", false},
+		{"tilde~1", false},
+		{"caret^", false},
+		{"colon:x", false},
+		{"", false},
+		{strings.Repeat("a", maxBranchNameLength), true},
+		{strings.Repeat("a", maxBranchNameLength+1), false},
+	} {
+		if got := validBranchName(tc.name); got != tc.valid {
+			t.Errorf("validBranchName(%q) = %t, want %t", tc.name, got, tc.valid)
+		}
+	}
+}
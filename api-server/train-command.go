@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Platform profiles that select how "ilab model train" is invoked.
+const (
+	trainProfileRHELAI = "rhelai"
+	trainProfileMPS    = "mps"
+	trainProfileCUDA   = "cuda"
+	trainProfileCPU    = "cpu"
+)
+
+// TrainCommand is the input to a TrainCommandBuilder.
+type TrainCommand struct {
+	ModelPath string
+	DataPath  string
+	// OutputDir is the directory the job writes its checkpoints and metrics to; empty
+	// keeps ilab's shared default.
+	OutputDir string
+	Pipeline  string
+	Spec      *TrainingSpec
+}
+
+// TrainCommandBuilder builds the "ilab model train" arguments for one platform profile.
+type TrainCommandBuilder interface {
+	// ApplyDefaults fills in the profile's defaults for fields the spec leaves unset.
+	ApplyDefaults(spec *TrainingSpec)
+	// Args returns the arguments for a command whose spec has had its defaults applied.
+	Args(tc TrainCommand) []string
+}
+
+// trainCommandBuilders are the builders per platform profile; add an entry here to
+// support another platform.
+var trainCommandBuilders = map[string]TrainCommandBuilder{
+	trainProfileRHELAI: rhelaiTrainBuilder{},
+	trainProfileMPS:    localTrainBuilder{device: "mps"},
+	trainProfileCUDA:   localTrainBuilder{device: "cuda"},
+	trainProfileCPU:    localTrainBuilder{},
+}
+
+// rhelaiTrainBuilder trains on a RHEL AI multi-GPU host. The dataset is always passed
+// explicitly, and the batch, GPU and sample defaults RHEL AI has always used apply.
+type rhelaiTrainBuilder struct{}
+
+func (rhelaiTrainBuilder) ApplyDefaults(spec *TrainingSpec) {
+	if spec.MaxBatchLen == nil {
+		n := rhelaiDefaultMaxBatchLen
+		spec.MaxBatchLen = &n
+	}
+	if spec.GPUs == nil {
+		n := rhelaiDefaultGPUs
+		spec.GPUs = &n
+	}
+	if spec.SaveSamples == nil {
+		n := rhelaiDefaultSaveSamples
+		spec.SaveSamples = &n
+	}
+}
+
+func (rhelaiTrainBuilder) Args(tc TrainCommand) []string {
+	args := []string{
+		"model", "train",
+		fmt.Sprintf("--data-path=%s", tc.DataPath),
+		"--device=cuda",
+		fmt.Sprintf("--model-path=%s", tc.ModelPath),
+		"--pipeline", tc.Pipeline,
+	}
+	args = appendOutputDirArg(args, tc.OutputDir)
+	args = append(args, tc.Spec.ilabArgs()...)
+	return appendEpochsArg(args, tc.Spec)
+}
+
+// localTrainBuilder trains in the server's own ilab venv on a single device: MPS on
+// macOS, CUDA, or the CPU when device is empty.
+type localTrainBuilder struct {
+	device string
+}
+
+func (localTrainBuilder) ApplyDefaults(*TrainingSpec) {}
+
+func (b localTrainBuilder) Args(tc TrainCommand) []string {
+	args := []string{"model", "train"}
+	if tc.Pipeline == "simple" {
+		// The simple pipeline trains a GGUF model and takes none of the spec's tuning flags
+		args = append(args, "--pipeline", tc.Pipeline)
+		if tc.Spec.OptimizeMemory == nil || *tc.Spec.OptimizeMemory {
+			args = append(args, "--optimize-memory")
+		}
+		args = append(args, fmt.Sprintf("--gguf-model-path=%s", tc.ModelPath))
+		args = b.appendDevice(args)
+	} else {
+		if tc.Pipeline != "" {
+			args = append(args, "--pipeline", tc.Pipeline)
+		}
+		args = append(args, fmt.Sprintf("--model-path=%s", tc.ModelPath))
+		args = b.appendDevice(args)
+		args = appendOutputDirArg(args, tc.OutputDir)
+		args = append(args, tc.Spec.ilabArgs()...)
+	}
+	args = appendEpochsArg(args, tc.Spec)
+	if tc.DataPath != "" {
+		args = append(args, fmt.Sprintf("--data-path=%s", tc.DataPath))
+	}
+	return args
+}
+
+func (b localTrainBuilder) appendDevice(args []string) []string {
+	if b.device == "" {
+		return args
+	}
+	return append(args, fmt.Sprintf("--device=%s", b.device))
+}
+
+func appendOutputDirArg(args []string, outputDir string) []string {
+	if outputDir != "" {
+		args = append(args, fmt.Sprintf("--ckpt-output-dir=%s", outputDir))
+	}
+	return args
+}
+
+func appendEpochsArg(args []string, spec *TrainingSpec) []string {
+	if spec.NumEpochs != nil {
+		args = append(args, fmt.Sprintf("--num-epochs=%d", *spec.NumEpochs))
+	}
+	return args
+}
+
+// writesHFCheckpoints reports whether training saves Hugging Face checkpoints; the simple
+// pipeline outside RHEL AI trains a GGUF model instead.
+func (srv *ILabServer) writesHFCheckpoints() bool {
+	return srv.pipelineType != "simple" || srv.trainProfile() == trainProfileRHELAI
+}
+
+// trainProfileNames lists the known platform profiles, sorted.
+func trainProfileNames() []string {
+	names := make([]string, 0, len(trainCommandBuilders))
+	for name := range trainCommandBuilders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// trainProfile returns the platform profile training runs with: --train-profile when set,
+// otherwise one derived from --rhelai, --osx and --cuda.
+func (srv *ILabServer) trainProfile() string {
+	switch {
+	case srv.trainProfileName != "":
+		return srv.trainProfileName
+	case srv.rhelai:
+		return trainProfileRHELAI
+	case srv.isOSX:
+		return trainProfileMPS
+	case srv.isCuda:
+		return trainProfileCUDA
+	default:
+		return trainProfileCPU
+	}
+}
+
+// TrainJobRequest describes a training job to start.
+type TrainJobRequest struct {
+	ModelName  string
+	BranchName string
+	Spec       *TrainingSpec
+	DataPath   string
+	// ResumeFrom is a checkpoint to continue training from instead of ModelName.
+	ResumeFrom *CheckpointInfo
+	// ParentJobID links the job to the job that started it. It defaults to the job that
+	// wrote ResumeFrom.
+	ParentJobID string
+	// Phase names the phase of a phased training job.
+	Phase string
+}
+
+// TrainCommandPlan is a fully resolved training command, as run by startTrainJob and
+// returned by POST /model/train?dry_run=true.
+type TrainCommandPlan struct {
+	Profile   string        `json:"profile"`
+	Cmd       string        `json:"cmd"`
+	Args      []string      `json:"args"`
+	Command   string        `json:"command"`
+	Dir       string        `json:"dir,omitempty"`
+	Dataset   string        `json:"dataset,omitempty"`
+	OutputDir string        `json:"output_dir,omitempty"`
+	TrainSpec *TrainingSpec `json:"train_spec"`
+	// ResumeCheckpoint is set when resuming from a checkpoint.
+	ResumeCheckpoint string `json:"resume_checkpoint,omitempty"`
+	ParentJobID      string `json:"parent_job_id,omitempty"`
+	// CommitSHA is the taxonomy commit the dataset came from (see datasetTaxonomyCommit).
+	CommitSHA     string `json:"commit_sha,omitempty"`
+	TaxonomyDirty bool   `json:"taxonomy_dirty,omitempty"`
+}
+
+// dryRunJobID stands in for the ID of a job that a dry run does not start.
+const dryRunJobID = "<job_id>"
+
+// buildTrainCommand resolves the command for the training job jobID. It has no side
+// effects, so it also serves dry runs, which pass dryRunJobID. RHEL AI falls back to the
+// latest dataset when none is given.
+func (srv *ILabServer) buildTrainCommand(req *TrainJobRequest, jobID string) (*TrainCommandPlan, error) {
+	profile := srv.trainProfile()
+	builder, ok := trainCommandBuilders[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown training profile '%s'", profile)
+	}
+
+	// A checkpoint is a complete model, so resuming trains it in place of the base model
+	var modelPath string
+	if req.ResumeFrom != nil {
+		modelPath = req.ResumeFrom.Path
+	} else {
+		fullModelPath, err := getFullModelPath(req.ModelName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get full model path: %v", err)
+		}
+		modelPath = fullModelPath
+	}
+
+	dataPath := req.DataPath
+
+	if profile == trainProfileRHELAI && dataPath == "" {
+		latestDataset, err := srv.getLatestDatasetFile()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest dataset file: %v", err)
+		}
+		dataPath = latestDataset
+		srv.log.Infof("No dataset specified; using the latest dataset: %s", dataPath)
+	}
+
+	// Work on a copy so the caller's spec (e.g. a preset) keeps its unset fields
+	effective := &TrainingSpec{}
+	if req.Spec != nil {
+		*effective = *req.Spec
+	}
+	builder.ApplyDefaults(effective)
+
+	// Each job writes to its own output directory, so concurrent runs cannot claim each
+	// other's checkpoints and a resumed job (e.g. the skills phase) cannot overwrite the
+	// checkpoint it continues from
+	var outputDir string
+	if srv.writesHFCheckpoints() {
+		dir, err := getTrainOutputDir(jobID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get output directory: %v", err)
+		}
+		outputDir = dir
+	}
+
+	ilabPath := srv.getIlabCommand()
+	args := builder.Args(TrainCommand{
+		ModelPath: modelPath,
+		DataPath:  dataPath,
+		OutputDir: outputDir,
+		Pipeline:  srv.pipelineType,
+		Spec:      effective,
+	})
+	plan := &TrainCommandPlan{
+		Profile:   profile,
+		Cmd:       ilabPath,
+		Args:      args,
+		Command:   strings.Join(append([]string{ilabPath}, args...), " "),
+		Dataset:   dataPath,
+		OutputDir: outputDir,
+		TrainSpec: effective,
+	}
+	if !srv.rhelai {
+		plan.Dir = srv.baseDir
+	}
+	plan.CommitSHA, plan.TaxonomyDirty = srv.datasetTaxonomyCommit(dataPath)
+	plan.ParentJobID = req.ParentJobID
+	if req.ResumeFrom != nil {
+		plan.ResumeCheckpoint = req.ResumeFrom.Name
+		if plan.ParentJobID == "" {
+			plan.ParentJobID = req.ResumeFrom.JobID
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrainCommandBuilderArgs(t *testing.T) {
+	lr := 2e-5
+	noOptimize := false
+	for _, tc := range []struct {
+		name    string
+		profile string
+		command TrainCommand
+		want    []string
+	}{
+		{
+			name:    "rhelai accelerated with defaults",
+			profile: trainProfileRHELAI,
+			command: TrainCommand{
+				ModelPath: "/models/granite",
+				DataPath:  "/data/train.jsonl",
+				OutputDir: "/ckpt/jobs/t-1",
+				Pipeline:  "accelerated",
+				Spec:      &TrainingSpec{NumEpochs: intPtr(3)},
+			},
+			want: []string{
+				"model", "train",
+				"--data-path=/data/train.jsonl",
+				"--device=cuda",
+				"--model-path=/models/granite",
+				"--pipeline", "accelerated",
+				"--ckpt-output-dir=/ckpt/jobs/t-1",
+				"--max-batch-len=5000",
+				"--save-samples=1000",
+				"--gpus=4",
+				"--num-epochs=3",
+			},
+		},
+		{
+			name:    "rhelai spec overrides defaults",
+			profile: trainProfileRHELAI,
+			command: TrainCommand{
+				ModelPath: "/models/granite",
+				DataPath:  "/data/train.jsonl",
+				OutputDir: "/ckpt/jobs/t-1",
+				Pipeline:  "simple",
+				Spec:      &TrainingSpec{LearningRate: &lr, GPUs: intPtr(8), SaveSamples: intPtr(0)},
+			},
+			want: []string{
+				"model", "train",
+				"--data-path=/data/train.jsonl",
+				"--device=cuda",
+				"--model-path=/models/granite",
+				"--pipeline", "simple",
+				"--ckpt-output-dir=/ckpt/jobs/t-1",
+				"--learning-rate=2e-05",
+				"--max-batch-len=5000",
+				"--save-samples=0",
+				"--gpus=8",
+			},
+		},
+		{
+			name:    "mps simple",
+			profile: trainProfileMPS,
+			command: TrainCommand{
+				ModelPath: "/models/granite.gguf",
+				DataPath:  "/data/train.jsonl",
+				Pipeline:  "simple",
+				Spec:      &TrainingSpec{NumEpochs: intPtr(2), LearningRate: &lr},
+			},
+			want: []string{
+				"model", "train",
+				"--pipeline", "simple",
+				"--optimize-memory",
+				"--gguf-model-path=/models/granite.gguf",
+				"--device=mps",
+				"--num-epochs=2",
+				"--data-path=/data/train.jsonl",
+			},
+		},
+		{
+			name:    "mps simple without memory optimization",
+			profile: trainProfileMPS,
+			command: TrainCommand{
+				ModelPath: "/models/granite.gguf",
+				Pipeline:  "simple",
+				Spec:      &TrainingSpec{OptimizeMemory: &noOptimize},
+			},
+			want: []string{
+				"model", "train",
+				"--pipeline", "simple",
+				"--gguf-model-path=/models/granite.gguf",
+				"--device=mps",
+			},
+		},
+		{
+			name:    "cuda full",
+			profile: trainProfileCUDA,
+			command: TrainCommand{
+				ModelPath: "/models/granite",
+				DataPath:  "/data/train.jsonl",
+				OutputDir: "/ckpt/jobs/t-2",
+				Pipeline:  "full",
+				Spec:      &TrainingSpec{NumEpochs: intPtr(5), EffectiveBatchSize: intPtr(64), LoraRank: intPtr(4)},
+			},
+			want: []string{
+				"model", "train",
+				"--pipeline", "full",
+				"--model-path=/models/granite",
+				"--device=cuda",
+				"--ckpt-output-dir=/ckpt/jobs/t-2",
+				"--effective-batch-size=64",
+				"--lora-rank=4",
+				"--num-epochs=5",
+				"--data-path=/data/train.jsonl",
+			},
+		},
+		{
+			name:    "cpu full without pipeline",
+			profile: trainProfileCPU,
+			command: TrainCommand{
+				ModelPath: "/models/granite",
+				OutputDir: "/ckpt/jobs/t-3",
+				Spec:      &TrainingSpec{MaxSeqLen: intPtr(4096)},
+			},
+			want: []string{
+				"model", "train",
+				"--model-path=/models/granite",
+				"--ckpt-output-dir=/ckpt/jobs/t-3",
+				"--max-seq-len=4096",
+			},
+		},
+		{
+			name:    "cpu simple",
+			profile: trainProfileCPU,
+			command: TrainCommand{
+				ModelPath: "/models/granite.gguf",
+				DataPath:  "/data/train.jsonl",
+				Pipeline:  "simple",
+				Spec:      &TrainingSpec{NumEpochs: intPtr(1)},
+			},
+			want: []string{
+				"model", "train",
+				"--pipeline", "simple",
+				"--optimize-memory",
+				"--gguf-model-path=/models/granite.gguf",
+				"--num-epochs=1",
+				"--data-path=/data/train.jsonl",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := trainCommandBuilders[tc.profile]
+			builder.ApplyDefaults(tc.command.Spec)
+			got := builder.Args(tc.command)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("args:\n got  %q\n want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTrainProfileNames(t *testing.T) {
+	want := []string{trainProfileCPU, trainProfileCUDA, trainProfileMPS, trainProfileRHELAI}
+	if got := trainProfileNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("trainProfileNames() = %q, want %q", got, want)
+	}
+}
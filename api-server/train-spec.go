@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Defaults the RHEL AI training command has always used when the spec doesn't say otherwise.
+const (
+	rhelaiDefaultMaxBatchLen = 5000
+	rhelaiDefaultGPUs        = 4
+	rhelaiDefaultSaveSamples = 1000
+)
+
+// TrainingSpec holds the training hyperparameters of a job. Unset fields use ilab's defaults
+// (or the platform defaults above); the effective spec is stored on the job.
+type TrainingSpec struct {
+	NumEpochs          *int     `json:"num_epochs,omitempty"`
+	LearningRate       *float64 `json:"learning_rate,omitempty"`
+	EffectiveBatchSize *int     `json:"effective_batch_size,omitempty"`
+	MaxSeqLen          *int     `json:"max_seq_len,omitempty"`
+	MaxBatchLen        *int     `json:"max_batch_len,omitempty"`
+	WarmupSteps        *int     `json:"warmup_steps,omitempty"`
+	// SaveSamples is the checkpoint interval in samples; 0 disables interval checkpoints
+	SaveSamples       *int  `json:"save_samples,omitempty"`
+	CheckpointAtEpoch *bool `json:"checkpoint_at_epoch,omitempty"`
+
+	// LoRA; a rank of 0 trains all weights
+	LoraRank          *int     `json:"lora_rank,omitempty"`
+	LoraAlpha         *int     `json:"lora_alpha,omitempty"`
+	LoraDropout       *float64 `json:"lora_dropout,omitempty"`
+	LoraQuantizeDtype string   `json:"lora_quantize_dtype,omitempty"`
+
+	// Distributed training
+	GPUs                 *int   `json:"gpus,omitempty"`
+	DistributedBackend   string `json:"distributed_backend,omitempty"`
+	FSDPShardingStrategy string `json:"fsdp_sharding_strategy,omitempty"`
+	CPUOffloadOptimizer  *bool  `json:"cpu_offload_optimizer,omitempty"`
+
+	// OptimizeMemory applies to the simple pipeline only
+	OptimizeMemory *bool `json:"optimize_memory,omitempty"`
+}
+
+// TrainingPreset is a named, saved TrainingSpec.
+type TrainingPreset struct {
+	Name      string       `json:"name"`
+	Spec      TrainingSpec `json:"spec"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+var errTrainingPresetNotFound = errors.New("training preset not found")
+
+var fsdpShardingStrategies = map[string]bool{
+	"FULL_SHARD": true, "SHARD_GRAD_OP": true, "NO_SHARD": true, "HYBRID_SHARD": true, "_HYBRID_SHARD_ZERO2": true,
+}
+
+// validate checks the ranges and combinations of the spec. It returns an error suitable for
+// a 400 response.
+func (s *TrainingSpec) validate() error {
+	intRanges := []struct {
+		name     string
+		value    *int
+		min, max int
+	}{
+		{"num_epochs", s.NumEpochs, 1, 100},
+		{"effective_batch_size", s.EffectiveBatchSize, 1, 65536},
+		{"max_seq_len", s.MaxSeqLen, 64, 131072},
+		{"max_batch_len", s.MaxBatchLen, 64, 10000000},
+		{"warmup_steps", s.WarmupSteps, 0, 1000000},
+		{"save_samples", s.SaveSamples, 0, 100000000},
+		{"lora_rank", s.LoraRank, 0, 1024},
+		{"lora_alpha", s.LoraAlpha, 1, 4096},
+		{"gpus", s.GPUs, 1, 64},
+	}
+	for _, r := range intRanges {
+		if r.value != nil && (*r.value < r.min || *r.value > r.max) {
+			return fmt.Errorf("'%s' must be between %d and %d", r.name, r.min, r.max)
+		}
+	}
+	if s.LearningRate != nil && (*s.LearningRate <= 0 || *s.LearningRate > 1) {
+		return fmt.Errorf("'learning_rate' must be greater than 0 and at most 1")
+	}
+	if s.LoraDropout != nil && (*s.LoraDropout < 0 || *s.LoraDropout >= 1) {
+		return fmt.Errorf("'lora_dropout' must be at least 0 and less than 1")
+	}
+	if s.LoraQuantizeDtype != "" && s.LoraQuantizeDtype != "nf4" {
+		return fmt.Errorf("'lora_quantize_dtype' must be 'nf4'")
+	}
+	loraRank := 0
+	if s.LoraRank != nil {
+		loraRank = *s.LoraRank
+	}
+	if loraRank == 0 && (s.LoraAlpha != nil || s.LoraDropout != nil || s.LoraQuantizeDtype != "") {
+		return fmt.Errorf("LoRA options require a 'lora_rank' greater than 0")
+	}
+	if s.MaxSeqLen != nil && s.MaxBatchLen != nil && *s.MaxBatchLen < *s.MaxSeqLen {
+		return fmt.Errorf("'max_batch_len' must be at least 'max_seq_len'")
+	}
+	switch s.DistributedBackend {
+	case "", "fsdp", "deepspeed":
+	default:
+		return fmt.Errorf("'distributed_backend' must be 'fsdp' or 'deepspeed'; got '%s'", s.DistributedBackend)
+	}
+	if s.FSDPShardingStrategy != "" {
+		if s.DistributedBackend == "deepspeed" {
+			return fmt.Errorf("'fsdp_sharding_strategy' requires the 'fsdp' distributed backend")
+		}
+		if !fsdpShardingStrategies[s.FSDPShardingStrategy] {
+			return fmt.Errorf("invalid 'fsdp_sharding_strategy' '%s'", s.FSDPShardingStrategy)
+		}
+	}
+	return nil
+}
+
+// unsupportedBySimplePipeline reports the spec fields the simple pipeline cannot honour.
+func (s *TrainingSpec) unsupportedBySimplePipeline() []string {
+	var fields []string
+	add := func(set bool, name string) {
+		if set {
+			fields = append(fields, name)
+		}
+	}
+	add(s.LearningRate != nil, "learning_rate")
+	add(s.EffectiveBatchSize != nil, "effective_batch_size")
+	add(s.MaxSeqLen != nil, "max_seq_len")
+	add(s.MaxBatchLen != nil, "max_batch_len")
+	add(s.WarmupSteps != nil, "warmup_steps")
+	add(s.SaveSamples != nil, "save_samples")
+	add(s.CheckpointAtEpoch != nil, "checkpoint_at_epoch")
+	add(s.LoraRank != nil, "lora_rank")
+	add(s.GPUs != nil, "gpus")
+	add(s.DistributedBackend != "", "distributed_backend")
+	add(s.FSDPShardingStrategy != "", "fsdp_sharding_strategy")
+	add(s.CPUOffloadOptimizer != nil, "cpu_offload_optimizer")
+	return fields
+}
+
+// mergeTrainingSpecs overlays the fields set in override onto base.
+func mergeTrainingSpecs(base, override *TrainingSpec) (*TrainingSpec, error) {
+	merged := make(map[string]json.RawMessage)
+	for _, s := range []*TrainingSpec{base, override} {
+		if s == nil {
+			continue
+		}
+		data, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		for k, v := range fields {
+			merged[k] = v
+		}
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	spec := &TrainingSpec{}
+	return spec, json.Unmarshal(data, spec)
+}
+
+// ilabArgs returns the "ilab model train" flags for the set fields of the spec, except
+// num_epochs and optimize_memory, which every pipeline handles itself.
+func (s *TrainingSpec) ilabArgs() []string {
+	var args []string
+	if s.LearningRate != nil {
+		args = append(args, fmt.Sprintf("--learning-rate=%g", *s.LearningRate))
+	}
+	if s.EffectiveBatchSize != nil {
+		args = append(args, fmt.Sprintf("--effective-batch-size=%d", *s.EffectiveBatchSize))
+	}
+	if s.MaxSeqLen != nil {
+		args = append(args, fmt.Sprintf("--max-seq-len=%d", *s.MaxSeqLen))
+	}
+	if s.MaxBatchLen != nil {
+		args = append(args, fmt.Sprintf("--max-batch-len=%d", *s.MaxBatchLen))
+	}
+	if s.WarmupSteps != nil {
+		args = append(args, fmt.Sprintf("--warmup-steps=%d", *s.WarmupSteps))
+	}
+	if s.SaveSamples != nil {
+		args = append(args, fmt.Sprintf("--save-samples=%d", *s.SaveSamples))
+	}
+	if s.CheckpointAtEpoch != nil {
+		if *s.CheckpointAtEpoch {
+			args = append(args, "--checkpoint-at-epoch")
+		} else {
+			args = append(args, "--no-checkpoint-at-epoch")
+		}
+	}
+	if s.LoraRank != nil {
+		args = append(args, fmt.Sprintf("--lora-rank=%d", *s.LoraRank))
+	}
+	if s.LoraAlpha != nil {
+		args = append(args, fmt.Sprintf("--lora-alpha=%d", *s.LoraAlpha))
+	}
+	if s.LoraDropout != nil {
+		args = append(args, fmt.Sprintf("--lora-dropout=%g", *s.LoraDropout))
+	}
+	if s.LoraQuantizeDtype != "" {
+		args = append(args, fmt.Sprintf("--lora-quantize-dtype=%s", s.LoraQuantizeDtype))
+	}
+	if s.GPUs != nil {
+		args = append(args, fmt.Sprintf("--gpus=%d", *s.GPUs))
+	}
+	if s.DistributedBackend != "" {
+		args = append(args, fmt.Sprintf("--distributed-backend=%s", s.DistributedBackend))
+	}
+	if s.FSDPShardingStrategy != "" {
+		args = append(args, fmt.Sprintf("--fsdp-sharding-strategy=%s", s.FSDPShardingStrategy))
+	}
+	if s.CPUOffloadOptimizer != nil && *s.CPUOffloadOptimizer {
+		if s.DistributedBackend == "deepspeed" {
+			args = append(args, "--deepspeed-cpu-offload-optimizer=true")
+		} else {
+			args = append(args, "--fsdp-cpu-offload-optimizer=true")
+		}
+	}
+	return args
+}
+
+// decodeHyperparameters decodes the "hyperparameters" of a training request, rejecting
+// unknown fields like PUT /model/train/presets does. An absent or null value yields nil.
+func decodeHyperparameters(raw json.RawMessage) (*TrainingSpec, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var spec TrainingSpec
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid 'hyperparameters': %v", err)
+	}
+	return &spec, nil
+}
+
+// resolveTrainingSpec builds the spec of a training request: the named preset, if any,
+// overlaid with the request's hyperparameters and its "epochs" shorthand. The result is
+// validated, including against what the configured pipeline supports.
+func (srv *ILabServer) resolveTrainingSpec(presetName string, hyperparameters json.RawMessage, epochs *int) (*TrainingSpec, error) {
+	overrides, err := decodeHyperparameters(hyperparameters)
+	if err != nil {
+		return nil, err
+	}
+	var base *TrainingSpec
+	if presetName != "" {
+		preset, err := srv.getTrainingPreset(presetName)
+		if err != nil {
+			return nil, err
+		}
+		if preset == nil {
+			return nil, fmt.Errorf("%w: '%s'", errTrainingPresetNotFound, presetName)
+		}
+		base = &preset.Spec
+	}
+	spec, err := mergeTrainingSpecs(base, overrides)
+	if err != nil {
+		return nil, err
+	}
+	if epochs != nil {
+		if overrides != nil && overrides.NumEpochs != nil && *overrides.NumEpochs != *epochs {
+			return nil, fmt.Errorf("'epochs' and 'hyperparameters.num_epochs' disagree")
+		}
+		spec.NumEpochs = epochs
+	}
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	if srv.pipelineType == "simple" && !srv.rhelai {
+		if fields := spec.unsupportedBySimplePipeline(); len(fields) > 0 {
+			return nil, fmt.Errorf("not supported by the simple pipeline: %s", strings.Join(fields, ", "))
+		}
+	}
+	return spec, nil
+}
+
+// marshalTrainingSpec encodes a spec for the jobs table.
+func marshalTrainingSpec(spec *TrainingSpec) (sql.NullString, error) {
+	if spec == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("failed to marshal training spec: %v", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// unmarshalTrainingSpec decodes a spec stored by marshalTrainingSpec.
+func unmarshalTrainingSpec(value sql.NullString) *TrainingSpec {
+	if !value.Valid || value.String == "" {
+		return nil
+	}
+	var spec TrainingSpec
+	if json.Unmarshal([]byte(value.String), &spec) != nil {
+		return nil
+	}
+	return &spec
+}
+
+// getTrainingPreset loads a preset; it returns nil if none exists with that name.
+func (srv *ILabServer) getTrainingPreset(name string) (*TrainingPreset, error) {
+	var spec, createdAt, updatedAt string
+	err := srv.db.QueryRow("SELECT spec, created_at, updated_at FROM training_presets WHERE name = ?", name).Scan(&spec, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	preset := &TrainingPreset{Name: name}
+	if err := json.Unmarshal([]byte(spec), &preset.Spec); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal preset '%s': %v", name, err)
+	}
+	preset.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	preset.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	return preset, nil
+}
+
+// listTrainingPresetsHandler handles GET /model/train/presets.
+func (srv *ILabServer) listTrainingPresetsHandler(w http.ResponseWriter, r *http.Request) {
+	srv.log.Info("GET /model/train/presets called")
+
+	rows, err := srv.db.Query("SELECT name FROM training_presets ORDER BY name")
+	if err != nil {
+		srv.log.Errorf("Error listing training presets: %v", err)
+		http.Error(w, "Failed to list training presets", http.StatusInternalServerError)
+		return
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err == nil {
+			names = append(names, name)
+		}
+	}
+	rows.Close()
+
+	presets := []TrainingPreset{}
+	for _, name := range names {
+		preset, err := srv.getTrainingPreset(name)
+		if err != nil {
+			srv.log.Errorf("Error loading training preset '%s': %v", name, err)
+			continue
+		}
+		if preset != nil {
+			presets = append(presets, *preset)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(presets)
+}
+
+// getTrainingPresetHandler handles GET /model/train/presets/{name}.
+func (srv *ILabServer) getTrainingPresetHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	srv.log.Infof("GET /model/train/presets/%s called", name)
+
+	preset, err := srv.getTrainingPreset(name)
+	if err != nil {
+		srv.log.Errorf("Error loading training preset '%s': %v", name, err)
+		http.Error(w, "Failed to load training preset", http.StatusInternalServerError)
+		return
+	}
+	if preset == nil {
+		http.Error(w, fmt.Sprintf("Training preset '%s' not found", name), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(preset)
+}
+
+// putTrainingPresetHandler handles PUT /model/train/presets/{name}, creating or replacing a
+// preset with the TrainingSpec in the body.
+func (srv *ILabServer) putTrainingPresetHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	srv.log.Infof("PUT /model/train/presets/%s called", name)
+
+	if !resourceNamePattern.MatchString(name) {
+		http.Error(w, fmt.Sprintf("Invalid preset name '%s'", name), http.StatusBadRequest)
+		return
+	}
+	var spec TrainingSpec
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid training spec: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := spec.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		http.Error(w, "Failed to encode training spec", http.StatusInternalServerError)
+		return
+	}
+
+	existing, err := srv.getTrainingPreset(name)
+	if err != nil {
+		srv.log.Errorf("Error loading training preset '%s': %v", name, err)
+		http.Error(w, "Failed to save training preset", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().Format(time.RFC3339)
+	_, err = srv.db.Exec(`
+        INSERT INTO training_presets (name, spec, created_at, updated_at) VALUES (?, ?, ?, ?)
+        ON CONFLICT(name) DO UPDATE SET spec = excluded.spec, updated_at = excluded.updated_at
+    `, name, string(data), now, now)
+	if err != nil {
+		srv.log.Errorf("Error saving training preset '%s': %v", name, err)
+		http.Error(w, "Failed to save training preset", http.StatusInternalServerError)
+		return
+	}
+	srv.log.Infof("Saved training preset '%s': %s", name, data)
+
+	preset, _ := srv.getTrainingPreset(name)
+	w.Header().Set("Content-Type", "application/json")
+	if existing == nil {
+		w.WriteHeader(http.StatusCreated)
+	}
+	_ = json.NewEncoder(w).Encode(preset)
+}
+
+// deleteTrainingPresetHandler handles DELETE /model/train/presets/{name}.
+func (srv *ILabServer) deleteTrainingPresetHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	srv.log.Infof("DELETE /model/train/presets/%s called", name)
+
+	res, err := srv.db.Exec("DELETE FROM training_presets WHERE name = ?", name)
+	if err != nil {
+		srv.log.Errorf("Error deleting training preset '%s': %v", name, err)
+		http.Error(w, "Failed to delete training preset", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, fmt.Sprintf("Training preset '%s' not found", name), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
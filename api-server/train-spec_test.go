@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResolveTrainingSpecHyperparameters(t *testing.T) {
+	srv := newTestServer(t)
+	for _, tc := range []struct {
+		name            string
+		hyperparameters string
+		epochs          *int
+		wantEpochs      int
+		wantErr         string
+	}{
+		{name: "absent", epochs: intPtr(2), wantEpochs: 2},
+		{name: "null", hyperparameters: "null", epochs: intPtr(2), wantEpochs: 2},
+		{name: "known fields", hyperparameters: `{"num_epochs": 4, "learning_rate": 2e-5}`, wantEpochs: 4},
+		{name: "unknown field", hyperparameters: `{"num_epoch": 4}`, wantErr: `unknown field "num_epoch"`},
+		{name: "wrong type", hyperparameters: `{"num_epochs": "4"}`, wantErr: "invalid 'hyperparameters'"},
+		{name: "disagreeing epochs", hyperparameters: `{"num_epochs": 4}`, epochs: intPtr(2), wantErr: "disagree"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec, err := srv.resolveTrainingSpec("", json.RawMessage(tc.hyperparameters), tc.epochs)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if spec.NumEpochs == nil || *spec.NumEpochs != tc.wantEpochs {
+				t.Errorf("num_epochs = %v, want %d", spec.NumEpochs, tc.wantEpochs)
+			}
+		})
+	}
+}
+
+func TestTrainHandlersRejectUnknownHyperparameters(t *testing.T) {
+	srv := newTestServer(t)
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+	}{
+		{"train", srv.trainModelHandler, `{"branchName": "main", "hyperparameters": {"lr": 0.1}}`},
+		{"phased", srv.phasedTrainHandler, `{"branchName": "main", "knowledge": {"hyperparameters": {"lr": 0.1}}}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tc.handler(w, httptest.NewRequest(http.MethodPost, "/model/train", strings.NewReader(tc.body)))
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `unknown field "lr"`) {
+				t.Errorf("response = %d %q, want 400 naming the unknown field", w.Code, w.Body)
+			}
+		})
+	}
+}
@@ -71,70 +71,85 @@ func getBaseCacheDir() (string, error) {
 	return filepath.Join(homeDir, ".cache", "instructlab"), nil
 }
 
-// getFullModelPath converts a user-supplied model name into a fully qualified path:
-//
-//	~/.cache/instructlab/models/<modelName>
-func getFullModelPath(modelName string) (string, error) {
+// getBaseDataDir returns the base data directory path: ~/.local/share/instructlab/
+func getBaseDataDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %v", err)
+	}
+	return filepath.Join(homeDir, ".local", "share", "instructlab"), nil
+}
+
+// getModelsDir returns the directory ilab downloads models into: ~/.cache/instructlab/models
+func getModelsDir() (string, error) {
 	baseCacheDir, err := getBaseCacheDir()
 	if err != nil {
 		return "", err
 	}
-	// If user-supplied name already starts with "models/", don't prepend again
-	if strings.HasPrefix(modelName, "models/") {
-		return filepath.Join(baseCacheDir, modelName), nil
-	}
-	return filepath.Join(baseCacheDir, "models", modelName), nil
+	return filepath.Join(baseCacheDir, "models"), nil
 }
 
-// findLatestDirWithPrefix finds the most recently modified directory within 'dir' that starts with 'prefix'.
-func (srv *ILabServer) findLatestDirWithPrefix(dir, prefix string) (string, error) {
-	entries, err := ioutil.ReadDir(dir)
+// getCheckpointsDir returns the directory holding HF-format training checkpoints:
+// ~/.local/share/instructlab/checkpoints/hf_format
+func getCheckpointsDir() (string, error) {
+	baseDataDir, err := getBaseDataDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to read directory '%s': %v", dir, err)
+		return "", err
 	}
+	return filepath.Join(baseDataDir, "checkpoints", "hf_format"), nil
+}
 
-	var latestDir os.FileInfo
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-		if strings.HasPrefix(entry.Name(), prefix) {
-			if latestDir == nil || entry.ModTime().After(latestDir.ModTime()) {
-				latestDir = entry
-			}
-		}
+// getTrainOutputsDir returns the directory holding the output directories of individual
+// training jobs: ~/.local/share/instructlab/checkpoints/jobs
+func getTrainOutputsDir() (string, error) {
+	baseDataDir, err := getBaseDataDir()
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(baseDataDir, "checkpoints", "jobs"), nil
+}
 
-	if latestDir == nil {
-		return "", fmt.Errorf("no directory found in '%s' with prefix '%s'", dir, prefix)
+// getTrainOutputDir returns the output directory (--ckpt-output-dir) of a training job.
+// ilab writes its HF-format checkpoints to the hf_format subdirectory and its metrics
+// files next to it.
+func getTrainOutputDir(jobID string) (string, error) {
+	outputsDir, err := getTrainOutputsDir()
+	if err != nil {
+		return "", err
 	}
-
-	latestPath := filepath.Join(dir, latestDir.Name())
-	return latestPath, nil
+	return filepath.Join(outputsDir, jobID), nil
 }
 
-// getLatestDatasetFile returns the path to the latest dataset file named "knowledge_train_msgs_*.jsonl".
-func (srv *ILabServer) getLatestDatasetFile() (string, error) {
-	homeDir, err := os.UserHomeDir()
+// getDatasetsDir returns the directory SDG writes datasets into: ~/.local/share/instructlab/datasets
+func getDatasetsDir() (string, error) {
+	baseDataDir, err := getBaseDataDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %v", err)
+		return "", err
 	}
-	datasetDir := filepath.Join(homeDir, ".local", "share", "instructlab", "datasets")
-	files, err := ioutil.ReadDir(datasetDir)
+	return filepath.Join(baseDataDir, "datasets"), nil
+}
+
+// getFullModelPath converts a user-supplied model name into a fully qualified path:
+//
+//	~/.cache/instructlab/models/<modelName>
+func getFullModelPath(modelName string) (string, error) {
+	baseCacheDir, err := getBaseCacheDir()
 	if err != nil {
-		return "", fmt.Errorf("failed to read dataset directory: %v", err)
+		return "", err
 	}
-
-	var latestFile os.FileInfo
-	for _, file := range files {
-		if strings.HasPrefix(file.Name(), "knowledge_train_msgs_") && strings.HasSuffix(file.Name(), ".jsonl") {
-			if latestFile == nil || file.ModTime().After(latestFile.ModTime()) {
-				latestFile = file
-			}
-		}
+	// If user-supplied name already starts with "models/", don't prepend again
+	if strings.HasPrefix(modelName, "models/") {
+		return filepath.Join(baseCacheDir, modelName), nil
 	}
-	if latestFile == nil {
-		return "", fmt.Errorf("no dataset file found with the prefix 'knowledge_train_msgs_'")
+	return filepath.Join(baseCacheDir, "models", modelName), nil
+}
+
+// getLatestDatasetFile returns the path to the latest dataset file named "knowledge_train_msgs_*.jsonl",
+// including those in the per-job output directories of generate jobs.
+func (srv *ILabServer) getLatestDatasetFile() (string, error) {
+	datasetDir, err := getDatasetsDir()
+	if err != nil {
+		return "", err
 	}
-	return filepath.Join(datasetDir, latestFile.Name()), nil
+	return latestTrainingDataset(datasetDir)
 }
@@ -1,12 +1,8 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"os/exec"
-	"strings"
 )
 
 // VllmContainer details of a vllm container.
@@ -20,65 +16,40 @@ type VllmContainer struct {
 	Names           string `json:"names"`
 	ServedModelName string `json:"served_model_name"`
 	ModelPath       string `json:"model_path"`
+	JobID           string `json:"job_id"`
 }
 
-// ListVllmContainers retrieves all running vllm containers and extracts the
-// --served-model-name and --model values.
+// ListVllmContainers retrieves the running vllm containers launched by this server
+// and reads their served model name and model path from the container labels.
 func (srv *ILabServer) ListVllmContainers() ([]VllmContainer, error) {
-	// Define a custom format with a pipe delimiter to avoid splitting on spaces.
-	format := "{{.ID}}|{{.Image}}|{{.Command}}|{{.CreatedAt}}|{{.Status}}|{{.Ports}}|{{.Names}}"
-
-	cmd := exec.Command("podman", "ps",
-		"--filter", "ancestor=registry.redhat.io/rhelai1/instructlab-nvidia-rhel9:1.4-1738905416",
-		"--format", format,
-	)
-
-	var out, stderr bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("error running podman ps: %v, stderr: %s", err, stderr.String())
+	infos, err := srv.containerRuntime.List(srv.ownedContainerFilter(roleVllm))
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s containers: %v", srv.containerRuntime.Name(), err)
 	}
 
-	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
 	var containers []VllmContainer
-
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-		parts := strings.Split(line, "|")
-		if len(parts) != 7 {
-			srv.log.Warnf("Skipping malformed podman ps line: %s", line)
-			continue
-		}
-
-		containerID := strings.TrimSpace(parts[0])
-		image := strings.TrimSpace(parts[1])
-		command := strings.TrimSpace(parts[2])
-		createdAt := strings.TrimSpace(parts[3])
-		status := strings.TrimSpace(parts[4])
-		ports := strings.TrimSpace(parts[5])
-		names := strings.TrimSpace(parts[6])
-
-		// Inspect the container to get the full command & extract args
-		servedModelName, modelPath, err := srv.ExtractVllmArgs(containerID)
-		if err != nil {
-			srv.log.Warnf("Error extracting vllm args for container %s: %v", containerID, err)
-			continue
+	for _, info := range infos {
+		servedModelName, modelPath := info.Labels[labelServedModelName], info.Labels[labelModelPath]
+		if servedModelName == "" || modelPath == "" {
+			// Fall back to the container command line for containers missing the labels
+			servedModelName, modelPath, err = srv.parseVllmArgs(info.Args)
+			if err != nil {
+				srv.log.Warnf("Error extracting vllm args for container %s: %v", info.ID, err)
+				continue
+			}
 		}
 
 		container := VllmContainer{
-			ContainerID:     containerID,
-			Image:           image,
-			Command:         command,
-			CreatedAt:       createdAt,
-			Status:          status,
-			Ports:           ports,
-			Names:           names,
+			ContainerID:     info.ID,
+			Image:           info.Image,
+			Command:         info.Command,
+			CreatedAt:       info.CreatedAt,
+			Status:          info.Status,
+			Ports:           info.Ports,
+			Names:           info.Name,
 			ServedModelName: servedModelName,
 			ModelPath:       modelPath,
+			JobID:           info.Labels[labelJobID],
 		}
 		containers = append(containers, container)
 	}
@@ -88,29 +59,14 @@ func (srv *ILabServer) ListVllmContainers() ([]VllmContainer, error) {
 
 // ExtractVllmArgs inspects a container and extracts --served-model-name and --model values.
 func (srv *ILabServer) ExtractVllmArgs(containerID string) (string, string, error) {
-	inspectCmd := exec.Command("podman", "inspect",
-		"--format", "{{json .Config.Cmd}}",
-		containerID,
-	)
-
-	var inspectOut, inspectErr bytes.Buffer
-	inspectCmd.Stdout = &inspectOut
-	inspectCmd.Stderr = &inspectErr
-
-	if err := inspectCmd.Run(); err != nil {
-		return "", "", fmt.Errorf("error inspecting container %s: %v, stderr: %s",
-			containerID, err, inspectErr.String())
+	info, err := srv.containerRuntime.Inspect(containerID)
+	if err != nil {
+		return "", "", err
 	}
 
-	// The command is a JSON array, e.g.:
+	// The command is the argument list passed to the vllm entrypoint, e.g.:
 	// ["serve","/var/home/cloud-user/.cache/instructlab/models/granite-8b-starter-v1","--served-model-name","pre-train","--load-format","safetensors","--host","127.0.0.1","--port","8000"]
-	var cmdArgs []string
-	if err := json.Unmarshal(inspectOut.Bytes(), &cmdArgs); err != nil {
-		return "", "", fmt.Errorf("error unmarshalling command args for container %s: %v",
-			containerID, err)
-	}
-
-	servedModelName, modelPath, err := srv.parseVllmArgs(cmdArgs)
+	servedModelName, modelPath, err := srv.parseVllmArgs(info.Args)
 	if err != nil {
 		return "", "", fmt.Errorf("error parsing vllm args for container %s: %v", containerID, err)
 	}
@@ -170,14 +126,8 @@ func (srv *ILabServer) StopVllmContainer(servedModelName string) error {
 		return fmt.Errorf("no vllm container found with served-model-name '%s'", servedModelName)
 	}
 
-	stopCmd := exec.Command("podman", "stop", targetContainer.ContainerID)
-	var stopOut, stopErr bytes.Buffer
-	stopCmd.Stdout = &stopOut
-	stopCmd.Stderr = &stopErr
-
-	if err := stopCmd.Run(); err != nil {
-		return fmt.Errorf("error stopping container %s: %v, stderr: %s",
-			targetContainer.ContainerID, err, stopErr.String())
+	if err := srv.containerRuntime.Stop(targetContainer.ContainerID); err != nil {
+		return err
 	}
 
 	srv.log.Infof("Successfully stopped vllm container '%s' with served-model-name '%s'",